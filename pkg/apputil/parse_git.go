@@ -2,77 +2,223 @@ package apputil
 
 import (
 	"net/url"
+	"regexp"
 	"strings"
+	"sync"
 )
 
 type GitInfo struct {
-	URL      string
-	SubPath  string
+	// Repository URL suitable for clone
+	URL string
+	// Repository name followed by directory inside repository
+	SubPath string
+	// Branch, tag or commit, empty for master
 	Revision string
+	Host     string
+	Port     string
+	// Owner of repository: user, organization, group path or
+	// organization/project for Azure DevOps
+	Owner string
+	Repo  string
+	// Directory inside repository
+	Dir string
 }
 
-var knownGitHosts = map[string]string{
-	"github.com":    "tree",
-	"bitbucket.org": "src",
+// GitHostKind defines layout of repository browse URLs.
+type GitHostKind string
+
+const (
+	GitHostGeneric   GitHostKind = ""
+	GitHostGitHub    GitHostKind = "github"
+	GitHostBitbucket GitHostKind = "bitbucket"
+	GitHostGitLab    GitHostKind = "gitlab"
+	// Gitea and Gogs
+	GitHostGitea GitHostKind = "gitea"
+	GitHostAzure GitHostKind = "azure"
+)
+
+var scpLikeGitURL = regexp.MustCompile(`^(?:([\w.-]+)@)?([\w.-]+):(.*)$`)
+
+// Path segments preceding revision in browse URLs.
+var revisionMarkers = map[GitHostKind][][]string{
+	GitHostGitHub:    {{"tree"}},
+	GitHostBitbucket: {{"src"}},
+	GitHostGitLab:    {{"-", "tree"}, {"tree"}},
+	GitHostGitea:     {{"src", "branch"}, {"src", "tag"}, {"src", "commit"}, {"src"}},
 }
 
-func ParseGitURL(v interface{}) (g GitInfo) {
-	switch t := v.(type) {
-	case string:
-		t = strings.TrimSuffix(t, "/")
-		g.URL = t
-		var port_is_owner bool
-		if p := strings.Split(t, "@"); len(p) > 1 {
-			t = "https://" + p[1]
-			port_is_owner = true
+var gitHostsMu sync.RWMutex
+var knownGitHosts = map[string]GitHostKind{
+	"github.com":        GitHostGitHub,
+	"bitbucket.org":     GitHostBitbucket,
+	"gitlab.com":        GitHostGitLab,
+	"gitea.com":         GitHostGitea,
+	"codeberg.org":      GitHostGitea,
+	"dev.azure.com":     GitHostAzure,
+	"ssh.dev.azure.com": GitHostAzure,
+}
 
+// RegisterGitHost sets layout of self-hosted git server URLs. Repositories
+// of hosts registered as GitHostGeneric may be nested in groups, like on
+// GitLab; unknown hosts keep owner/repo layout.
+func RegisterGitHost(host string, kind GitHostKind) {
+	gitHostsMu.Lock()
+	defer gitHostsMu.Unlock()
+	knownGitHosts[strings.ToLower(host)] = kind
+}
+
+// gitHostKind returns layout of the host and whether the host is known.
+func gitHostKind(host string, segments []string) (GitHostKind, bool) {
+	host = strings.ToLower(host)
+	gitHostsMu.RLock()
+	kind, ok := knownGitHosts[host]
+	gitHostsMu.RUnlock()
+	if ok {
+		return kind, true
+	}
+	if strings.HasSuffix(host, ".visualstudio.com") {
+		return GitHostAzure, true
+	}
+	// Layouts which can't be confused with directories.
+	for i, s := range segments {
+		if s == "_git" {
+			return GitHostAzure, false
+		}
+		if s == "-" && i+1 < len(segments) && segments[i+1] == "tree" {
+			return GitHostGitLab, false
 		}
-		v, err := url.Parse(t)
+	}
+	return GitHostGeneric, false
+}
+
+// ParseGitURL parses repository URL which may point to revision and
+// directory inside repository, e.g. https://github.com/org/repo/tree/rev/dir.
+func ParseGitURL(v interface{}) (g GitInfo) {
+	t, ok := v.(string)
+	if !ok {
+		return
+	}
+	t = strings.TrimSuffix(t, "/")
+	g.URL = t
+
+	// Base is part of URL before repository path.
+	var base, path string
+	var query url.Values
+	if strings.Contains(t, "://") {
+		u, err := url.Parse(t)
 		if err != nil {
 			return
 		}
-		path := v.Path
-		if port_is_owner {
-			if p := v.Port(); p != "" {
-				path = "/" + p + path
+		base = u.Scheme + "://"
+		if u.User != nil {
+			base += u.User.String() + "@"
+		}
+		base += u.Host + "/"
+		g.Host, g.Port, path, query = u.Hostname(), u.Port(), u.Path, u.Query()
+	} else if m := scpLikeGitURL.FindStringSubmatch(t); m != nil {
+		base = strings.TrimSuffix(t, m[3])
+		if strings.HasPrefix(m[3], "/") {
+			base += "/"
+		}
+		g.Host, path = m[2], m[3]
+	} else {
+		return
+	}
+
+	var segments []string
+	for _, s := range strings.Split(path, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	kind, known := gitHostKind(g.Host, segments)
+
+	repoIdx := -1
+	for i, s := range segments {
+		if strings.HasSuffix(s, ".git") {
+			repoIdx = i
+			break
+		}
+	}
+	switch kind {
+	case GitHostAzure:
+		for i, s := range segments {
+			if s == "_git" && i+1 < len(segments) {
+				repoIdx = i + 1
+				break
 			}
 		}
-		p := strings.Split(path, "/")
-		if len(p) < 3 {
-			return
+		if repoIdx < 0 && len(segments) > 3 && segments[0] == "v3" {
+			// git@ssh.dev.azure.com:v3/org/project/repo
+			repoIdx = 3
 		}
-		var h = v.Hostname()
-		var known bool
-		var knownSuff string
-		if !port_is_owner {
-			if p3, ok := knownGitHosts[h]; ok && len(p) > 4 && p3 == p[3] {
-				if p[4] != "master" {
-					g.Revision = p[4]
-				}
-				known = true
-				knownSuff = "/" + p[3] + "/" + p[4]
+	case GitHostGitLab:
+		for i, s := range segments {
+			if s == "-" && i > 0 {
+				repoIdx = i - 1
+				break
 			}
 		}
-		g.SubPath = strings.TrimSuffix(p[2], ".git")
-		var dirParts []string
-		if !known {
-			if len(p) == 4 {
-				// in case https://github.com/org/repo/sub
-				dirParts = append(dirParts, p[3])
-			} else if len(p) > 4 {
-				// in case https://github.com/org/repo/sub/dir...
-				dirParts = append(dirParts, p[3], p[4])
+		if repoIdx < 0 {
+			// Legacy browse URL: group/repo/tree/rev, repository is
+			// inside of a group at least.
+			for i, s := range segments {
+				if s == "tree" && i > 1 {
+					repoIdx = i - 1
+					break
+				}
 			}
 		}
-		if len(p) > 4 {
-			dirParts = append(dirParts, p[5:]...)
+	}
+	if repoIdx < 0 {
+		if len(segments) < 2 {
+			return
 		}
-		if len(dirParts) > 0 {
-			var dir = "/" + strings.Join(dirParts, "/")
-			g.SubPath = g.SubPath + dir
-			g.URL = strings.TrimSuffix(g.URL, dir)
+		if kind == GitHostGitLab || (kind == GitHostGeneric && known) {
+			// Repository may be nested in groups, so whole path is
+			// repository.
+			repoIdx = len(segments) - 1
+		} else {
+			// owner/repo, the rest is directory
+			repoIdx = 1
 		}
-		g.URL = strings.TrimSuffix(g.URL, knownSuff)
+	}
+
+	var owner []string
+	for _, s := range segments[:repoIdx] {
+		if s != "_git" && !(kind == GitHostAzure && s == "v3") {
+			owner = append(owner, s)
+		}
+	}
+	g.Owner = strings.Join(owner, "/")
+	g.Repo = strings.TrimSuffix(segments[repoIdx], ".git")
+	g.URL = base + strings.Join(segments[:repoIdx+1], "/")
+
+	rest := segments[repoIdx+1:]
+	for _, marker := range revisionMarkers[kind] {
+		if len(rest) > len(marker) && strings.Join(rest[:len(marker)], "/") == strings.Join(marker, "/") {
+			g.Revision = rest[len(marker)]
+			rest = rest[len(marker)+1:]
+			break
+		}
+	}
+	if kind == GitHostAzure {
+		if version := query.Get("version"); len(version) > 2 {
+			// GB<branch>, GT<tag> or GC<commit>
+			g.Revision = version[2:]
+		}
+		if p := strings.Trim(query.Get("path"), "/"); p != "" {
+			rest = strings.Split(p, "/")
+		}
+	}
+	if g.Revision == "master" {
+		g.Revision = ""
+	}
+
+	g.Dir = strings.Join(rest, "/")
+	g.SubPath = g.Repo
+	if g.Dir != "" {
+		g.SubPath += "/" + g.Dir
 	}
 	return
 }
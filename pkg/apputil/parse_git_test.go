@@ -5,28 +5,101 @@ import (
 )
 
 func TestParseGitURL(t *testing.T) {
+	gh := func(url, subPath, revision, dir string) GitInfo {
+		return GitInfo{URL: url, SubPath: subPath, Revision: revision, Host: "github.com", Owner: "org", Repo: "repo", Dir: dir}
+	}
+	bb := func(url, subPath, revision, dir string) GitInfo {
+		return GitInfo{URL: url, SubPath: subPath, Revision: revision, Host: "bitbucket.org", Owner: "org", Repo: "repo", Dir: dir}
+	}
 	var tests = map[string]GitInfo{
-		"https://github.com/org/repo":                     {"https://github.com/org/repo", "repo", ""},
-		"https://github.com/org/repo/":                    {"https://github.com/org/repo", "repo", ""},
-		"https://github.com/org/repo/sub":                 {"https://github.com/org/repo", "repo/sub", ""},
-		"https://github.com/org/repo/sub/":                {"https://github.com/org/repo", "repo/sub", ""},
-		"https://github.com/org/repo/sub/dir":             {"https://github.com/org/repo", "repo/sub/dir", ""},
-		"https://github.com/org/repo/sub/dir/":            {"https://github.com/org/repo", "repo/sub/dir", ""},
-		"https://github.com/org/repo/tree/master":         {"https://github.com/org/repo", "repo", ""},
-		"https://github.com/org/repo/tree/master/":        {"https://github.com/org/repo", "repo", ""},
-		"https://github.com/org/repo/tree/master/sub":     {"https://github.com/org/repo", "repo/sub", ""},
-		"https://github.com/org/repo/tree/master/sub/":    {"https://github.com/org/repo", "repo/sub", ""},
-		"https://github.com/org/repo/tree/master/sub/dir": {"https://github.com/org/repo", "repo/sub/dir", ""},
-		"https://github.com/org/repo/tree/rev/sub/dir":    {"https://github.com/org/repo", "repo/sub/dir", "rev"},
-		"https://bitbucket.org/org/repo/sub/dir":          {"https://bitbucket.org/org/repo", "repo/sub/dir", ""},
-		"https://bitbucket.org/org/repo/src/rev/sub/dir":  {"https://bitbucket.org/org/repo", "repo/sub/dir", "rev"},
-		"git@github.com:org/repo.git":                     {"git@github.com:org/repo.git", "repo", ""},
-		"git@github.com:org/repo.git/sub":                 {"git@github.com:org/repo.git", "repo/sub", ""},
-		"git@github.com:org/repo.git/sub/dir":             {"git@github.com:org/repo.git", "repo/sub/dir", ""},
+		"https://github.com/org/repo":                     gh("https://github.com/org/repo", "repo", "", ""),
+		"https://github.com/org/repo/":                    gh("https://github.com/org/repo", "repo", "", ""),
+		"https://github.com/org/repo/sub":                 gh("https://github.com/org/repo", "repo/sub", "", "sub"),
+		"https://github.com/org/repo/sub/":                gh("https://github.com/org/repo", "repo/sub", "", "sub"),
+		"https://github.com/org/repo/sub/dir":             gh("https://github.com/org/repo", "repo/sub/dir", "", "sub/dir"),
+		"https://github.com/org/repo/sub/dir/":            gh("https://github.com/org/repo", "repo/sub/dir", "", "sub/dir"),
+		"https://github.com/org/repo/tree/master":         gh("https://github.com/org/repo", "repo", "", ""),
+		"https://github.com/org/repo/tree/master/":        gh("https://github.com/org/repo", "repo", "", ""),
+		"https://github.com/org/repo/tree/master/sub":     gh("https://github.com/org/repo", "repo/sub", "", "sub"),
+		"https://github.com/org/repo/tree/master/sub/":    gh("https://github.com/org/repo", "repo/sub", "", "sub"),
+		"https://github.com/org/repo/tree/master/sub/dir": gh("https://github.com/org/repo", "repo/sub/dir", "", "sub/dir"),
+		"https://github.com/org/repo/tree/rev/sub/dir":    gh("https://github.com/org/repo", "repo/sub/dir", "rev", "sub/dir"),
+		"https://bitbucket.org/org/repo/sub/dir":          bb("https://bitbucket.org/org/repo", "repo/sub/dir", "", "sub/dir"),
+		"https://bitbucket.org/org/repo/src/rev/sub/dir":  bb("https://bitbucket.org/org/repo", "repo/sub/dir", "rev", "sub/dir"),
+		"git@github.com:org/repo.git":                     gh("git@github.com:org/repo.git", "repo", "", ""),
+		"git@github.com:org/repo.git/sub":                 gh("git@github.com:org/repo.git", "repo/sub", "", "sub"),
+		"git@github.com:org/repo.git/sub/dir":             gh("git@github.com:org/repo.git", "repo/sub/dir", "", "sub/dir"),
+		"https://gitlab.com/group/sub/repo/-/tree/dev/src": {
+			URL: "https://gitlab.com/group/sub/repo", SubPath: "repo/src", Revision: "dev",
+			Host: "gitlab.com", Owner: "group/sub", Repo: "repo", Dir: "src",
+		},
+		"https://git.example.com/group/repo/-/tree/v1": {
+			URL: "https://git.example.com/group/repo", SubPath: "repo", Revision: "v1",
+			Host: "git.example.com", Owner: "group", Repo: "repo",
+		},
+		"https://gitea.com/org/repo/src/branch/dev/sub": {
+			URL: "https://gitea.com/org/repo", SubPath: "repo/sub", Revision: "dev",
+			Host: "gitea.com", Owner: "org", Repo: "repo", Dir: "sub",
+		},
+		"https://dev.azure.com/org/project/_git/repo?path=/src/dir&version=GBdev": {
+			URL: "https://dev.azure.com/org/project/_git/repo", SubPath: "repo/src/dir", Revision: "dev",
+			Host: "dev.azure.com", Owner: "org/project", Repo: "repo", Dir: "src/dir",
+		},
+		"git@ssh.dev.azure.com:v3/org/project/repo": {
+			URL: "git@ssh.dev.azure.com:v3/org/project/repo", SubPath: "repo",
+			Host: "ssh.dev.azure.com", Owner: "org/project", Repo: "repo",
+		},
+		"https://gitlab.com/group/subgroup/repo": {
+			URL: "https://gitlab.com/group/subgroup/repo", SubPath: "repo",
+			Host: "gitlab.com", Owner: "group/subgroup", Repo: "repo",
+		},
+		"https://gitlab.com/group/subgroup/repo/tree/dev/src": {
+			URL: "https://gitlab.com/group/subgroup/repo", SubPath: "repo/src", Revision: "dev",
+			Host: "gitlab.com", Owner: "group/subgroup", Repo: "repo", Dir: "src",
+		},
+		"https://git.example.com/org/repo/sub": {
+			URL: "https://git.example.com/org/repo", SubPath: "repo/sub",
+			Host: "git.example.com", Owner: "org", Repo: "repo", Dir: "sub",
+		},
+		"git@git.example.com:org/repo/sub/dir": {
+			URL: "git@git.example.com:org/repo", SubPath: "repo/sub/dir",
+			Host: "git.example.com", Owner: "org", Repo: "repo", Dir: "sub/dir",
+		},
+		"ssh://git@git.example.com:2222/org/repo.git/sub": {
+			URL: "ssh://git@git.example.com:2222/org/repo.git", SubPath: "repo/sub",
+			Host: "git.example.com", Port: "2222", Owner: "org", Repo: "repo", Dir: "sub",
+		},
 	}
 	for r, i := range tests {
 		if p := ParseGitURL(r); p != i {
-			t.Errorf("Parse url '%s' error: expected %v, got %v", r, i, p)
+			t.Errorf("Parse url '%s' error: expected %+v, got %+v", r, i, p)
 		}
 	}
 }
+
+func TestRegisterGitHost(t *testing.T) {
+	url := "https://git.example.org/org/repo/src/tag/v1/dir"
+	if p := ParseGitURL(url); p.Revision != "" || p.Dir != "src/tag/v1/dir" || p.URL != "https://git.example.org/org/repo" {
+		t.Fatalf("Unexpected result for unknown host: %+v", p)
+	}
+	RegisterGitHost("git.example.org", GitHostGitea)
+	RegisterGitHost("git.example.net", GitHostGeneric)
+	defer func() {
+		gitHostsMu.Lock()
+		delete(knownGitHosts, "git.example.org")
+		delete(knownGitHosts, "git.example.net")
+		gitHostsMu.Unlock()
+	}()
+	if p := ParseGitURL(url); p.Revision != "v1" || p.Dir != "dir" || p.URL != "https://git.example.org/org/repo" {
+		t.Fatalf("Unexpected result for registered host: %+v", p)
+	}
+	// Repositories of registered generic hosts may be nested in groups.
+	p := ParseGitURL("git@git.example.net:group/subgroup/repo")
+	want := GitInfo{
+		URL: "git@git.example.net:group/subgroup/repo", SubPath: "repo",
+		Host: "git.example.net", Owner: "group/subgroup", Repo: "repo",
+	}
+	if p != want {
+		t.Fatalf("Expected %+v, got %+v", want, p)
+	}
+}
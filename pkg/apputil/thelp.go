@@ -22,6 +22,9 @@ func gitRepo(v interface{}) string {
 func gitSubPath(v interface{}) string {
 	return ParseGitURL(v).SubPath
 }
+func gitRevision(v interface{}) string {
+	return ParseGitURL(v).Revision
+}
 
 func FuncMap() template.FuncMap {
 	f := sprig.TxtFuncMap()
@@ -29,9 +32,10 @@ func FuncMap() template.FuncMap {
 	delete(f, "expandenv")
 	// Add some extra functionality
 	extra := template.FuncMap{
-		"toYaml":     ToYaml,
-		"gitSubPath": gitSubPath,
-		"gitRepo":    gitRepo,
+		"toYaml":      ToYaml,
+		"gitSubPath":  gitSubPath,
+		"gitRepo":     gitRepo,
+		"gitRevision": gitRevision,
 	}
 	for k, v := range extra {
 		f[k] = v
@@ -129,3 +129,26 @@ func (c *Client) GetDataset(workspace string, name string) (*Dataset, error) {
 	}
 	return ds, nil
 }
+
+type DatasetVersion struct {
+	Version   string   `json:"version"`
+	Message   string   `json:"message,omitempty"`
+	SizeBytes int64    `json:"size_bytes,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+}
+
+func (c *Client) ListDatasetVersions(workspace, name string) ([]DatasetVersion, error) {
+	u := fmt.Sprintf("/workspace/%v/dataset/%v/versions", workspace, name)
+
+	var vs = make([]DatasetVersion, 0)
+	req, err := c.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	_, err = c.Do(req, &vs)
+
+	if err != nil {
+		return nil, err
+	}
+	return vs, nil
+}
@@ -11,6 +11,14 @@ type ResourceLimit struct {
 	Replicas      int64              `json:"replicas,omitempty"`
 	ParallelRuns  int64              `json:"parallel_runs,omitempty"`
 	ExecutionTime int64              `json:"execution_time,omitempty"`
+	// Local disk of container
+	EphemeralStorage *resource.Quantity `json:"ephemeral_storage,omitempty"`
+	// Size of memory-backed /dev/shm
+	SharedMemory *resource.Quantity `json:"shared_memory,omitempty"`
+	// Hugepages by page size, e.g. 2Mi or 1Gi
+	HugePages map[string]*resource.Quantity `json:"hugepages,omitempty"`
+	// The highest priority level allowed for components
+	MaxPriority string `json:"max_priority,omitempty"`
 }
 
 func (r *ResourceLimit) MinimizeTo(limit ResourceLimit) {
@@ -31,6 +39,14 @@ func (r *ResourceLimit) MinimizeTo(limit ResourceLimit) {
 	if r.ExecutionTime > limit.ExecutionTime && limit.Replicas > 0 || r.ExecutionTime <= 0 {
 		r.ExecutionTime = limit.ExecutionTime
 	}
+	r.EphemeralStorage = minQuantity(r.EphemeralStorageQuantity(), limit.EphemeralStorageQuantity())
+	r.SharedMemory = minQuantity(r.SharedMemoryQuantity(), limit.SharedMemoryQuantity())
+	for size, q := range limit.HugePages {
+		if r.HugePages == nil {
+			r.HugePages = make(map[string]*resource.Quantity)
+		}
+		r.HugePages[size] = minQuantity(r.HugePagesQuantity(size), q)
+	}
 	r.Memory = nil
 	r.CPU = nil
 	if minCPU != nil {
@@ -93,6 +109,35 @@ func (r *ResourceLimit) MemoryQuantity() *resource.Quantity {
 	return q
 }
 
+func (r *ResourceLimit) EphemeralStorageQuantity() *resource.Quantity {
+	if r == nil {
+		return nil
+	}
+	return positiveQuantity(r.EphemeralStorage)
+}
+
+func (r *ResourceLimit) SharedMemoryQuantity() *resource.Quantity {
+	if r == nil {
+		return nil
+	}
+	return positiveQuantity(r.SharedMemory)
+}
+
+// HugePagesQuantity returns hugepages of the page size, e.g. 2Mi.
+func (r *ResourceLimit) HugePagesQuantity(size string) *resource.Quantity {
+	if r == nil {
+		return nil
+	}
+	return positiveQuantity(r.HugePages[size])
+}
+
+func positiveQuantity(q *resource.Quantity) *resource.Quantity {
+	if q == nil || q.Sign() <= 0 {
+		return nil
+	}
+	return q
+}
+
 func minQuantity(val *resource.Quantity, limit *resource.Quantity) *resource.Quantity {
 	if val == nil || val.Value() < 0 {
 		return limit
@@ -133,3 +133,46 @@ func (c *Client) GetModel(workspace string, name string) (*Dataset, error) {
 	}
 	return ds, nil
 }
+
+type ModelVersion struct {
+	Version     string `json:"version"`
+	Message     string `json:"message,omitempty"`
+	DownloadURL string `json:"download_url,omitempty"`
+	SizeBytes   int64  `json:"size_bytes,omitempty"`
+	// Checksum of model archive, "sha256:<hex>"
+	Checksum string `json:"checksum,omitempty"`
+	// Tags attached to the version, e.g. "production"
+	Tags []string `json:"tags,omitempty"`
+}
+
+func (c *Client) GetModelVersion(workspace, name, version string) (*ModelVersion, error) {
+	u := fmt.Sprintf("/workspace/%v/mlmodel/%v/versions/%v", workspace, name, version)
+
+	var v = &ModelVersion{}
+	req, err := c.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	_, err = c.Do(req, v)
+
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (c *Client) ListModelVersions(workspace, name string) ([]ModelVersion, error) {
+	u := fmt.Sprintf("/workspace/%v/mlmodel/%v/versions", workspace, name)
+
+	var vs = make([]ModelVersion, 0)
+	req, err := c.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	_, err = c.Do(req, &vs)
+
+	if err != nil {
+		return nil, err
+	}
+	return vs, nil
+}
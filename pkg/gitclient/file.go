@@ -0,0 +1,139 @@
+package gitclient
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// lsRemoteFile reads references of local repository directly from its
+// git directory: HEAD, loose references and packed-refs.
+func lsRemoteFile(path string) ([]Ref, error) {
+	gitDir := path
+	if st, err := os.Stat(filepath.Join(path, ".git")); err == nil && st.IsDir() {
+		gitDir = filepath.Join(path, ".git")
+	}
+	if _, err := os.Stat(filepath.Join(gitDir, "HEAD")); err != nil {
+		return nil, fmt.Errorf("%v is not a git repository", path)
+	}
+
+	byName := make(map[string]string)
+	if err := readPackedRefs(gitDir, byName); err != nil {
+		return nil, err
+	}
+	refsDir := filepath.Join(gitDir, "refs")
+	err := filepath.Walk(refsDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		hash := strings.TrimSpace(string(data))
+		if !fullSHA.MatchString(hash) {
+			return nil
+		}
+		rel, _ := filepath.Rel(gitDir, p)
+		name := filepath.ToSlash(rel)
+		// Loose references override packed ones.
+		byName[name] = hash
+		delete(byName, name+"^{}")
+		if strings.HasPrefix(name, "refs/tags/") {
+			if target := peelLooseTag(gitDir, hash); target != "" {
+				byName[name+"^{}"] = target
+			}
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	head, err := ioutil.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return nil, err
+	}
+	headRef := strings.TrimSpace(string(head))
+	if strings.HasPrefix(headRef, "ref: ") {
+		if hash, ok := byName[strings.TrimPrefix(headRef, "ref: ")]; ok {
+			byName[HEAD] = hash
+		}
+	} else if fullSHA.MatchString(headRef) {
+		byName[HEAD] = headRef
+	}
+
+	refs := make([]Ref, 0, len(byName))
+	for name, hash := range byName {
+		refs = append(refs, Ref{Name: name, Hash: hash})
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Name < refs[j].Name })
+	return refs, nil
+}
+
+func readPackedRefs(gitDir string, byName map[string]string) error {
+	f, err := os.Open(filepath.Join(gitDir, "packed-refs"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var last string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "^"):
+			if last != "" {
+				byName[last+"^{}"] = strings.TrimPrefix(line, "^")
+			}
+		default:
+			parts := strings.SplitN(line, " ", 2)
+			if len(parts) == 2 && fullSHA.MatchString(parts[0]) {
+				byName[parts[1]] = parts[0]
+				last = parts[1]
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// peelLooseTag returns object the annotated tag points to, if tag is stored
+// as loose object. Empty string is returned otherwise.
+func peelLooseTag(gitDir, hash string) string {
+	f, err := os.Open(filepath.Join(gitDir, "objects", hash[:2], hash[2:]))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	z, err := zlib.NewReader(f)
+	if err != nil {
+		return ""
+	}
+	defer z.Close()
+	data := make([]byte, 512)
+	n, _ := io.ReadFull(z, data)
+	data = data[:n]
+	if !bytes.HasPrefix(data, []byte("tag ")) {
+		return ""
+	}
+	i := bytes.IndexByte(data, 0)
+	if i < 0 || !bytes.HasPrefix(data[i+1:], []byte("object ")) {
+		return ""
+	}
+	body := data[i+1+len("object "):]
+	if len(body) < 40 || !fullSHA.Match(body[:40]) {
+		return ""
+	}
+	return string(body[:40])
+}
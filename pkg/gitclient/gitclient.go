@@ -0,0 +1,149 @@
+// Package gitclient resolves git references of remote repositories without
+// calling git binary. Smart HTTP(S), SSH and local (file://) repositories are
+// supported.
+package gitclient
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const HEAD = "HEAD"
+
+var fullSHA = regexp.MustCompile("^[0-9a-f]{40}$")
+var scpLikeURL = regexp.MustCompile(`^(?:([\w.-]+)@)?([\w.-]+):([^/\\:\s][^\\\s]*)$`)
+
+type Ref struct {
+	Name string
+	Hash string
+}
+
+// Auth holds credentials in memory. Private key is never written to disk.
+type Auth struct {
+	// Basic auth for HTTP(S)
+	Username string
+	Password string
+	// PEM encoded private key for SSH
+	PrivateKey []byte
+	// Content of known_hosts file used to verify SSH host keys, hashed
+	// entries are supported. If empty, KnownHostsFiles are used.
+	KnownHosts []byte
+}
+
+// KnownHostsFiles are used to verify SSH host keys if Auth.KnownHosts is empty.
+var KnownHostsFiles = []string{"~/.ssh/known_hosts", "/etc/ssh/ssh_known_hosts"}
+
+// RevisionNotFoundError is returned if revision doesn't exist in repository.
+type RevisionNotFoundError struct {
+	URL      string
+	Revision string
+}
+
+func (e *RevisionNotFoundError) Error() string {
+	return fmt.Sprintf("revision '%v' not found in %v", e.Revision, e.URL)
+}
+
+// Endpoint is parsed repository URL.
+type Endpoint struct {
+	Scheme string
+	User   string
+	Host   string
+	Port   string
+	Path   string
+}
+
+// ParseEndpoint parses repository URL, including scp-like syntax
+// user@host:path and local paths.
+func ParseEndpoint(repo string) (*Endpoint, error) {
+	if strings.Contains(repo, "://") {
+		u, err := url.Parse(repo)
+		if err != nil {
+			return nil, err
+		}
+		e := &Endpoint{Scheme: u.Scheme, Host: u.Hostname(), Port: u.Port(), Path: u.Path}
+		if u.User != nil {
+			e.User = u.User.Username()
+		}
+		switch e.Scheme {
+		case "http", "https", "ssh", "file":
+		default:
+			return nil, fmt.Errorf("unsupported repository scheme '%v'", e.Scheme)
+		}
+		if e.Scheme != "file" && e.Host == "" {
+			return nil, fmt.Errorf("repository host is empty: %v", repo)
+		}
+		return e, nil
+	}
+	if m := scpLikeURL.FindStringSubmatch(repo); m != nil {
+		return &Endpoint{Scheme: "ssh", User: m[1], Host: m[2], Path: m[3]}, nil
+	}
+	if strings.HasPrefix(repo, "/") {
+		return &Endpoint{Scheme: "file", Path: repo}, nil
+	}
+	return nil, fmt.Errorf("invalid repository URL: %v", repo)
+}
+
+// LsRemote lists references of the repository, like git ls-remote.
+func LsRemote(ctx context.Context, repo string, auth *Auth) ([]Ref, error) {
+	if auth == nil {
+		auth = &Auth{}
+	}
+	e, err := ParseEndpoint(repo)
+	if err != nil {
+		return nil, err
+	}
+	switch e.Scheme {
+	case "http", "https":
+		return lsRemoteHTTP(ctx, repo, auth)
+	case "ssh":
+		return lsRemoteSSH(ctx, e, auth)
+	default:
+		return lsRemoteFile(e.Path)
+	}
+}
+
+// ResolveRevision returns commit hash of the revision: branch, tag or
+// full reference name. Empty revision means HEAD. Full commit hashes are
+// returned as is.
+func ResolveRevision(ctx context.Context, repo, revision string, auth *Auth) (string, error) {
+	if fullSHA.MatchString(revision) {
+		return revision, nil
+	}
+	refs, err := LsRemote(ctx, repo, auth)
+	if err != nil {
+		return "", err
+	}
+	if hash := FindRef(refs, revision); hash != "" {
+		return hash, nil
+	}
+	if revision == "" {
+		revision = HEAD
+	}
+	return "", &RevisionNotFoundError{URL: repo, Revision: revision}
+}
+
+// FindRef looks for the revision in references in the same order as git
+// does: exact name, refs/<name>, refs/tags/<name>, refs/heads/<name>.
+// Annotated tags are resolved to commits.
+func FindRef(refs []Ref, revision string) string {
+	if revision == "" {
+		revision = HEAD
+	}
+	byName := make(map[string]string, len(refs))
+	for _, r := range refs {
+		byName[r.Name] = r.Hash
+	}
+	candidates := []string{revision, "refs/" + revision, "refs/tags/" + revision, "refs/heads/" + revision}
+	for _, name := range candidates {
+		if hash, ok := byName[name+"^{}"]; ok {
+			return hash
+		}
+		if hash, ok := byName[name]; ok {
+			return hash
+		}
+	}
+	return ""
+}
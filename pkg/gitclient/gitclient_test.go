@@ -0,0 +1,165 @@
+package gitclient
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func git(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(
+		os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// testRepo creates repository with master and feature branches, lightweight
+// tag v1 and annotated tag v2. It returns working copy and bare clone paths.
+func testRepo(t *testing.T) (string, string, map[string]string) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "gitclient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	work := filepath.Join(dir, "work")
+	os.Mkdir(work, 0755)
+	git(t, work, "init", "-q", "-b", "master")
+	ioutil.WriteFile(filepath.Join(work, "a"), []byte("a"), 0644)
+	git(t, work, "add", "a")
+	git(t, work, "commit", "-q", "-m", "first")
+	first := git(t, work, "rev-parse", "HEAD")
+	git(t, work, "tag", "v1")
+	git(t, work, "checkout", "-q", "-b", "feature")
+	ioutil.WriteFile(filepath.Join(work, "b"), []byte("b"), 0644)
+	git(t, work, "add", "b")
+	git(t, work, "commit", "-q", "-m", "second")
+	second := git(t, work, "rev-parse", "HEAD")
+	git(t, work, "tag", "-a", "v2", "-m", "annotated")
+	git(t, work, "checkout", "-q", "master")
+
+	bare := filepath.Join(dir, "repo.git")
+	git(t, dir, "clone", "-q", "--bare", work, bare)
+	// Bare clone keeps references packed.
+	git(t, bare, "pack-refs", "--all")
+	return work, bare, map[string]string{
+		"":                   first,
+		"master":             first,
+		"v1":                 first,
+		"feature":            second,
+		"refs/heads/feature": second,
+		"v2":                 second,
+		second:               second,
+	}
+}
+
+func checkResolve(t *testing.T, repo string, auth *Auth, expected map[string]string) {
+	for rev, hash := range expected {
+		got, err := ResolveRevision(context.Background(), repo, rev, auth)
+		if err != nil {
+			t.Errorf("Resolve %q in %v: %v", rev, repo, err)
+			continue
+		}
+		if got != hash {
+			t.Errorf("Resolve %q in %v: expected %v, got %v", rev, repo, hash, got)
+		}
+	}
+	_, err := ResolveRevision(context.Background(), repo, "missing", auth)
+	if _, ok := err.(*RevisionNotFoundError); !ok {
+		t.Errorf("Expected RevisionNotFoundError for %v, got %v", repo, err)
+	}
+}
+
+func TestResolveFile(t *testing.T) {
+	work, bare, expected := testRepo(t)
+	checkResolve(t, "file://"+work, nil, expected)
+	checkResolve(t, "file://"+bare, nil, expected)
+	checkResolve(t, bare, nil, expected)
+
+	if _, err := LsRemote(context.Background(), "file://"+filepath.Dir(bare), nil); err == nil {
+		t.Error("Expected error for non-repository directory")
+	}
+}
+
+func TestResolveSmartHTTP(t *testing.T) {
+	_, bare, expected := testRepo(t)
+	backend := &cgi.Handler{
+		Path: "git",
+		Args: []string{"http-backend"},
+		Env: []string{
+			"GIT_PROJECT_ROOT=" + filepath.Dir(bare),
+			"GIT_HTTP_EXPORT_ALL=1",
+		},
+	}
+	if p, err := exec.LookPath("git"); err == nil {
+		backend.Path = p
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "user" || pass != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		backend.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	repo := server.URL + "/repo.git"
+	checkResolve(t, repo, &Auth{Username: "user", Password: "token"}, expected)
+
+	if _, err := LsRemote(context.Background(), repo, &Auth{Username: "user", Password: "wrong"}); err == nil {
+		t.Error("Expected authentication error")
+	}
+	if _, err := LsRemote(context.Background(), server.URL+"/missing.git", &Auth{Username: "user", Password: "token"}); err == nil {
+		t.Error("Expected error for missing repository")
+	}
+}
+
+func TestParseEndpoint(t *testing.T) {
+	cases := map[string]Endpoint{
+		"git@github.com:kuberlab/lib.git":             {Scheme: "ssh", User: "git", Host: "github.com", Path: "kuberlab/lib.git"},
+		"ssh://git@gitlab.example.com:2222/g/lib.git": {Scheme: "ssh", User: "git", Host: "gitlab.example.com", Port: "2222", Path: "/g/lib.git"},
+		"https://github.com/kuberlab/lib":             {Scheme: "https", Host: "github.com", Path: "/kuberlab/lib"},
+		"/srv/git/lib.git":                            {Scheme: "file", Path: "/srv/git/lib.git"},
+	}
+	for in, expected := range cases {
+		e, err := ParseEndpoint(in)
+		if err != nil {
+			t.Errorf("Parse %v: %v", in, err)
+			continue
+		}
+		if *e != expected {
+			t.Errorf("Parse %v: expected %+v, got %+v", in, expected, *e)
+		}
+	}
+	for _, in := range []string{"ext::sh -c id", "ftp://host/repo", "relative/path", "https:///repo"} {
+		if _, err := ParseEndpoint(in); err == nil {
+			t.Errorf("Expected error for %v", in)
+		}
+	}
+}
+
+func TestSSHRequiresKnownHosts(t *testing.T) {
+	defer func(files []string) { KnownHostsFiles = files }(KnownHostsFiles)
+	KnownHostsFiles = []string{}
+	if _, err := hostKeyCallback(&Auth{}); err == nil {
+		t.Error("Expected error without known hosts")
+	}
+}
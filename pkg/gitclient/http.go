@@ -0,0 +1,59 @@
+package gitclient
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const uploadPackAdvertisement = "application/x-git-upload-pack-advertisement"
+
+// HTTPClient is used for smart HTTP requests.
+var HTTPClient = &http.Client{Timeout: time.Minute}
+
+func lsRemoteHTTP(ctx context.Context, repo string, auth *Auth) ([]Ref, error) {
+	u := strings.TrimSuffix(repo, "/") + "/info/refs?service=git-upload-pack"
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", "git/kuberlab-gitclient")
+	if auth.Username != "" || auth.Password != "" {
+		req.SetBasicAuth(auth.Username, auth.Password)
+	}
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, fmt.Errorf("authentication failed for %v: %v", repo, resp.Status)
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("repository %v not found", repo)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("failed get references of %v: %v", repo, resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != uploadPackAdvertisement {
+		return nil, fmt.Errorf("%v doesn't support smart HTTP protocol (content type '%v')", repo, ct)
+	}
+
+	r := bufio.NewReader(resp.Body)
+	// Smart HTTP response starts with service announcement and flush.
+	line, err := readPktLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(string(line)) != "# service=git-upload-pack" {
+		return nil, fmt.Errorf("unexpected service announcement %q", line)
+	}
+	if line, err = readPktLine(r); err != nil || line != nil {
+		return nil, fmt.Errorf("expected flush packet after service announcement")
+	}
+	return readAdvertisement(r)
+}
@@ -0,0 +1,86 @@
+package gitclient
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const zeroSHA = "0000000000000000000000000000000000000000"
+
+// readPktLine reads one pkt-line. Flush packet is returned as nil.
+func readPktLine(r *bufio.Reader) ([]byte, error) {
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, err
+	}
+	size, err := strconv.ParseUint(string(head), 16, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pkt-line length %q", head)
+	}
+	if size == 0 {
+		return nil, nil
+	}
+	if size < 4 {
+		return nil, fmt.Errorf("invalid pkt-line length %v", size)
+	}
+	line := make([]byte, size-4)
+	if _, err := io.ReadFull(r, line); err != nil {
+		return nil, err
+	}
+	return line, nil
+}
+
+// readAdvertisement parses reference advertisement of upload-pack till
+// flush packet.
+func readAdvertisement(r *bufio.Reader) ([]Ref, error) {
+	refs := make([]Ref, 0)
+	symrefs := make(map[string]string)
+	first := true
+	for {
+		line, err := readPktLine(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed read references: %v", err)
+		}
+		if line == nil {
+			break
+		}
+		line = bytes.TrimSuffix(line, []byte("\n"))
+		if bytes.HasPrefix(line, []byte("ERR ")) {
+			return nil, fmt.Errorf("remote error: %s", line[4:])
+		}
+		if first {
+			first = false
+			if i := bytes.IndexByte(line, 0); i >= 0 {
+				for _, c := range strings.Fields(string(line[i+1:])) {
+					if strings.HasPrefix(c, "symref=") {
+						parts := strings.SplitN(strings.TrimPrefix(c, "symref="), ":", 2)
+						if len(parts) == 2 {
+							symrefs[parts[0]] = parts[1]
+						}
+					}
+				}
+				line = line[:i]
+			}
+		}
+		parts := strings.SplitN(string(line), " ", 2)
+		if len(parts) != 2 || len(parts[0]) != 40 {
+			return nil, fmt.Errorf("invalid reference line %q", line)
+		}
+		// Empty repository advertises capabilities only.
+		if parts[0] == zeroSHA {
+			continue
+		}
+		refs = append(refs, Ref{Name: parts[1], Hash: parts[0]})
+	}
+	// Some servers do not advertise HEAD itself but its symref.
+	if target, ok := symrefs[HEAD]; ok && FindRef(refs, HEAD) == "" {
+		if hash := FindRef(refs, target); hash != "" {
+			refs = append(refs, Ref{Name: HEAD, Hash: hash})
+		}
+	}
+	return refs, nil
+}
@@ -0,0 +1,205 @@
+package gitclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultCacheTTL = time.Minute
+	DefaultWorkers  = 8
+	DefaultTimeout  = 30 * time.Second
+)
+
+// DefaultResolver is shared by all callers so concurrent task launches reuse
+// resolved revisions.
+var DefaultResolver = NewResolver(DefaultCacheTTL, DefaultWorkers)
+
+// Request is a revision to resolve.
+type Request struct {
+	URL      string
+	Revision string
+	Auth     *Auth
+}
+
+// Result of resolving Request.
+type Result struct {
+	Hash string
+	Err  error
+}
+
+type cacheKey struct {
+	url      string
+	revision string
+	identity string
+}
+
+type cacheEntry struct {
+	hash    string
+	expires time.Time
+}
+
+type call struct {
+	done chan struct{}
+	hash string
+	err  error
+}
+
+// Resolver resolves revisions in parallel with bounded number of workers
+// and caches successful results for TTL. Concurrent requests for the same
+// key share one remote call. Errors are not cached.
+type Resolver struct {
+	TTL time.Duration
+	// Maximum of parallel remote calls of all callers. The bound is set by
+	// NewResolver or on first use, later changes have no effect.
+	Workers int
+	// Timeout of single remote call
+	Timeout time.Duration
+
+	mu       sync.Mutex
+	cache    map[cacheKey]cacheEntry
+	inflight map[cacheKey]*call
+	sem      chan struct{}
+	now      func() time.Time
+	lsRemote func(ctx context.Context, repo string, auth *Auth) ([]Ref, error)
+}
+
+func NewResolver(ttl time.Duration, workers int) *Resolver {
+	return &Resolver{
+		TTL:      ttl,
+		Workers:  workers,
+		Timeout:  DefaultTimeout,
+		cache:    make(map[cacheKey]cacheEntry),
+		inflight: make(map[cacheKey]*call),
+		sem:      make(chan struct{}, maxInt(workers, 1)),
+		now:      time.Now,
+		lsRemote: LsRemote,
+	}
+}
+
+// identity identifies credentials without keeping them in the cache key.
+func (a *Auth) identity() string {
+	if a == nil {
+		return ""
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%d:%s%d:%s%d:", len(a.Username), a.Username, len(a.Password), a.Password, len(a.PrivateKey))
+	h.Write(a.PrivateKey)
+	// Host keys decide whether the remote is trusted at all.
+	fmt.Fprintf(h, "%d:", len(a.KnownHosts))
+	h.Write(a.KnownHosts)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// Resolve returns commit hash of the revision, see ResolveRevision.
+func (r *Resolver) Resolve(ctx context.Context, repo, revision string, auth *Auth) (string, error) {
+	if fullSHA.MatchString(revision) {
+		return revision, nil
+	}
+	key := cacheKey{url: repo, revision: revision, identity: auth.identity()}
+
+	r.mu.Lock()
+	if e, ok := r.cache[key]; ok && r.now().Before(e.expires) {
+		r.mu.Unlock()
+		return e.hash, nil
+	}
+	c, ok := r.inflight[key]
+	if !ok {
+		c = &call{done: make(chan struct{})}
+		r.inflight[key] = c
+		go r.do(key, c, repo, revision, auth)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.hash, c.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// semaphore returns semaphore of remote calls. It is sized by Workers once,
+// so calls holding slots are always counted.
+func (r *Resolver) semaphore() chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sem == nil {
+		r.sem = make(chan struct{}, maxInt(r.Workers, 1))
+	}
+	return r.sem
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// do performs remote call detached from the caller context, so the result
+// is still shared with other waiters if the first caller gives up. Calls
+// wait for a free worker, timeout starts when the call is made.
+func (r *Resolver) do(key cacheKey, c *call, repo, revision string, auth *Auth) {
+	sem := r.semaphore()
+	sem <- struct{}{}
+	defer func() { <-sem }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.Timeout)
+	defer cancel()
+
+	refs, err := r.lsRemote(ctx, repo, auth)
+	if err == nil {
+		if c.hash = FindRef(refs, revision); c.hash == "" {
+			if revision == "" {
+				revision = HEAD
+			}
+			err = &RevisionNotFoundError{URL: repo, Revision: revision}
+		}
+	}
+	c.err = err
+
+	r.mu.Lock()
+	if err == nil {
+		r.purgeLocked()
+		r.cache[key] = cacheEntry{hash: c.hash, expires: r.now().Add(r.TTL)}
+	}
+	delete(r.inflight, key)
+	r.mu.Unlock()
+	close(c.done)
+}
+
+// ResolveAll resolves requests in parallel, remote calls are bounded by
+// Workers. Results are returned in order of requests.
+func (r *Resolver) ResolveAll(ctx context.Context, reqs []Request) []Result {
+	results := make([]Result, len(reqs))
+	var wg sync.WaitGroup
+	for i := range reqs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i].Hash, results[i].Err = r.Resolve(ctx, reqs[i].URL, reqs[i].Revision, reqs[i].Auth)
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+// Purge removes expired cache entries.
+func (r *Resolver) Purge() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.purgeLocked()
+}
+
+func (r *Resolver) purgeLocked() {
+	now := r.now()
+	for k, e := range r.cache {
+		if !now.Before(e.expires) {
+			delete(r.cache, k)
+		}
+	}
+}
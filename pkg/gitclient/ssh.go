@@ -0,0 +1,122 @@
+package gitclient
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const sshTimeout = 30 * time.Second
+
+// hostKeyCallback verifies host keys against known hosts from auth or
+// KnownHostsFiles. Connection is refused if no known hosts are available.
+func hostKeyCallback(auth *Auth) (ssh.HostKeyCallback, error) {
+	tmp, err := ioutil.TempDir("", "known-hosts")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	files := make([]string, 0)
+	if len(auth.KnownHosts) > 0 {
+		// knownhosts package reads files only, public data is safe on disk.
+		f := filepath.Join(tmp, "known_hosts")
+		if err := ioutil.WriteFile(f, auth.KnownHosts, 0600); err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	} else {
+		home, _ := os.UserHomeDir()
+		for _, f := range KnownHostsFiles {
+			if strings.HasPrefix(f, "~/") {
+				f = filepath.Join(home, f[2:])
+			}
+			if _, err := os.Stat(f); err == nil {
+				files = append(files, f)
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no known hosts available to verify host key")
+	}
+	return knownhosts.New(files...)
+}
+
+func lsRemoteSSH(ctx context.Context, e *Endpoint, auth *Auth) ([]Ref, error) {
+	if len(auth.PrivateKey) == 0 {
+		return nil, fmt.Errorf("private key is required for %v", e.Host)
+	}
+	if strings.ContainsAny(e.Path, "'\n") {
+		return nil, fmt.Errorf("invalid repository path %q", e.Path)
+	}
+	signer, err := ssh.ParsePrivateKey(auth.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed parse private key: %v", err)
+	}
+	callback, err := hostKeyCallback(auth)
+	if err != nil {
+		return nil, err
+	}
+	user := e.User
+	if user == "" {
+		user = "git"
+	}
+	port := e.Port
+	if port == "" {
+		port = "22"
+	}
+	config := &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: callback,
+		Timeout:         sshTimeout,
+	}
+
+	addr := net.JoinHostPort(e.Host, port)
+	dialer := &net.Dialer{Timeout: sshTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	client := ssh.NewClient(c, chans, reqs)
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := session.Start(fmt.Sprintf("git-upload-pack '%s'", e.Path)); err != nil {
+		return nil, err
+	}
+	refs, err := readAdvertisement(bufio.NewReader(stdout))
+	// Tell upload-pack we don't want anything.
+	stdin.Write([]byte("0000"))
+	stdin.Close()
+	return refs, err
+}
@@ -0,0 +1,97 @@
+package kubernetes
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// Seconds without requests before the deployment is scaled to zero
+	ScaleToZeroIdleAnnotation = "kuberlab.io/scale-to-zero-idle-seconds"
+	// Replicas the deployment is scaled to on activation
+	ActivationReplicasAnnotation = "kuberlab.io/activation-replicas"
+)
+
+// Activator scales idle servings annotated with ScaleToZeroIdleAnnotation
+// to zero and back on request. Routing is not generated: the caller
+// receives requests of the servings without replicas (e.g. as the default
+// backend of the ingress), calls Activate before proxying them and
+// Deactivate with the time of the last request. Autoscaler of the
+// deployment is inactive while it has zero replicas, the deployment is
+// kept scaled to zero when it is applied again.
+type Activator struct {
+	Client kubernetes.Interface
+}
+
+// ScaleToZeroIdle returns how long the deployment may be idle before it is
+// scaled to zero, zero if scaling to zero is disabled.
+func ScaleToZeroIdle(d *appsv1.Deployment) time.Duration {
+	seconds, err := strconv.Atoi(d.Annotations[ScaleToZeroIdleAnnotation])
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func activationReplicas(d *appsv1.Deployment) int32 {
+	replicas, err := strconv.Atoi(d.Annotations[ActivationReplicasAnnotation])
+	if err != nil || replicas <= 0 {
+		return 1
+	}
+	return int32(replicas)
+}
+
+// keepScaledToZero returns the new deployment with zero replicas if the old
+// one is scaled to zero by the activator and the new one may be scaled to
+// zero. Replicas of the new deployment are used on the next activation.
+func keepScaledToZero(old, new *appsv1.Deployment) *appsv1.Deployment {
+	if ScaleToZeroIdle(old) == 0 || ScaleToZeroIdle(new) == 0 ||
+		old.Spec.Replicas == nil || *old.Spec.Replicas != 0 {
+		return new
+	}
+	new = new.DeepCopy()
+	zero := int32(0)
+	new.Spec.Replicas = &zero
+	return new
+}
+
+// Deactivate scales the deployment to zero if it has no requests since
+// lastRequest for its idle period. It returns true if it was scaled.
+func (a *Activator) Deactivate(ctx context.Context, namespace, name string, lastRequest, now time.Time) (bool, error) {
+	d, err := a.Client.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+	idle := ScaleToZeroIdle(d)
+	if idle == 0 || (d.Spec.Replicas != nil && *d.Spec.Replicas == 0) || now.Sub(lastRequest) < idle {
+		return false, nil
+	}
+	zero := int32(0)
+	d.Spec.Replicas = &zero
+	if _, err := a.Client.AppsV1().Deployments(namespace).Update(ctx, d, metav1.UpdateOptions{}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Activate scales the deployment scaled to zero back to its activation
+// replicas and waits until they are available.
+func (a *Activator) Activate(ctx context.Context, namespace, name string) (*RolloutStatus, error) {
+	d, err := a.Client.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if d.Spec.Replicas != nil && *d.Spec.Replicas == 0 {
+		replicas := activationReplicas(d)
+		d.Spec.Replicas = &replicas
+		if _, err := a.Client.AppsV1().Deployments(namespace).Update(ctx, d, metav1.UpdateOptions{}); err != nil {
+			return nil, err
+		}
+	}
+	return WaitRollout(ctx, a.Client, namespace, name)
+}
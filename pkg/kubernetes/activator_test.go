@@ -0,0 +1,107 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func scaleToZeroDeployment(replicas int32) *appsv1.Deployment {
+	return rolloutDeployment(func(d *appsv1.Deployment) {
+		d.Annotations[ScaleToZeroIdleAnnotation] = "300"
+		d.Annotations[ActivationReplicasAnnotation] = "2"
+		d.Spec.Replicas = &replicas
+	})
+}
+
+func deploymentReplicas(t *testing.T, client *fake.Clientset) int32 {
+	d, err := client.AppsV1().Deployments("ns").Get(context.Background(), "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *d.Spec.Replicas
+}
+
+func TestDeactivate(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name        string
+		deployment  *appsv1.Deployment
+		lastRequest time.Time
+		scaled      bool
+		replicas    int32
+	}{
+		{"idle", scaleToZeroDeployment(2), now.Add(-301 * time.Second), true, 0},
+		{"recent request", scaleToZeroDeployment(2), now.Add(-time.Minute), false, 2},
+		{"already scaled", scaleToZeroDeployment(0), now.Add(-time.Hour), false, 0},
+		{"disabled", rolloutDeployment(nil), now.Add(-time.Hour), false, 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(test.deployment)
+			a := &Activator{Client: client}
+			scaled, err := a.Deactivate(context.Background(), "ns", "web", test.lastRequest, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if scaled != test.scaled {
+				t.Fatalf("Expected scaled=%v, got %v", test.scaled, scaled)
+			}
+			if r := deploymentReplicas(t, client); r != test.replicas {
+				t.Fatalf("Expected %v replicas, got %v", test.replicas, r)
+			}
+		})
+	}
+}
+
+func TestActivate(t *testing.T) {
+	client := fake.NewSimpleClientset(scaleToZeroDeployment(0))
+	a := &Activator{Client: client}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s, err := a.Activate(ctx, "ns", "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Done || s.Replicas != 2 {
+		t.Fatalf("Unexpected status %+v", s)
+	}
+	if r := deploymentReplicas(t, client); r != 2 {
+		t.Fatalf("Expected 2 replicas, got %v", r)
+	}
+
+	// Active deployment is not scaled.
+	d := scaleToZeroDeployment(3)
+	d.Status.Replicas, d.Status.UpdatedReplicas, d.Status.AvailableReplicas = 3, 3, 3
+	client = fake.NewSimpleClientset(d)
+	a = &Activator{Client: client}
+	if _, err := a.Activate(ctx, "ns", "web"); err != nil {
+		t.Fatal(err)
+	}
+	if r := deploymentReplicas(t, client); r != 3 {
+		t.Fatalf("Expected 3 replicas, got %v", r)
+	}
+}
+
+func TestKeepScaledToZero(t *testing.T) {
+	old := scaleToZeroDeployment(0)
+	new := scaleToZeroDeployment(4)
+	if r := *keepScaledToZero(old, new).Spec.Replicas; r != 0 {
+		t.Fatalf("Deployment scaled to zero is activated by update: %v replicas", r)
+	}
+	if *new.Spec.Replicas != 4 {
+		t.Fatal("New deployment is changed")
+	}
+	// Replicas are applied if the deployment is active or scale to zero is
+	// disabled.
+	if r := *keepScaledToZero(scaleToZeroDeployment(2), new).Spec.Replicas; r != 4 {
+		t.Fatalf("Expected 4 replicas, got %v", r)
+	}
+	if r := *keepScaledToZero(old, rolloutDeployment(nil)).Spec.Replicas; r != 2 {
+		t.Fatalf("Expected 2 replicas, got %v", r)
+	}
+}
@@ -0,0 +1,301 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/api/autoscaling/v2beta2"
+	batch_v1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	policyv1 "k8s.io/api/policy/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// Resources which API version depends on the cluster.
+const (
+	ResourceHPA     = "horizontalpodautoscalers"
+	ResourcePDB     = "poddisruptionbudgets"
+	ResourceCronJob = "cronjobs"
+)
+
+// versionedResources lists API versions of the resources known to the
+// library, preferred first.
+var versionedResources = map[string][]string{
+	ResourceHPA:     {"autoscaling/v2", "autoscaling/v2beta2"},
+	ResourcePDB:     {"policy/v1", "policy/v1beta1"},
+	ResourceCronJob: {"batch/v1", "batch/v1beta1"},
+}
+
+// Capabilities are API versions served by the target cluster. Objects of
+// versioned resources are converted to the served version on apply and
+// delete.
+type Capabilities struct {
+	Version  *version.Info
+	versions map[string]string
+}
+
+// ClusterCapabilities of the cluster resources are applied to,
+// DefaultCapabilities are used if nil.
+var ClusterCapabilities *Capabilities
+
+// DefaultCapabilities returns API versions used by the library without
+// discovery.
+func DefaultCapabilities() *Capabilities {
+	return &Capabilities{versions: map[string]string{
+		ResourceHPA:     "autoscaling/v2beta2",
+		ResourcePDB:     "policy/v1",
+		ResourceCronJob: "batch/v1",
+	}}
+}
+
+// DiscoverCapabilities picks preferred API version of the versioned
+// resources served by the cluster. It fails if the cluster serves none of
+// the versions known to the library.
+func DiscoverCapabilities(client discovery.DiscoveryInterface) (*Capabilities, error) {
+	c := DefaultCapabilities()
+	v, err := client.ServerVersion()
+	if err != nil {
+		return nil, err
+	}
+	c.Version = v
+	groups, err := client.ServerGroups()
+	if err != nil {
+		return nil, err
+	}
+	served := make(map[string]bool)
+	for _, g := range groups.Groups {
+		for _, gv := range g.Versions {
+			served[gv.GroupVersion] = true
+		}
+	}
+	for resource, versions := range versionedResources {
+		found := false
+		for _, gv := range versions {
+			if !served[gv] {
+				continue
+			}
+			list, err := client.ServerResourcesForGroupVersion(gv)
+			if k8s_errors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if hasResource(list, resource) {
+				c.versions[resource] = gv
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("Cluster %v serves %v in none of supported versions %v", v.GitVersion, resource, versions)
+		}
+	}
+	return c, nil
+}
+
+func hasResource(list *meta_v1.APIResourceList, resource string) bool {
+	for _, r := range list.APIResources {
+		if r.Name == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// APIVersion returns API version of the resource served by the cluster.
+func (c *Capabilities) APIVersion(resource string) string {
+	return c.versions[resource]
+}
+
+// UseCapabilities sets capabilities and version of the target cluster.
+func UseCapabilities(c *Capabilities) {
+	ClusterCapabilities = c
+	if c.Version != nil {
+		MlBoardKubeVersion = c.Version
+	}
+}
+
+func capabilities() *Capabilities {
+	if ClusterCapabilities == nil {
+		return DefaultCapabilities()
+	}
+	return ClusterCapabilities
+}
+
+// convertVersion converts object to another API version of its kind. The
+// versions must have the same schema.
+func convertVersion(in runtime.Object, out runtime.Object, apiVersion, kind string) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return err
+	}
+	out.GetObjectKind().SetGroupVersionKind(schema.FromAPIVersionAndKind(apiVersion, kind))
+	return nil
+}
+
+// rawPath returns REST path of the namespaced resource.
+func rawPath(apiVersion, namespace, resource string, name ...string) string {
+	path := fmt.Sprintf("/apis/%v/namespaces/%v/%v", apiVersion, namespace, resource)
+	for _, n := range name {
+		path += "/" + n
+	}
+	return path
+}
+
+// applyRaw creates or updates the object through REST, it is used for API
+// versions the client has no types for.
+func applyRaw(client rest.Interface, apiVersion, resource string, obj runtime.Object, namespace, name string) error {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	obj.GetObjectKind().SetGroupVersionKind(schema.FromAPIVersionAndKind(apiVersion, gvk.Kind))
+	defer obj.GetObjectKind().SetGroupVersionKind(gvk)
+	body, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	err = client.Get().AbsPath(rawPath(apiVersion, namespace, resource, name)).Do(context.TODO()).Error()
+	if k8s_errors.IsNotFound(err) {
+		return client.Post().
+			AbsPath(rawPath(apiVersion, namespace, resource)).
+			SetHeader("Content-Type", "application/json").
+			Body(body).
+			Do(context.TODO()).
+			Error()
+	}
+	if err != nil {
+		return err
+	}
+	return client.Put().
+		AbsPath(rawPath(apiVersion, namespace, resource, name)).
+		SetHeader("Content-Type", "application/json").
+		Body(body).
+		Do(context.TODO()).
+		Error()
+}
+
+func deleteRaw(client rest.Interface, apiVersion, resource, namespace, name string, opts meta_v1.DeleteOptions) error {
+	body, err := json.Marshal(opts)
+	if err != nil {
+		return err
+	}
+	return client.Delete().
+		AbsPath(rawPath(apiVersion, namespace, resource, name)).
+		SetHeader("Content-Type", "application/json").
+		Body(body).
+		Do(context.TODO()).
+		Error()
+}
+
+func applyPDB(kubeClient *kubernetes.Clientset, v *policyv1.PodDisruptionBudget) error {
+	if apiVersion := capabilities().APIVersion(ResourcePDB); apiVersion != "policy/v1" {
+		pdb := &policyv1beta1.PodDisruptionBudget{}
+		if err := convertVersion(v, pdb, apiVersion, "PodDisruptionBudget"); err != nil {
+			return err
+		}
+		if _, err := kubeClient.PolicyV1beta1().PodDisruptionBudgets(v.Namespace).Get(context.TODO(), v.Name, meta_v1.GetOptions{}); err != nil {
+			_, err = kubeClient.PolicyV1beta1().PodDisruptionBudgets(v.Namespace).Create(context.TODO(), pdb, meta_v1.CreateOptions{})
+			return err
+		}
+		return nil
+	}
+	if _, err := kubeClient.PolicyV1().PodDisruptionBudgets(v.Namespace).Get(context.TODO(), v.Name, meta_v1.GetOptions{}); err != nil {
+		_, err := kubeClient.PolicyV1().PodDisruptionBudgets(v.Namespace).Create(context.TODO(), v, meta_v1.CreateOptions{})
+		return err
+	}
+	return nil
+}
+
+// cronJob returns the cron job in API version served by the cluster.
+func cronJob(obj runtime.Object) (runtime.Object, error) {
+	apiVersion := capabilities().APIVersion(ResourceCronJob)
+	switch v := obj.(type) {
+	case *batch_v1.CronJob:
+		if apiVersion == "batch/v1" {
+			return v, nil
+		}
+		out := &batchv1beta1.CronJob{}
+		return out, convertVersion(v, out, apiVersion, "CronJob")
+	case *batchv1beta1.CronJob:
+		if apiVersion != "batch/v1" {
+			return v, nil
+		}
+		out := &batch_v1.CronJob{}
+		return out, convertVersion(v, out, apiVersion, "CronJob")
+	}
+	return nil, fmt.Errorf("Unexpected cron job %T", obj)
+}
+
+func applyCronJob(kubeClient *kubernetes.Clientset, obj runtime.Object) error {
+	converted, err := cronJob(obj)
+	if err != nil {
+		return err
+	}
+	switch v := converted.(type) {
+	case *batch_v1.CronJob:
+		if old, err := kubeClient.BatchV1().CronJobs(v.Namespace).Get(context.TODO(), v.Name, meta_v1.GetOptions{}); err != nil {
+			_, err := kubeClient.BatchV1().CronJobs(v.Namespace).Create(context.TODO(), v, meta_v1.CreateOptions{})
+			return err
+		} else {
+			v.ResourceVersion = old.ResourceVersion
+			_, err := kubeClient.BatchV1().CronJobs(v.Namespace).Update(context.TODO(), v, meta_v1.UpdateOptions{})
+			return err
+		}
+	case *batchv1beta1.CronJob:
+		if old, err := kubeClient.BatchV1beta1().CronJobs(v.Namespace).Get(context.TODO(), v.Name, meta_v1.GetOptions{}); err != nil {
+			_, err := kubeClient.BatchV1beta1().CronJobs(v.Namespace).Create(context.TODO(), v, meta_v1.CreateOptions{})
+			return err
+		} else {
+			v.ResourceVersion = old.ResourceVersion
+			_, err := kubeClient.BatchV1beta1().CronJobs(v.Namespace).Update(context.TODO(), v, meta_v1.UpdateOptions{})
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteCronJob(kubeClient *kubernetes.Clientset, namespace, name string, opts meta_v1.DeleteOptions) error {
+	if capabilities().APIVersion(ResourceCronJob) == "batch/v1" {
+		return kubeClient.BatchV1().CronJobs(namespace).Delete(context.TODO(), name, opts)
+	}
+	return kubeClient.BatchV1beta1().CronJobs(namespace).Delete(context.TODO(), name, opts)
+}
+
+// applyHPA applies the autoscaler, autoscaling/v2 has the same schema as
+// v2beta2 and is applied through REST.
+func applyHPA(kubeClient *kubernetes.Clientset, v *v2beta2.HorizontalPodAutoscaler) error {
+	if apiVersion := capabilities().APIVersion(ResourceHPA); apiVersion != "autoscaling/v2beta2" {
+		return applyRaw(kubeClient.AutoscalingV2beta2().RESTClient(), apiVersion, ResourceHPA, v, v.Namespace, v.Name)
+	}
+	if _, err := kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers(v.Namespace).Get(context.TODO(), v.Name, meta_v1.GetOptions{}); err != nil {
+		_, err := kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers(v.Namespace).Create(context.TODO(), v, meta_v1.CreateOptions{})
+		return err
+	}
+	_, err := kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers(v.Namespace).Update(context.TODO(), v, meta_v1.UpdateOptions{})
+	return err
+}
+
+func deleteHPA(kubeClient *kubernetes.Clientset, namespace, name string, opts meta_v1.DeleteOptions) error {
+	if apiVersion := capabilities().APIVersion(ResourceHPA); apiVersion != "autoscaling/v2beta2" {
+		return deleteRaw(kubeClient.AutoscalingV2beta2().RESTClient(), apiVersion, ResourceHPA, namespace, name, opts)
+	}
+	return kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers(namespace).Delete(context.TODO(), name, opts)
+}
+
+func deletePDB(kubeClient *kubernetes.Clientset, namespace, name string, opts meta_v1.DeleteOptions) error {
+	if capabilities().APIVersion(ResourcePDB) == "policy/v1" {
+		return kubeClient.PolicyV1().PodDisruptionBudgets(namespace).Delete(context.TODO(), name, opts)
+	}
+	return kubeClient.PolicyV1beta1().PodDisruptionBudgets(namespace).Delete(context.TODO(), name, opts)
+}
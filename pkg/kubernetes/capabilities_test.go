@@ -0,0 +1,223 @@
+package kubernetes
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"k8s.io/api/autoscaling/v2beta2"
+	batch_v1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	apiv1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	fakerest "k8s.io/client-go/rest/fake"
+)
+
+func fakeDiscovery(gitVersion string, served map[string][]string) *fakediscovery.FakeDiscovery {
+	d := fake.NewSimpleClientset().Discovery().(*fakediscovery.FakeDiscovery)
+	d.FakedServerVersion = &version.Info{Major: "1", GitVersion: gitVersion}
+	for gv, resources := range served {
+		list := &meta_v1.APIResourceList{GroupVersion: gv}
+		for _, r := range resources {
+			list.APIResources = append(list.APIResources, meta_v1.APIResource{Name: r})
+		}
+		d.Resources = append(d.Resources, list)
+	}
+	return d
+}
+
+func TestDiscoverCapabilities(t *testing.T) {
+	tests := []struct {
+		name    string
+		served  map[string][]string
+		hpa     string
+		pdb     string
+		cronJob string
+		err     bool
+	}{
+		{
+			name: "v1.20",
+			served: map[string][]string{
+				"autoscaling/v2beta2": {ResourceHPA},
+				"policy/v1beta1":      {ResourcePDB},
+				"batch/v1":            {"jobs"},
+				"batch/v1beta1":       {ResourceCronJob},
+			},
+			hpa: "autoscaling/v2beta2", pdb: "policy/v1beta1", cronJob: "batch/v1beta1",
+		},
+		{
+			name: "v1.22",
+			served: map[string][]string{
+				"autoscaling/v2beta2": {ResourceHPA},
+				"policy/v1":           {ResourcePDB},
+				"policy/v1beta1":      {ResourcePDB},
+				"batch/v1":            {"jobs", ResourceCronJob},
+				"batch/v1beta1":       {ResourceCronJob},
+			},
+			hpa: "autoscaling/v2beta2", pdb: "policy/v1", cronJob: "batch/v1",
+		},
+		{
+			name: "v1.26",
+			served: map[string][]string{
+				"autoscaling/v2": {ResourceHPA},
+				"policy/v1":      {ResourcePDB},
+				"batch/v1":       {"jobs", ResourceCronJob},
+			},
+			hpa: "autoscaling/v2", pdb: "policy/v1", cronJob: "batch/v1",
+		},
+		{
+			name: "autoscaling/v1 only",
+			served: map[string][]string{
+				"autoscaling/v1": {ResourceHPA},
+				"policy/v1":      {ResourcePDB},
+				"batch/v1":       {"jobs", ResourceCronJob},
+			},
+			err: true,
+		},
+		{
+			name: "no cron jobs",
+			served: map[string][]string{
+				"autoscaling/v2": {ResourceHPA},
+				"policy/v1":      {ResourcePDB},
+				"batch/v1":       {"jobs"},
+			},
+			err: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := DiscoverCapabilities(fakeDiscovery(test.name, test.served))
+			if test.err {
+				if err == nil {
+					t.Fatalf("Expected error, got %+v", c)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Version.GitVersion != test.name {
+				t.Fatalf("Unexpected version %+v", c.Version)
+			}
+			got := []string{c.APIVersion(ResourceHPA), c.APIVersion(ResourcePDB), c.APIVersion(ResourceCronJob)}
+			want := []string{test.hpa, test.pdb, test.cronJob}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("Expected %v, got %v", want, got)
+				}
+			}
+		})
+	}
+}
+
+func useTestCapabilities(t *testing.T, versions map[string]string) {
+	prev := ClusterCapabilities
+	t.Cleanup(func() { ClusterCapabilities = prev })
+	c := DefaultCapabilities()
+	for resource, v := range versions {
+		c.versions[resource] = v
+	}
+	ClusterCapabilities = c
+}
+
+func TestCronJobConversion(t *testing.T) {
+	v1Job := &batch_v1.CronJob{
+		TypeMeta:   meta_v1.TypeMeta{APIVersion: "batch/v1", Kind: "CronJob"},
+		ObjectMeta: meta_v1.ObjectMeta{Name: "nightly", Namespace: "ns"},
+		Spec: batch_v1.CronJobSpec{
+			Schedule: "0 3 * * *",
+			JobTemplate: batch_v1.JobTemplateSpec{Spec: batch_v1.JobSpec{Template: apiv1.PodTemplateSpec{
+				Spec: apiv1.PodSpec{Containers: []apiv1.Container{{Name: "main", Image: "train:1"}}},
+			}}},
+		},
+	}
+
+	useTestCapabilities(t, map[string]string{ResourceCronJob: "batch/v1beta1"})
+	converted, err := cronJob(v1Job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	beta := converted.(*batchv1beta1.CronJob)
+	if beta.APIVersion != "batch/v1beta1" || beta.Kind != "CronJob" || beta.Name != "nightly" ||
+		beta.Spec.Schedule != "0 3 * * *" || beta.Spec.JobTemplate.Spec.Template.Spec.Containers[0].Image != "train:1" {
+		t.Fatalf("Unexpected batch/v1beta1 cron job %+v", beta)
+	}
+	if same, _ := cronJob(beta); same != beta {
+		t.Fatal("Served version is converted")
+	}
+
+	useTestCapabilities(t, map[string]string{ResourceCronJob: "batch/v1"})
+	converted, err = cronJob(beta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back := converted.(*batch_v1.CronJob)
+	if back.APIVersion != "batch/v1" || back.Spec.Schedule != v1Job.Spec.Schedule ||
+		back.Spec.JobTemplate.Spec.Template.Spec.Containers[0].Image != "train:1" {
+		t.Fatalf("Unexpected batch/v1 cron job %+v", back)
+	}
+	if _, err := cronJob(&batch_v1.Job{}); err == nil {
+		t.Fatal("Expected error for job")
+	}
+}
+
+func TestApplyRawHPA(t *testing.T) {
+	minReplicas := int32(1)
+	hpa := &v2beta2.HorizontalPodAutoscaler{
+		TypeMeta:   meta_v1.TypeMeta{APIVersion: "autoscaling/v2beta2", Kind: "HorizontalPodAutoscaler"},
+		ObjectMeta: meta_v1.ObjectMeta{Name: "web", Namespace: "ns"},
+		Spec: v2beta2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: v2beta2.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"},
+			MinReplicas:    &minReplicas,
+			MaxReplicas:    3,
+		},
+	}
+	var requests []string
+	var body map[string]interface{}
+	client := &fakerest.RESTClient{
+		NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		Client: fakerest.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			requests = append(requests, req.Method+" "+req.URL.Path)
+			if req.Method == http.MethodGet {
+				return &http.Response{
+					StatusCode: http.StatusNotFound,
+					Header:     http.Header{"Content-Type": []string{"application/json"}},
+					Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`))),
+				}, nil
+			}
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				return nil, err
+			}
+			return &http.Response{
+				StatusCode: http.StatusCreated,
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte(`{}`))),
+			}, nil
+		}),
+	}
+	if err := applyRaw(client, "autoscaling/v2", ResourceHPA, hpa, "ns", "web"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"GET /apis/autoscaling/v2/namespaces/ns/horizontalpodautoscalers/web",
+		"POST /apis/autoscaling/v2/namespaces/ns/horizontalpodautoscalers",
+	}
+	if len(requests) != len(want) || requests[0] != want[0] || requests[1] != want[1] {
+		t.Fatalf("Expected requests %v, got %v", want, requests)
+	}
+	spec := body["spec"].(map[string]interface{})
+	if body["apiVersion"] != "autoscaling/v2" || body["kind"] != "HorizontalPodAutoscaler" ||
+		spec["maxReplicas"] != float64(3) || spec["minReplicas"] != float64(1) {
+		t.Fatalf("Unexpected autoscaling/v2 body %v", body)
+	}
+	// The object keeps its version.
+	if hpa.APIVersion != "autoscaling/v2beta2" {
+		t.Fatalf("Object version is changed to %v", hpa.APIVersion)
+	}
+}
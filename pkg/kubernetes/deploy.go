@@ -14,9 +14,12 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	v2beta2 "k8s.io/api/autoscaling/v2beta2"
 	batch_v1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
 	api_v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	policyv1 "k8s.io/api/policy/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -57,6 +60,12 @@ func init() {
 	if err := v2beta2.AddToScheme(scheme.Scheme); err != nil {
 		panic(err)
 	}
+	if err := schedulingv1.AddToScheme(scheme.Scheme); err != nil {
+		panic(err)
+	}
+	if err := networkingv1.AddToScheme(scheme.Scheme); err != nil {
+		panic(err)
+	}
 }
 
 func GetTemplate(tpl string, vars interface{}) (string, error) {
@@ -174,12 +183,33 @@ func applyResource(kubeClient *kubernetes.Clientset, resource *KubeResource) err
 			_, err := kubeClient.CoreV1().ConfigMaps(v.Namespace).Update(context.TODO(), v, meta_v1.UpdateOptions{})
 			return err
 		}
-	case *policyv1.PodDisruptionBudget:
-		if _, err := kubeClient.PolicyV1().PodDisruptionBudgets(v.Namespace).Get(context.TODO(), v.Name, meta_v1.GetOptions{}); err != nil {
-			_, err := kubeClient.PolicyV1().PodDisruptionBudgets(v.Namespace).Create(context.TODO(), v, meta_v1.CreateOptions{})
+	case *schedulingv1.PriorityClass:
+		if _, err := kubeClient.SchedulingV1().PriorityClasses().Get(context.TODO(), v.Name, meta_v1.GetOptions{}); err != nil {
+			_, err := kubeClient.SchedulingV1().PriorityClasses().Create(context.TODO(), v, meta_v1.CreateOptions{})
 			return err
 		}
+		// Value and preemption policy of priority class are immutable.
 		return nil
+	case *api_v1.ResourceQuota:
+		if _, err := kubeClient.CoreV1().ResourceQuotas(v.Namespace).Get(context.TODO(), v.Name, meta_v1.GetOptions{}); err != nil {
+			_, err := kubeClient.CoreV1().ResourceQuotas(v.Namespace).Create(context.TODO(), v, meta_v1.CreateOptions{})
+			return err
+		} else {
+			_, err := kubeClient.CoreV1().ResourceQuotas(v.Namespace).Update(context.TODO(), v, meta_v1.UpdateOptions{})
+			return err
+		}
+	case *api_v1.LimitRange:
+		if _, err := kubeClient.CoreV1().LimitRanges(v.Namespace).Get(context.TODO(), v.Name, meta_v1.GetOptions{}); err != nil {
+			_, err := kubeClient.CoreV1().LimitRanges(v.Namespace).Create(context.TODO(), v, meta_v1.CreateOptions{})
+			return err
+		} else {
+			_, err := kubeClient.CoreV1().LimitRanges(v.Namespace).Update(context.TODO(), v, meta_v1.UpdateOptions{})
+			return err
+		}
+	case *policyv1.PodDisruptionBudget:
+		return applyPDB(kubeClient, v)
+	case *batch_v1.CronJob, *batchv1beta1.CronJob:
+		return applyCronJob(kubeClient, v)
 	case *api_v1.Secret:
 		if _, err := kubeClient.CoreV1().Secrets(v.Namespace).Get(context.TODO(), v.Name, meta_v1.GetOptions{}); err != nil {
 			_, err := kubeClient.CoreV1().Secrets(v.Namespace).Create(context.TODO(), v, meta_v1.CreateOptions{})
@@ -199,14 +229,7 @@ func applyResource(kubeClient *kubernetes.Clientset, resource *KubeResource) err
 	case *appsv1.Deployment:
 		return waitAndApply(kubeClient, v)
 	case *v2beta2.HorizontalPodAutoscaler:
-		if old, err := kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers(v.Namespace).Get(context.TODO(), v.Name, meta_v1.GetOptions{}); err != nil {
-			_, err := kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers(v.Namespace).Create(context.TODO(), v, meta_v1.CreateOptions{})
-			return err
-		} else {
-			old.Labels = v.Labels
-			_, err := kubeClient.AutoscalingV2beta2().HorizontalPodAutoscalers(v.Namespace).Update(context.TODO(), v, meta_v1.UpdateOptions{})
-			return err
-		}
+		return applyHPA(kubeClient, v)
 	case *api_v1.Service:
 		if old, err := kubeClient.CoreV1().Services(v.Namespace).Get(context.TODO(), v.Name, meta_v1.GetOptions{}); err != nil {
 			_, err := kubeClient.CoreV1().Services(v.Namespace).Create(context.TODO(), v, meta_v1.CreateOptions{})
@@ -218,6 +241,15 @@ func applyResource(kubeClient *kubernetes.Clientset, resource *KubeResource) err
 			_, err := kubeClient.CoreV1().Services(v.Namespace).Update(context.TODO(), old, meta_v1.UpdateOptions{})
 			return err
 		}
+	case *networkingv1.Ingress:
+		if old, err := kubeClient.NetworkingV1().Ingresses(v.Namespace).Get(context.TODO(), v.Name, meta_v1.GetOptions{}); err != nil {
+			_, err := kubeClient.NetworkingV1().Ingresses(v.Namespace).Create(context.TODO(), v, meta_v1.CreateOptions{})
+			return err
+		} else {
+			v.ResourceVersion = old.ResourceVersion
+			_, err := kubeClient.NetworkingV1().Ingresses(v.Namespace).Update(context.TODO(), v, meta_v1.UpdateOptions{})
+			return err
+		}
 	case *api_v1.ServiceAccount:
 		if _, err := kubeClient.CoreV1().ServiceAccounts(v.Namespace).Get(context.TODO(), v.Name, meta_v1.GetOptions{}); err != nil {
 			_, err := kubeClient.CoreV1().ServiceAccounts(v.Namespace).Create(context.TODO(), v, meta_v1.CreateOptions{})
@@ -325,11 +357,11 @@ func waitAndApply(client *kubernetes.Clientset, new *appsv1.Deployment) error {
 			}
 		}
 	}
-	if _, err := client.AppsV1().Deployments(new.Namespace).Get(context.TODO(), new.Name, meta_v1.GetOptions{}); err != nil {
+	if old, err := client.AppsV1().Deployments(new.Namespace).Get(context.TODO(), new.Name, meta_v1.GetOptions{}); err != nil {
 		_, err := client.AppsV1().Deployments(new.Namespace).Create(context.TODO(), new, meta_v1.CreateOptions{})
 		return err
 	} else {
-		_, err := client.AppsV1().Deployments(new.Namespace).Update(context.TODO(), new, meta_v1.UpdateOptions{})
+		_, err := client.AppsV1().Deployments(new.Namespace).Update(context.TODO(), keepScaledToZero(old, new), meta_v1.UpdateOptions{})
 		return err
 	}
 
@@ -372,8 +404,32 @@ func DeleteResource(kubeClient *kubernetes.Clientset, resource *KubeResource) er
 		if err := kubeClient.CoreV1().ConfigMaps(v.Namespace).Delete(context.TODO(), v.Name, meta_v1.DeleteOptions{PropagationPolicy: &propagation}); err != nil {
 			return err
 		}
+	case *schedulingv1.PriorityClass:
+		if err := kubeClient.SchedulingV1().PriorityClasses().Delete(context.TODO(), v.Name, meta_v1.DeleteOptions{PropagationPolicy: &propagation}); err != nil {
+			return err
+		}
+	case *api_v1.ResourceQuota:
+		if err := kubeClient.CoreV1().ResourceQuotas(v.Namespace).Delete(context.TODO(), v.Name, meta_v1.DeleteOptions{PropagationPolicy: &propagation}); err != nil {
+			return err
+		}
+	case *api_v1.LimitRange:
+		if err := kubeClient.CoreV1().LimitRanges(v.Namespace).Delete(context.TODO(), v.Name, meta_v1.DeleteOptions{PropagationPolicy: &propagation}); err != nil {
+			return err
+		}
 	case *policyv1.PodDisruptionBudget:
-		if err := kubeClient.PolicyV1beta1().PodDisruptionBudgets(v.Namespace).Delete(context.TODO(), v.Name, meta_v1.DeleteOptions{PropagationPolicy: &propagation}); err != nil {
+		if err := deletePDB(kubeClient, v.Namespace, v.Name, meta_v1.DeleteOptions{PropagationPolicy: &propagation}); err != nil {
+			return err
+		}
+	case *v2beta2.HorizontalPodAutoscaler:
+		if err := deleteHPA(kubeClient, v.Namespace, v.Name, meta_v1.DeleteOptions{PropagationPolicy: &propagation}); err != nil {
+			return err
+		}
+	case *batch_v1.CronJob:
+		if err := deleteCronJob(kubeClient, v.Namespace, v.Name, meta_v1.DeleteOptions{PropagationPolicy: &propagation}); err != nil {
+			return err
+		}
+	case *batchv1beta1.CronJob:
+		if err := deleteCronJob(kubeClient, v.Namespace, v.Name, meta_v1.DeleteOptions{PropagationPolicy: &propagation}); err != nil {
 			return err
 		}
 	case *api_v1.Secret:
@@ -392,6 +448,10 @@ func DeleteResource(kubeClient *kubernetes.Clientset, resource *KubeResource) er
 		if err := kubeClient.CoreV1().Services(v.Namespace).Delete(context.TODO(), v.Name, meta_v1.DeleteOptions{PropagationPolicy: &propagation}); err != nil {
 			return err
 		}
+	case *networkingv1.Ingress:
+		if err := kubeClient.NetworkingV1().Ingresses(v.Namespace).Delete(context.TODO(), v.Name, meta_v1.DeleteOptions{PropagationPolicy: &propagation}); err != nil {
+			return err
+		}
 	case *api_v1.ServiceAccount:
 		if err := kubeClient.CoreV1().ServiceAccounts(v.Namespace).Delete(context.TODO(), v.Name, meta_v1.DeleteOptions{PropagationPolicy: &propagation}); err != nil {
 			return err
@@ -405,19 +465,19 @@ func DeleteResource(kubeClient *kubernetes.Clientset, resource *KubeResource) er
 			return err
 		}
 	case *rbacv1.Role:
-		if err := kubeClient.RbacV1beta1().Roles(v.Namespace).Delete(context.TODO(), v.Name, meta_v1.DeleteOptions{PropagationPolicy: &propagation}); err != nil {
+		if err := kubeClient.RbacV1().Roles(v.Namespace).Delete(context.TODO(), v.Name, meta_v1.DeleteOptions{PropagationPolicy: &propagation}); err != nil {
 			return err
 		}
 	case *rbacv1.ClusterRole:
-		if err := kubeClient.RbacV1beta1().ClusterRoles().Delete(context.TODO(), v.Name, meta_v1.DeleteOptions{PropagationPolicy: &propagation}); err != nil {
+		if err := kubeClient.RbacV1().ClusterRoles().Delete(context.TODO(), v.Name, meta_v1.DeleteOptions{PropagationPolicy: &propagation}); err != nil {
 			return err
 		}
 	case *rbacv1.RoleBinding:
-		if err := kubeClient.RbacV1beta1().RoleBindings(v.Namespace).Delete(context.TODO(), v.Name, meta_v1.DeleteOptions{PropagationPolicy: &propagation}); err != nil {
+		if err := kubeClient.RbacV1().RoleBindings(v.Namespace).Delete(context.TODO(), v.Name, meta_v1.DeleteOptions{PropagationPolicy: &propagation}); err != nil {
 			return err
 		}
 	case *rbacv1.ClusterRoleBinding:
-		if err := kubeClient.RbacV1beta1().ClusterRoleBindings().Delete(context.TODO(), v.Name, meta_v1.DeleteOptions{PropagationPolicy: &propagation}); err != nil {
+		if err := kubeClient.RbacV1().ClusterRoleBindings().Delete(context.TODO(), v.Name, meta_v1.DeleteOptions{PropagationPolicy: &propagation}); err != nil {
 			return err
 		}
 	default:
@@ -0,0 +1,166 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var RolloutPollInterval = 2 * time.Second
+
+const revisionAnnotation = "deployment.kubernetes.io/revision"
+
+// fatalWaitingReasons are reasons of waiting containers which are not
+// resolved by waiting longer.
+var fatalWaitingReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ErrImagePull":               true,
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+}
+
+// RolloutStatus is progress of the Deployment update.
+type RolloutStatus struct {
+	Done              bool  `json:"done"`
+	Failed            bool  `json:"failed,omitempty"`
+	Replicas          int32 `json:"replicas"`
+	UpdatedReplicas   int32 `json:"updated_replicas"`
+	AvailableReplicas int32 `json:"available_replicas"`
+	// Why the rollout failed or is still in progress
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// DeploymentRolloutStatus returns rollout status of the deployment, pods
+// of the new revision are used to report why rollout doesn't progress.
+func DeploymentRolloutStatus(d *appsv1.Deployment, pods []apiv1.Pod) *RolloutStatus {
+	s := &RolloutStatus{
+		Replicas:          1,
+		UpdatedReplicas:   d.Status.UpdatedReplicas,
+		AvailableReplicas: d.Status.AvailableReplicas,
+	}
+	if d.Spec.Replicas != nil {
+		s.Replicas = *d.Spec.Replicas
+	}
+	if d.Generation > d.Status.ObservedGeneration {
+		s.Message = "Waiting for deployment spec update to be observed"
+		return s
+	}
+	for _, c := range d.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Reason == "ProgressDeadlineExceeded" {
+			s.Failed = true
+			s.Reason = c.Reason
+			s.Message = c.Message
+		}
+	}
+	if !s.Failed && s.UpdatedReplicas >= s.Replicas && d.Status.Replicas == s.UpdatedReplicas &&
+		s.AvailableReplicas >= s.UpdatedReplicas {
+		s.Done = true
+		return s
+	}
+	for _, pod := range pods {
+		reason, msg, fatal := podFailure(pod)
+		if reason == "" {
+			continue
+		}
+		s.Reason = reason
+		s.Message = fmt.Sprintf("Pod %v: %v", pod.Name, msg)
+		s.Failed = s.Failed || fatal
+		return s
+	}
+	if !s.Failed {
+		switch {
+		case s.UpdatedReplicas < s.Replicas:
+			s.Message = fmt.Sprintf("%v of %v replicas updated", s.UpdatedReplicas, s.Replicas)
+		case d.Status.Replicas > s.UpdatedReplicas:
+			s.Message = fmt.Sprintf("%v old replicas are pending termination", d.Status.Replicas-s.UpdatedReplicas)
+		default:
+			s.Message = fmt.Sprintf("%v of %v updated replicas are available", s.AvailableReplicas, s.UpdatedReplicas)
+		}
+	}
+	return s
+}
+
+// podFailure returns reason the pod doesn't become ready and whether
+// it is fatal.
+func podFailure(pod apiv1.Pod) (reason string, message string, fatal bool) {
+	if isTerminating(pod) {
+		return "", "", false
+	}
+	statuses := append(append([]apiv1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, c := range statuses {
+		if w := c.State.Waiting; w != nil && fatalWaitingReasons[w.Reason] {
+			return w.Reason, fmt.Sprintf("container %v: %v", c.Name, w.Message), true
+		}
+	}
+	for _, c := range statuses {
+		if t := c.LastTerminationState.Terminated; t != nil && t.ExitCode != 0 {
+			return t.Reason, fmt.Sprintf("container %v exited with code %v: %v", c.Name, t.ExitCode, t.Message), false
+		}
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == apiv1.PodScheduled && c.Status == apiv1.ConditionFalse {
+			return c.Reason, c.Message, false
+		}
+	}
+	return "", "", false
+}
+
+// newRevisionPods returns pods of the current revision of the deployment.
+func newRevisionPods(ctx context.Context, client kubernetes.Interface, d *appsv1.Deployment) ([]apiv1.Pod, error) {
+	opts := labelSelector(d.Spec.Selector.MatchLabels)
+	sets, err := client.AppsV1().ReplicaSets(d.Namespace).List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	hash := ""
+	for _, rs := range sets.Items {
+		if metav1.IsControlledBy(&rs, d) && rs.Annotations[revisionAnnotation] == d.Annotations[revisionAnnotation] {
+			hash = rs.Labels[appsv1.DefaultDeploymentUniqueLabelKey]
+		}
+	}
+	if hash == "" {
+		return nil, nil
+	}
+	labels := map[string]string{appsv1.DefaultDeploymentUniqueLabelKey: hash}
+	for k, v := range d.Spec.Selector.MatchLabels {
+		labels[k] = v
+	}
+	pods, err := client.CoreV1().Pods(d.Namespace).List(ctx, labelSelector(labels))
+	if err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
+
+// WaitRollout waits until the deployment is updated or its rollout fails.
+// The last status is returned along with error if ctx is done first.
+func WaitRollout(ctx context.Context, client kubernetes.Interface, namespace, name string) (*RolloutStatus, error) {
+	ticker := time.NewTicker(RolloutPollInterval)
+	defer ticker.Stop()
+	for {
+		d, err := client.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		pods, err := newRevisionPods(ctx, client, d)
+		if err != nil {
+			return nil, err
+		}
+		status := DeploymentRolloutStatus(d, pods)
+		if status.Done || status.Failed {
+			return status, nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return status, ctx.Err()
+		}
+	}
+}
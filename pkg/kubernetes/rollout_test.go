@@ -0,0 +1,188 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var rolloutLabels = map[string]string{"app": "web"}
+
+func rolloutDeployment(mutate func(d *appsv1.Deployment)) *appsv1.Deployment {
+	replicas := int32(2)
+	d := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "web",
+			Namespace:   "ns",
+			UID:         "web-uid",
+			Generation:  2,
+			Annotations: map[string]string{revisionAnnotation: "2"},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: rolloutLabels},
+		},
+		Status: appsv1.DeploymentStatus{
+			ObservedGeneration: 2,
+			Replicas:           2,
+			UpdatedReplicas:    2,
+			AvailableReplicas:  2,
+		},
+	}
+	if mutate != nil {
+		mutate(d)
+	}
+	return d
+}
+
+func rolloutReplicaSet(d *appsv1.Deployment, revision, hash string) *appsv1.ReplicaSet {
+	labels := map[string]string{appsv1.DefaultDeploymentUniqueLabelKey: hash}
+	for k, v := range rolloutLabels {
+		labels[k] = v
+	}
+	return &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Name:            d.Name + "-" + hash,
+		Namespace:       d.Namespace,
+		Labels:          labels,
+		Annotations:     map[string]string{revisionAnnotation: revision},
+		OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(d, appsv1.SchemeGroupVersion.WithKind("Deployment"))},
+	}}
+}
+
+func rolloutPod(name, hash, waiting string) *apiv1.Pod {
+	labels := map[string]string{appsv1.DefaultDeploymentUniqueLabelKey: hash}
+	for k, v := range rolloutLabels {
+		labels[k] = v
+	}
+	pod := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns", Labels: labels}}
+	if waiting != "" {
+		pod.Status.ContainerStatuses = []apiv1.ContainerStatus{{
+			Name:  "main",
+			State: apiv1.ContainerState{Waiting: &apiv1.ContainerStateWaiting{Reason: waiting, Message: "back-off"}},
+		}}
+	}
+	return pod
+}
+
+func TestWaitRollout(t *testing.T) {
+	interval := RolloutPollInterval
+	defer func() { RolloutPollInterval = interval }()
+	RolloutPollInterval = 10 * time.Millisecond
+
+	inProgress := func(d *appsv1.Deployment) {
+		d.Status.UpdatedReplicas = 1
+		d.Status.AvailableReplicas = 1
+		d.Status.Replicas = 3
+	}
+	tests := []struct {
+		name       string
+		deployment *appsv1.Deployment
+		pods       []runtime.Object
+		done       bool
+		failed     bool
+		reason     string
+		message    string
+	}{
+		{
+			name:       "done",
+			deployment: rolloutDeployment(nil),
+			done:       true,
+		},
+		{
+			name: "progress deadline exceeded",
+			deployment: rolloutDeployment(func(d *appsv1.Deployment) {
+				inProgress(d)
+				d.Status.Conditions = []appsv1.DeploymentCondition{{
+					Type:    appsv1.DeploymentProgressing,
+					Status:  apiv1.ConditionFalse,
+					Reason:  "ProgressDeadlineExceeded",
+					Message: `ReplicaSet "web-new" has timed out progressing.`,
+				}}
+			}),
+			failed:  true,
+			reason:  "ProgressDeadlineExceeded",
+			message: `ReplicaSet "web-new" has timed out progressing.`,
+		},
+		{
+			name:       "crash loop of new revision",
+			deployment: rolloutDeployment(inProgress),
+			pods: []runtime.Object{
+				rolloutPod("web-old-1", "old", "CrashLoopBackOff"),
+				rolloutPod("web-new-1", "new", ""),
+				rolloutPod("web-new-2", "new", "CrashLoopBackOff"),
+			},
+			failed:  true,
+			reason:  "CrashLoopBackOff",
+			message: "Pod web-new-2: container main: back-off",
+		},
+		{
+			name:       "crash loop of old revision",
+			deployment: rolloutDeployment(inProgress),
+			pods:       []runtime.Object{rolloutPod("web-old-1", "old", "CrashLoopBackOff")},
+			message:    "1 of 2 replicas updated",
+		},
+		{
+			name: "generation is not observed",
+			deployment: rolloutDeployment(func(d *appsv1.Deployment) {
+				d.Generation = 3
+			}),
+			message: "Waiting for deployment spec update to be observed",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := test.deployment
+			objects := append([]runtime.Object{
+				d,
+				rolloutReplicaSet(d, "1", "old"),
+				rolloutReplicaSet(d, "2", "new"),
+			}, test.pods...)
+			client := fake.NewSimpleClientset(objects...)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			s, err := WaitRollout(ctx, client, "ns", "web")
+			if test.done || test.failed {
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else if err != context.DeadlineExceeded {
+				t.Fatalf("Expected wait until deadline, got %v", err)
+			}
+			if s.Done != test.done || s.Failed != test.failed {
+				t.Fatalf("Expected done=%v failed=%v, got %+v", test.done, test.failed, s)
+			}
+			if s.Reason != test.reason || s.Message != test.message {
+				t.Fatalf("Expected %q: %q, got %q: %q", test.reason, test.message, s.Reason, s.Message)
+			}
+		})
+	}
+}
+
+func TestDeploymentRolloutStatus(t *testing.T) {
+	// Old replicas are still running.
+	d := rolloutDeployment(func(d *appsv1.Deployment) { d.Status.Replicas = 3 })
+	s := DeploymentRolloutStatus(d, nil)
+	if s.Done || s.Message != "1 old replicas are pending termination" {
+		t.Fatalf("Unexpected status %+v", s)
+	}
+
+	// Restarting container is reported, but rollout may still succeed.
+	pod := rolloutPod("web-new-1", "new", "")
+	pod.Status.ContainerStatuses = []apiv1.ContainerStatus{{
+		Name: "main",
+		LastTerminationState: apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{
+			ExitCode: 1, Reason: "Error", Message: "failed",
+		}},
+	}}
+	s = DeploymentRolloutStatus(d, []apiv1.Pod{*pod})
+	if s.Failed || s.Reason != "Error" || s.Message != "Pod web-new-1: container main exited with code 1: failed" {
+		t.Fatalf("Unexpected status %+v", s)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/kuberlab/lib/pkg/types"
 	"github.com/kuberlab/lib/pkg/utils"
 	"github.com/sirupsen/logrus"
 	appsv1 "k8s.io/api/apps/v1"
@@ -17,10 +18,33 @@ import (
 )
 
 const (
-	ReasonInsufficient = "insufficient"
-	ReasonError        = "error"
+	ReasonInsufficient  = "insufficient"
+	ReasonError         = "error"
+	ReasonModelDownload = "model-download"
+	ReasonGitCommit     = "git-commit"
+	ReasonPreempted     = "preempted"
 )
 
+// GitCommitContainerPrefix is name prefix of containers committing task
+// outputs to git after the main container finished.
+const GitCommitContainerPrefix = "git-commit-"
+
+// GitCommitExitReasons maps exit codes of git commit containers to failure
+// reasons.
+var GitCommitExitReasons = map[int32]string{
+	44: "Failed commit task outputs",
+	45: "Failed push task outputs",
+}
+
+// ModelInitExitReasons maps exit codes of model download init container to
+// failure reasons.
+var ModelInitExitReasons = map[int32]string{
+	40: "Failed download model",
+	41: "Model checksum mismatch",
+	42: "Unsupported model archive format",
+	43: "Failed extract model archive",
+}
+
 const (
 	ResourceNvidiaGPU = "nvidia.com/gpu"
 )
@@ -40,6 +64,10 @@ type ResourceState struct {
 	Status    string                     `json:"status"`
 	Resources apiv1.ResourceRequirements `json:"resources,omitempty"`
 	Events    []Event                    `json:"events,omitempty"`
+	// Value of ml-node label the pod is scheduled to
+	MLNode     string       `json:"ml_node,omitempty"`
+	StartTime  *metav1.Time `json:"start_time,omitempty"`
+	FinishTime *metav1.Time `json:"finish_time,omitempty"`
 }
 
 type Event struct {
@@ -142,9 +170,28 @@ func DetermineResourceState(pod apiv1.Pod, client *kubernetes.Clientset) (reason
 		Status:    GetPodState(pod),
 		Events:    make([]Event, 0),
 		Resources: sumResourceRequests(pod),
+		MLNode:    pod.Spec.NodeSelector[types.KuberlabMLNodeLabel],
+		StartTime: pod.Status.StartTime,
+	}
+	if pod.Status.Phase == apiv1.PodSucceeded || pod.Status.Phase == apiv1.PodFailed {
+		resourceState.FinishTime = podFinishTime(pod)
+	}
+
+	// Main container may complete while outputs failed to be committed.
+	if event := gitCommitFailure(pod); event != nil {
+		resourceState.Status = "Error"
+		resourceState.Events = append(resourceState.Events, *event)
+		return event.Message, resourceState, ReasonGitCommit, nil
+	}
+
+	if event := preemption(pod); event != nil {
+		resourceState.Events = append(resourceState.Events, *event)
+		return event.Message, resourceState, ReasonPreempted, nil
 	}
 
-	if pod.Status.Phase == apiv1.PodRunning || pod.Status.Phase == apiv1.PodSucceeded || resourceState.Status == "Completed" {
+	// Preempted pods are deleted, scheduler reports it only by event.
+	if (pod.Status.Phase == apiv1.PodRunning || pod.Status.Phase == apiv1.PodSucceeded || resourceState.Status == "Completed") &&
+		pod.DeletionTimestamp == nil {
 		return
 	}
 
@@ -152,6 +199,15 @@ func DetermineResourceState(pod apiv1.Pod, client *kubernetes.Clientset) (reason
 	if err != nil {
 		return "", nil, "", err
 	}
+	for _, e := range events.Items {
+		if e.Reason == "Preempted" {
+			resourceState.Events = append(resourceState.Events, convertEvent(e))
+			return e.Message, resourceState, ReasonPreempted, nil
+		}
+	}
+	if pod.DeletionTimestamp != nil && (pod.Status.Phase == apiv1.PodRunning || pod.Status.Phase == apiv1.PodSucceeded) {
+		return
+	}
 
 	//resourceState.Events = events.Items
 
@@ -218,7 +274,8 @@ func DetermineResourceState(pod apiv1.Pod, client *kubernetes.Clientset) (reason
 				}
 				if terminated.ExitCode == 39 {
 					event.Reason = fmt.Sprintf("%v: %v", terminated.Message, terminated.Reason)
-					matches := gitRepoPattern.FindAllString(strings.Join(pod.Spec.InitContainers[i].Command, " "), -1)
+					// Repository is passed to init container through environment.
+					matches := gitRepoPattern.FindAllString(initContainerArgs(pod.Spec.InitContainers[i]), -1)
 					//repos := make([]string, 0)
 					//for _, groups := range matches {
 					//	if len(groups) > 1 {
@@ -227,6 +284,9 @@ func DetermineResourceState(pod apiv1.Pod, client *kubernetes.Clientset) (reason
 					//}
 					msg := "Failed get access to the repo(s): [" + strings.Join(matches, ",") + "]"
 					event.Message = msg
+				} else if msg, ok := ModelInitExitReasons[terminated.ExitCode]; ok {
+					event.Reason = terminated.Reason
+					event.Message = fmt.Sprintf("%v: %v", msg, initContainerEnv(pod.Spec.InitContainers[i], "MODEL_URL"))
 				} else {
 					event.Reason = terminated.Reason
 					event.Message = terminated.Message
@@ -234,6 +294,9 @@ func DetermineResourceState(pod apiv1.Pod, client *kubernetes.Clientset) (reason
 				resourceState.Events = append(resourceState.Events, event)
 				reason = event.Message
 				code = ReasonError
+				if _, ok := ModelInitExitReasons[terminated.ExitCode]; ok {
+					code = ReasonModelDownload
+				}
 			}
 
 		}
@@ -241,6 +304,120 @@ func DetermineResourceState(pod apiv1.Pod, client *kubernetes.Clientset) (reason
 	return
 }
 
+// podFinishTime returns the latest finish time of pod containers. Pods
+// failed without terminated containers (e.g. evicted) are finished when
+// they stopped being ready, or at start if it is unknown.
+func podFinishTime(pod apiv1.Pod) *metav1.Time {
+	var finish *metav1.Time
+	for _, status := range pod.Status.ContainerStatuses {
+		if t := status.State.Terminated; t != nil && (finish == nil || finish.Before(&t.FinishedAt)) {
+			finished := t.FinishedAt
+			finish = &finished
+		}
+	}
+	if finish != nil {
+		return finish
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == apiv1.PodReady && !c.LastTransitionTime.IsZero() {
+			finished := c.LastTransitionTime
+			return &finished
+		}
+	}
+	for _, c := range pod.Status.Conditions {
+		if !c.LastTransitionTime.IsZero() && (finish == nil || finish.Before(&c.LastTransitionTime)) {
+			finished := c.LastTransitionTime
+			finish = &finished
+		}
+	}
+	if finish == nil && pod.Status.StartTime != nil {
+		finished := *pod.Status.StartTime
+		finish = &finished
+	}
+	return finish
+}
+
+// preemption returns event if the pod was preempted by kubelet for
+// critical pod or marked as disruption target by scheduler preemption.
+func preemption(pod apiv1.Pod) *Event {
+	message := ""
+	if pod.Status.Reason == "Preempting" {
+		message = pod.Status.Message
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == "DisruptionTarget" && c.Status == apiv1.ConditionTrue &&
+			(c.Reason == "PreemptionByScheduler" || c.Reason == "PreemptionByKubeScheduler") {
+			message = c.Message
+		}
+	}
+	if message == "" && pod.Status.Reason != "Preempting" {
+		return nil
+	}
+	if message == "" {
+		message = "Preempted by pod with higher priority"
+	}
+	return &Event{
+		Reason:         "Preempted",
+		Message:        message,
+		Count:          1,
+		Type:           "Warning",
+		FirstTimestamp: metav1.Now(),
+		LastTimestamp:  metav1.Now(),
+		Source: apiv1.EventSource{
+			Component: "mlboard",
+		},
+	}
+}
+
+func gitCommitFailure(pod apiv1.Pod) *Event {
+	for _, status := range pod.Status.ContainerStatuses {
+		terminated := status.State.Terminated
+		if !strings.HasPrefix(status.Name, GitCommitContainerPrefix) || terminated == nil || terminated.ExitCode == 0 {
+			continue
+		}
+		msg, ok := GitCommitExitReasons[terminated.ExitCode]
+		if !ok {
+			msg = GitCommitExitReasons[44]
+		}
+		volume := status.Name
+		for _, c := range pod.Spec.Containers {
+			if c.Name == status.Name {
+				volume = initContainerEnv(c, "VOLUME_NAME")
+			}
+		}
+		return &Event{
+			Reason:         terminated.Reason,
+			Message:        fmt.Sprintf("%v: %v", msg, volume),
+			Count:          1,
+			Type:           "Warning",
+			FirstTimestamp: metav1.Now(),
+			LastTimestamp:  metav1.Now(),
+			Source: apiv1.EventSource{
+				Component: "mlboard",
+			},
+		}
+	}
+	return nil
+}
+
+func initContainerArgs(c apiv1.Container) string {
+	args := make([]string, 0, len(c.Command)+len(c.Env))
+	args = append(args, c.Command...)
+	for _, e := range c.Env {
+		args = append(args, e.Value)
+	}
+	return strings.Join(args, " ")
+}
+
+func initContainerEnv(c apiv1.Container, name string) string {
+	for _, e := range c.Env {
+		if e.Name == name {
+			return e.Value
+		}
+	}
+	return ""
+}
+
 func GetPodState(pod apiv1.Pod) string {
 	// Pod may be in Running phase even if the termination began already.
 	// So first check for terminating.
@@ -252,7 +429,7 @@ func GetPodState(pod apiv1.Pod) string {
 		return string(pod.Status.Phase)
 	}
 
-	containerState := pod.Status.ContainerStatuses[0].State
+	containerState := mainContainerStatus(pod).State
 	if pod.Status.Phase == apiv1.PodRunning && containerState.Terminated == nil && containerState.Waiting == nil {
 		return string(pod.Status.Phase)
 	}
@@ -267,6 +444,20 @@ func GetPodState(pod apiv1.Pod) string {
 	return string(pod.Status.Phase)
 }
 
+// mainContainerStatus returns status of the first container in spec.
+// Statuses are sorted by name, so it isn't necessarily the first one when
+// pod has more containers, e.g. git commit containers.
+func mainContainerStatus(pod apiv1.Pod) apiv1.ContainerStatus {
+	if len(pod.Spec.Containers) > 0 {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Name == pod.Spec.Containers[0].Name {
+				return status
+			}
+		}
+	}
+	return pod.Status.ContainerStatuses[0]
+}
+
 // isTerminating returns true if pod's DeletionTimestamp has been set
 func isTerminating(pod apiv1.Pod) bool {
 	return pod.DeletionTimestamp != nil
@@ -0,0 +1,76 @@
+package kubernetes
+
+import (
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodFinishTime(t *testing.T) {
+	start := metav1.NewTime(time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC))
+	at := func(d time.Duration) metav1.Time { return metav1.NewTime(start.Add(d)) }
+	terminated := func(name string, finish metav1.Time) apiv1.ContainerStatus {
+		return apiv1.ContainerStatus{Name: name, State: apiv1.ContainerState{
+			Terminated: &apiv1.ContainerStateTerminated{FinishedAt: finish},
+		}}
+	}
+	tests := []struct {
+		name   string
+		status apiv1.PodStatus
+		want   *metav1.Time
+	}{
+		{
+			name: "latest terminated container",
+			status: apiv1.PodStatus{
+				Phase:             apiv1.PodFailed,
+				StartTime:         &start,
+				ContainerStatuses: []apiv1.ContainerStatus{terminated("main", at(time.Hour)), terminated("git-commit-0", at(2*time.Hour))},
+			},
+			want: &metav1.Time{Time: start.Add(2 * time.Hour)},
+		},
+		{
+			name: "evicted",
+			status: apiv1.PodStatus{
+				Phase:     apiv1.PodFailed,
+				Reason:    "Evicted",
+				StartTime: &start,
+				Conditions: []apiv1.PodCondition{
+					{Type: apiv1.PodScheduled, Status: apiv1.ConditionTrue, LastTransitionTime: start},
+					{Type: apiv1.PodReady, Status: apiv1.ConditionFalse, LastTransitionTime: at(30 * time.Minute)},
+				},
+			},
+			want: &metav1.Time{Time: start.Add(30 * time.Minute)},
+		},
+		{
+			name: "latest condition",
+			status: apiv1.PodStatus{
+				Phase:     apiv1.PodFailed,
+				StartTime: &start,
+				Conditions: []apiv1.PodCondition{
+					{Type: apiv1.PodScheduled, Status: apiv1.ConditionTrue, LastTransitionTime: at(time.Minute)},
+					{Type: apiv1.PodInitialized, Status: apiv1.ConditionTrue, LastTransitionTime: at(5 * time.Minute)},
+				},
+			},
+			want: &metav1.Time{Time: start.Add(5 * time.Minute)},
+		},
+		{
+			name:   "start",
+			status: apiv1.PodStatus{Phase: apiv1.PodFailed, StartTime: &start},
+			want:   &start,
+		},
+		{
+			name:   "not started",
+			status: apiv1.PodStatus{Phase: apiv1.PodFailed},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := podFinishTime(apiv1.Pod{Status: test.status})
+			if (got == nil) != (test.want == nil) || (got != nil && !got.Equal(test.want)) {
+				t.Fatalf("Expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
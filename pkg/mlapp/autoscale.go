@@ -1,35 +1,149 @@
 package mlapp
 
 import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/kuberlab/lib/pkg/errors"
 	"github.com/kuberlab/lib/pkg/kubernetes"
 	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/api/autoscaling/v2beta2"
 	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const defaultIdleSeconds = 300
+
+func checkAutoscale(a *Autoscale, resName string) error {
+	if a == nil || !a.Enabled {
+		return nil
+	}
+	invalid := func(msg string) error {
+		return errors.NewStatusReason(
+			http.StatusBadRequest,
+			fmt.Sprintf("Invalid autoscale of resource %v: %v", resName, msg),
+			"Invalid autoscale",
+		)
+	}
+	if a.MinReplicas < 0 || a.MaxReplicas < 0 || (a.MaxReplicas > 0 && a.MinReplicas > a.MaxReplicas) {
+		return invalid("min_replicas must not exceed max_replicas")
+	}
+	if a.TargetAverageUtilization < 0 || a.TargetMemoryUtilization < 0 || a.TargetGPUUtilization < 0 {
+		return invalid("target utilization must not be negative")
+	}
+	for _, m := range a.PodMetrics {
+		if m.Name == "" || m.TargetAverageValue == nil || m.TargetValue != nil {
+			return invalid(fmt.Sprintf("pod metric '%v' requires name and target_average_value only", m.Name))
+		}
+	}
+	for _, m := range a.ExternalMetrics {
+		if m.Name == "" || (m.TargetValue == nil) == (m.TargetAverageValue == nil) {
+			return invalid(fmt.Sprintf("external metric '%v' requires name and either target_value or target_average_value", m.Name))
+		}
+	}
+	if a.ScaleToZero != nil && a.ScaleToZero.IdleSeconds < 0 {
+		return invalid("idle_seconds must not be negative")
+	}
+	return nil
+}
+
+// autoscaleMetrics returns metrics of the autoscaler. Utilization of
+// resources the container doesn't request is skipped.
+func (c *BoardConfig) autoscaleMetrics(deployment *appsv1.Deployment, autoscaleCfg *Autoscale) []v2beta2.MetricSpec {
+	container := deployment.Spec.Template.Spec.Containers[0]
+	custom := autoscaleCfg.TargetMemoryUtilization > 0 || autoscaleCfg.TargetGPUUtilization > 0 ||
+		len(autoscaleCfg.PodMetrics) > 0 || len(autoscaleCfg.ExternalMetrics) > 0
+
+	var metrics []v2beta2.MetricSpec
+	request := container.Resources.Requests.Cpu()
+	if (autoscaleCfg.TargetAverageUtilization > 0 || !custom) && request.MilliValue() != 0 {
+		var target int32
+		if autoscaleCfg.TargetAverageUtilization > 0 {
+			target = autoscaleCfg.TargetAverageUtilization
+		} else {
+			limit := container.Resources.Limits.Cpu()
+			if limit.MilliValue() != 0 {
+				// limit / request * 100 * 0.5
+				target = int32(float64(limit.MilliValue()) / float64(request.MilliValue()) * 100 * 0.5)
+			} else {
+				target = 50
+			}
+		}
+		metrics = append(metrics, resourceMetric(v1.ResourceCPU, target))
+	}
+	if autoscaleCfg.TargetMemoryUtilization > 0 && container.Resources.Requests.Memory().Value() != 0 {
+		metrics = append(metrics, resourceMetric(v1.ResourceMemory, autoscaleCfg.TargetMemoryUtilization))
+	}
+	if autoscaleCfg.TargetGPUUtilization > 0 && kubernetes.NvidiaGPU(&container.Resources.Limits).Value() > 0 {
+		target := resource.NewQuantity(int64(autoscaleCfg.TargetGPUUtilization), resource.DecimalSI)
+		metrics = append(metrics, v2beta2.MetricSpec{
+			Type: v2beta2.PodsMetricSourceType,
+			Pods: &v2beta2.PodsMetricSource{
+				Metric: v2beta2.MetricIdentifier{Name: c.Platform().GPUUtilizationMetric},
+				Target: v2beta2.MetricTarget{Type: v2beta2.AverageValueMetricType, AverageValue: target},
+			},
+		})
+	}
+	for _, m := range autoscaleCfg.PodMetrics {
+		metrics = append(metrics, v2beta2.MetricSpec{
+			Type: v2beta2.PodsMetricSourceType,
+			Pods: &v2beta2.PodsMetricSource{
+				Metric: metricIdentifier(m),
+				Target: v2beta2.MetricTarget{Type: v2beta2.AverageValueMetricType, AverageValue: m.TargetAverageValue},
+			},
+		})
+	}
+	for _, m := range autoscaleCfg.ExternalMetrics {
+		target := v2beta2.MetricTarget{Type: v2beta2.ValueMetricType, Value: m.TargetValue}
+		if m.TargetAverageValue != nil {
+			target = v2beta2.MetricTarget{Type: v2beta2.AverageValueMetricType, AverageValue: m.TargetAverageValue}
+		}
+		metrics = append(metrics, v2beta2.MetricSpec{
+			Type:     v2beta2.ExternalMetricSourceType,
+			External: &v2beta2.ExternalMetricSource{Metric: metricIdentifier(m), Target: target},
+		})
+	}
+	return metrics
+}
+
+func resourceMetric(name v1.ResourceName, target int32) v2beta2.MetricSpec {
+	return v2beta2.MetricSpec{
+		Type: v2beta2.ResourceMetricSourceType,
+		Resource: &v2beta2.ResourceMetricSource{
+			Name: name,
+			Target: v2beta2.MetricTarget{
+				Type:               v2beta2.UtilizationMetricType,
+				AverageUtilization: &target,
+			},
+		},
+	}
+}
+
+func metricIdentifier(m AutoscaleMetric) v2beta2.MetricIdentifier {
+	id := v2beta2.MetricIdentifier{Name: m.Name}
+	if len(m.Selector) > 0 {
+		id.Selector = &metav1.LabelSelector{MatchLabels: m.Selector}
+	}
+	return id
+}
+
+// generateHPA returns autoscaler of the deployment, nil if it has no
+// metrics to scale on.
 func (c *BoardConfig) generateHPA(deployment *appsv1.Deployment, autoscaleCfg *Autoscale) *kubernetes.KubeResource {
 	min := int32(1)
 	max := int32(5)
 
-	var target int32 = 0
 	if autoscaleCfg.MinReplicas > 0 {
 		min = autoscaleCfg.MinReplicas
 	}
 	if autoscaleCfg.MaxReplicas > 1 {
 		max = autoscaleCfg.MaxReplicas
 	}
-	if autoscaleCfg.TargetAverageUtilization > 0 {
-		target = autoscaleCfg.TargetAverageUtilization
-	} else {
-		limit := deployment.Spec.Template.Spec.Containers[0].Resources.Limits.Cpu()
-		request := deployment.Spec.Template.Spec.Containers[0].Resources.Requests.Cpu()
-		if limit.MilliValue() != 0 {
-			// limit / request * 100 * 0.5
-			target = int32(float64(limit.MilliValue()) / float64(request.MilliValue()) * 100 * 0.5)
-		} else {
-			target = 50
-		}
+	metrics := c.autoscaleMetrics(deployment, autoscaleCfg)
+	if len(metrics) == 0 {
+		return nil
 	}
 
 	hpa := &v2beta2.HorizontalPodAutoscaler{
@@ -50,17 +164,8 @@ func (c *BoardConfig) generateHPA(deployment *appsv1.Deployment, autoscaleCfg *A
 				Kind:       deployment.Kind,
 				Name:       deployment.Name,
 			},
-			Metrics: []v2beta2.MetricSpec{
-				{
-					Type: v2beta2.ResourceMetricSourceType,
-					Resource: &v2beta2.ResourceMetricSource{
-						Name: v1.ResourceCPU,
-						Target: v2beta2.MetricTarget{
-							AverageUtilization: &target,
-						},
-					},
-				},
-			},
+			Metrics:  metrics,
+			Behavior: autoscaleCfg.Behavior,
 		},
 	}
 
@@ -71,3 +176,25 @@ func (c *BoardConfig) generateHPA(deployment *appsv1.Deployment, autoscaleCfg *A
 		Kind:   &gv,
 	}
 }
+
+// setScaleToZero annotates the deployment to be scaled to zero by the
+// activator when idle. Current replicas are restored on activation. Requests
+// are routed to the activator by its caller, see kubernetes.Activator.
+func setScaleToZero(deployment *appsv1.Deployment, autoscaleCfg *Autoscale) {
+	if autoscaleCfg == nil || !autoscaleCfg.Enabled || autoscaleCfg.ScaleToZero == nil || !autoscaleCfg.ScaleToZero.Enabled {
+		return
+	}
+	idle := autoscaleCfg.ScaleToZero.IdleSeconds
+	if idle == 0 {
+		idle = defaultIdleSeconds
+	}
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil && *deployment.Spec.Replicas > 0 {
+		replicas = *deployment.Spec.Replicas
+	}
+	if deployment.Annotations == nil {
+		deployment.Annotations = make(map[string]string)
+	}
+	deployment.Annotations[kubernetes.ScaleToZeroIdleAnnotation] = strconv.Itoa(int(idle))
+	deployment.Annotations[kubernetes.ActivationReplicasAnnotation] = strconv.Itoa(int(replicas))
+}
@@ -0,0 +1,62 @@
+package mlapp
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/kuberlab/lib/pkg/dealerclient"
+	"github.com/kuberlab/lib/pkg/errors"
+	kuberlab "github.com/kuberlab/lib/pkg/kubernetes"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/api/autoscaling/v2beta2"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestAutoscale(t *testing.T) {
+	mem := resource.MustParse("1Gi")
+	rate := resource.MustParse("100")
+	window := int32(600)
+	serving := BoardModelServing{ModelServing: ModelServing{Uix: Uix{
+		Meta:  Meta{Name: "resnet"},
+		Ports: []Port{{Name: "http", Port: 9000, TargetPort: 9000, Protocol: "TCP"}},
+		Resource: Resource{
+			Images:    Images{CPU: "kuberlab/serving:latest"},
+			Resources: &ResourceRequest{Requests: &dealerclient.ResourceLimit{Memory: &mem}},
+			Autoscale: &Autoscale{
+				Enabled:                 true,
+				MaxReplicas:             4,
+				TargetMemoryUtilization: 80,
+				PodMetrics:              []AutoscaleMetric{{Name: "requests_per_second", TargetAverageValue: &rate}},
+				Behavior: &v2beta2.HorizontalPodAutoscalerBehavior{
+					ScaleDown: &v2beta2.HPAScalingRules{StabilizationWindowSeconds: &window},
+				},
+				ScaleToZero: &ScaleToZero{Enabled: true},
+			},
+		},
+	}}}
+	resources, err := GenerateModelServing(serving, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert(2, len(resources), t)
+	deploy := resources[0].Object.(*appsv1.Deployment)
+	Assert("300", deploy.Annotations[kuberlab.ScaleToZeroIdleAnnotation], t)
+	Assert("1", deploy.Annotations[kuberlab.ActivationReplicasAnnotation], t)
+
+	// CPU is not requested, so it is not scaled on.
+	hpa := resources[1].Object.(*v2beta2.HorizontalPodAutoscaler)
+	Assert(2, len(hpa.Spec.Metrics), t)
+	Assert("memory", string(hpa.Spec.Metrics[0].Resource.Name), t)
+	Assert(int32(80), *hpa.Spec.Metrics[0].Resource.Target.AverageUtilization, t)
+	Assert("requests_per_second", hpa.Spec.Metrics[1].Pods.Metric.Name, t)
+	Assert(window, *hpa.Spec.Behavior.ScaleDown.StabilizationWindowSeconds, t)
+
+	serving.Autoscale.TargetMemoryUtilization = 0
+	serving.Autoscale.PodMetrics = nil
+	resources, _ = GenerateModelServing(serving, false, nil)
+	Assert(1, len(resources), t)
+
+	serving.Autoscale.ExternalMetrics = []AutoscaleMetric{{Name: "queue_length"}}
+	_, err = GenerateModelServing(serving, false, nil)
+	Assert(http.StatusBadRequest, err.(*errors.Error).Status, t)
+}
@@ -11,13 +11,18 @@ import (
 	"strings"
 
 	"github.com/ghodss/yaml"
+	"github.com/kuberlab/lib/pkg/apputil"
 	"github.com/kuberlab/lib/pkg/dealerclient"
 	"github.com/kuberlab/lib/pkg/errors"
+	"github.com/kuberlab/lib/pkg/gitclient"
 	kuberlab "github.com/kuberlab/lib/pkg/kubernetes"
+	"github.com/kuberlab/lib/pkg/registry"
 	"github.com/kuberlab/lib/pkg/types"
 	"github.com/kuberlab/lib/pkg/utils"
 	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/api/autoscaling/v2beta2"
 	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
@@ -63,6 +68,20 @@ type BoardConfig struct {
 	BoardMetadata       Metadata `json:"board_metadata,omitempty"`
 	Config              `json:",inline"`
 	DeployResourceLabel string `json:"-"`
+	// Merge mounts of the same volume to the same path instead of failing
+	ResolveDuplicateMounts bool `json:"resolve_duplicate_mounts,omitempty"`
+	// Cluster specific settings, DefaultPlatformProfile is used if empty
+	PlatformProfile *PlatformProfile `json:"platform_profile,omitempty"`
+	// Resolver of git revisions, gitclient.DefaultResolver is used if nil
+	GitResolver *gitclient.Resolver `json:"-"`
+	// Pin image tags to digests at launch: always (tasks, servings and Uix),
+	// tasks or never (default)
+	ImagePinning string `json:"image_pinning,omitempty"`
+	// Resolver of image digests, registry.DefaultResolver is used if nil
+	ImageResolver *registry.Resolver `json:"-"`
+	// Lists dataset and model versions to resolve version constraints and
+	// model sources, dealer client is created from DealerAPI if nil
+	VersionLister VersionLister `json:"-"`
 }
 
 type Metadata struct {
@@ -71,17 +90,21 @@ type Metadata struct {
 
 func (c *BoardConfig) CheckResourceLimit(res Resource, resName string) error {
 	if c.BoardMetadata.Limits == nil {
-		return nil
+		return c.checkPriority(res, resName)
 	}
 	if c.BoardMetadata.Limits.Replicas > 0 {
 		if int64(res.Replicas) > c.BoardMetadata.Limits.Replicas {
-			return fmt.Errorf(
-				"Invalid replicas %v for resource %v: maximum allowed: %v",
-				res.Replicas, resName, c.BoardMetadata.Limits.Replicas,
+			return errors.NewStatusReason(
+				http.StatusForbidden,
+				fmt.Sprintf(
+					"Invalid replicas %v for resource %v: maximum allowed: %v",
+					res.Replicas, resName, c.BoardMetadata.Limits.Replicas,
+				),
+				"Replicas exceed workspace limit",
 			)
 		}
 	}
-	return nil
+	return c.checkPriority(res, resName)
 }
 
 func (c *BoardConfig) Type() string {
@@ -102,26 +125,11 @@ func (c *BoardConfig) GPURequests() int64 {
 }
 
 func (c *BoardConfig) DisableGPU(num int) int {
-	disabled := 0
-	remain := num
-	for i, ui := range c.Uix {
-		reqs := 0
-		if ui.Resources != nil {
-			reqs = int(ui.Resources.Accelerators.GPU)
-		}
-		// Disable component which has GPU request and fit to disable num.
-		if reqs >= remain && reqs > 0 {
-			// Disable it.
-			c.Uix[i].DisabledReason = GPUDisabledMessage
-			c.Uix[i].Disabled = true
-			remain -= reqs
-			disabled += reqs
-		}
-		if remain <= 0 {
-			break
-		}
-	}
-	return disabled
+	return GPUDowngrade{}.Disable([]ComponentRequests{c}, num)
+}
+
+func (c *BoardConfig) EnableGPU(num int) int {
+	return GPUDowngrade{}.Enable([]ComponentRequests{c}, num)
 }
 
 func (c *BoardConfig) CPUMiLimits() map[string]int64 {
@@ -311,13 +319,48 @@ type Resource struct {
 	DefaultMountPath string `json:"default_mount_path,omitempty"`
 	// Resource autoscaling settings
 	Autoscale *Autoscale `json:"autoscale,omitempty"`
+	// GPUs of component started on CPU image until GPU is available
+	GPUFallback uint `json:"gpu_fallback,omitempty"`
+	// Priority level of component pods, see PlatformProfile.PriorityClasses
+	Priority string `json:"priority,omitempty"`
 }
 
 type Autoscale struct {
-	Enabled                  bool  `json:"enabled,omitempty"`
-	MinReplicas              int32 `json:"min_replicas,omitempty"`
-	MaxReplicas              int32 `json:"max_replicas,omitempty"`
+	Enabled     bool  `json:"enabled,omitempty"`
+	MinReplicas int32 `json:"min_replicas,omitempty"`
+	MaxReplicas int32 `json:"max_replicas,omitempty"`
+	// Target CPU utilization, percent of requests. CPU is used by default
+	// if no other metric is set.
 	TargetAverageUtilization int32 `json:"target_average_utilization,omitempty"`
+	// Target memory utilization, percent of requests
+	TargetMemoryUtilization int32 `json:"target_memory_utilization,omitempty"`
+	// Target GPU utilization, percent. Requires GPU metric of the platform
+	// in custom metrics API.
+	TargetGPUUtilization int32 `json:"target_gpu_utilization,omitempty"`
+	// Metrics of the pods, e.g. request rate exported on the metrics port
+	PodMetrics []AutoscaleMetric `json:"pod_metrics,omitempty"`
+	// Metrics not related to the pods, e.g. length of a queue
+	ExternalMetrics []AutoscaleMetric `json:"external_metrics,omitempty"`
+	// Stabilization windows and scaling policies
+	Behavior *v2beta2.HorizontalPodAutoscalerBehavior `json:"behavior,omitempty"`
+	// Scale idle serving to zero replicas, it is activated on request
+	ScaleToZero *ScaleToZero `json:"scale_to_zero,omitempty"`
+}
+
+type AutoscaleMetric struct {
+	Name string `json:"name"`
+	// Labels of external metric
+	Selector map[string]string `json:"selector,omitempty"`
+	// Target value per pod
+	TargetAverageValue *resource.Quantity `json:"target_average_value,omitempty"`
+	// Target total value, only for external metrics
+	TargetValue *resource.Quantity `json:"target_value,omitempty"`
+}
+
+type ScaleToZero struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Seconds without requests before the serving is scaled to zero
+	IdleSeconds int32 `json:"idle_seconds,omitempty"`
 }
 
 func (r Resource) VolumeMounts(volumes []Volume, defaultMountPath string, defaultReadOnly bool) []VolumeMount {
@@ -384,6 +427,8 @@ type Uix struct {
 	Disabled       bool   `json:"disabled"`
 	DisabledReason string `json:"disabledReason,omitempty"`
 	SkipPrefix     bool   `json:"skipPrefix"`
+	// Update strategy of the Deployment
+	Rollout *Rollout `json:"rollout,omitempty"`
 }
 
 func (uix *Uix) Type() string {
@@ -441,9 +486,11 @@ func (s *Serving) MemoryMBLimits() map[string]int64 {
 }
 
 func (s *Serving) DisableGPU(num int) int {
-	s.Disabled = true
-	s.DisabledReason = GPUDisabledMessage
-	return int(s.GPURequests())
+	return GPUDowngrade{}.Disable([]ComponentRequests{s}, num)
+}
+
+func (s *Serving) EnableGPU(num int) int {
+	return GPUDowngrade{}.Enable([]ComponentRequests{s}, num)
 }
 
 type ServingModelSpec struct {
@@ -483,11 +530,15 @@ type ServingSpec struct {
 }
 
 type ServingSpecParam struct {
-	Name    string      `json:"name,omitempty"`
-	Type    string      `json:"type,omitempty"`
-	Label   string      `json:"label,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Type  string `json:"type,omitempty"`
+	Label string `json:"label,omitempty"`
+	// Default value
 	Value   interface{} `json:"value,omitempty"`
 	Options []string    `json:"options,omitempty"`
+	// Dimensions of array value, -1 is any size
+	Shape    []int `json:"shape,omitempty"`
+	Required bool  `json:"required,omitempty"`
 }
 
 type ServingResponseParam struct {
@@ -526,6 +577,8 @@ type Task struct {
 	DatasetRevisions []TaskRevision `json:"datasetRevisions,omitempty"`
 	// Revisions of models used for execution
 	ModelRevisions []TaskRevision `json:"modelRevisions,omitempty"`
+	// Commits of outputs pushed by task, see TaskResource.CommitGit
+	GitCommits []GitCommit `json:"gitCommits,omitempty"`
 }
 
 const (
@@ -538,6 +591,22 @@ func (t *Task) Type() string {
 	return KindTask
 }
 
+// revision returns revision of the volume requested by task, empty if task
+// is nil or doesn't specify it.
+func (t *Task) revision(volume string) string {
+	if t == nil {
+		return ""
+	}
+	for _, revs := range [][]TaskRevision{t.GitRevisions, t.DatasetRevisions, t.ModelRevisions} {
+		for _, rev := range revs {
+			if rev.VolumeName == volume {
+				return rev.Revision
+			}
+		}
+	}
+	return ""
+}
+
 func (t *Task) GPURequests() int64 {
 	var gpus int64 = 0
 	for _, r := range t.Resources {
@@ -574,8 +643,14 @@ func (t *Task) MemoryMBLimits() map[string]int64 {
 	return memoryMap
 }
 
+// DisableGPU moves task components to CPU images, components can't be
+// disabled during execution.
 func (t *Task) DisableGPU(num int) int {
-	return 0
+	return GPUDowngrade{CPUFallback: true}.Disable([]ComponentRequests{t}, num)
+}
+
+func (t *Task) EnableGPU(num int) int {
+	return GPUDowngrade{}.Enable([]ComponentRequests{t}, num)
 }
 
 type TaskRevision struct {
@@ -593,8 +668,10 @@ type TaskResource struct {
 	// Is it permanent component that should not stop execution until all other component will be finished
 	IsPermanent bool `json:"is_permanent,omitempty"`
 	// Port used for communication with other component inside tasks.
-	Port     int32 `json:"port,omitempty"`
-	Resource `json:",inline"`
+	Port int32 `json:"port,omitempty"`
+	// Git sources committed and pushed to new branch after successful run
+	CommitGit []string `json:"commitGit,omitempty"`
+	Resource  `json:",inline"`
 }
 
 type Images struct {
@@ -703,119 +780,86 @@ func (c *BoardConfig) LibVolume() (*v1.Volume, *v1.VolumeMount) {
 }
 
 func (c *BoardConfig) InjectRevisions(client *kubernetes.Clientset, task *Task) error {
-	if err := c.InjectGitRevisions(client, task); err != nil {
+	return c.InjectRevisionsContext(context.Background(), task)
+}
+
+func (c *BoardConfig) InjectRevisionsContext(ctx context.Context, task *Task) error {
+	if err := c.InjectGitRevisionsContext(ctx, task); err != nil {
 		return err
 	}
 	c.InjectDatasetRevisions(task)
 	c.InjectModelRevisions(task)
-	return nil
+	if err := c.ResolveRevisionConstraints(task); err != nil {
+		return err
+	}
+	return c.PinTaskImages(ctx, task)
 }
 
 type InitContainers struct {
 	Image   string
-	Command string
+	Command []string
+	Env     []v1.EnvVar
 	Name    string
 	Mounts  map[string]interface{}
 }
 
+func (i InitContainers) EnvSpec() map[string]interface{} {
+	return map[string]interface{}{
+		"env": i.Env,
+	}
+}
+
 func (c *BoardConfig) KubeInits(mounts []VolumeMount, task *Task, build *string) ([]InitContainers, error) {
 	var inits []InitContainers
 	added := map[string]bool{}
-	_, vmounts, err := c.getSecretVolumes(c.Secrets)
+	_, secretMounts, err := c.getSecretVolumes(c.Secrets)
 	if err != nil {
 		return nil, err
 	}
+	if step := c.helpersStep(); step != nil {
+		inits = append(inits, step.Container())
+	}
+	initImage := c.Platform().InitImage
 	for j, m := range mounts {
 		if _, ok := added[m.Name]; ok {
 			continue
 		}
 		added[m.Name] = true
 		v := c.volumeByName(m.Name)
-		id := v.CommonID()
 		if v == nil {
 			return nil, fmt.Errorf("Source '%s' not found", m.Name)
 		}
-		//if v.GitRepo != nil && v.GitRepo.AccountId != "" {
-		if v.GitRepo != nil {
-			// Skip for UIX with public repos.
-			//if task == nil && build == nil {
-			//	return []InitContainers{}, nil
-			//}
-			var cmd []string
-			repoName := getGitRepoName(v.GitRepo.Repository)
-			baseDir := fmt.Sprintf("/gitdata/%d", j)
-			repoDir := fmt.Sprintf("%v/%v", baseDir, repoName)
-			//if v.GitRepo.AccountId == "" {
-			// If already cloned.
-			//cmd = append(cmd, fmt.Sprintf("cd %v", repoDir))
-			//} else {
-			apnd := []string{
-				fmt.Sprintf("cd %v", baseDir),
-				fmt.Sprintf("git clone %v", v.GitRepo.Repository),
-				fmt.Sprintf("cd %v", repoDir),
-			}
-			cmd = append(cmd, apnd...)
-			//}
-
-			findRevision := func(volume string) string {
-				for _, rev := range task.GitRevisions {
-					if rev.VolumeName == volume {
-						return rev.Revision
-					}
-				}
-				return ""
-			}
-
-			if task != nil && findRevision(v.Name) != "" {
-				cmd = append(cmd, fmt.Sprintf("git checkout %s", findRevision(v.Name)))
-			} else if v.GitRepo.Revision != "" {
-				cmd = append(cmd, fmt.Sprintf("git checkout %s", v.GitRepo.Revision))
-			}
-			cmd = append(cmd, "git config --local user.name kuberlab-robot")
-			cmd = append(cmd, "git config --local user.email robot@kuberlab.com")
-
-			cmdStr := strings.Join(cmd, " && ")
-
-			vmounts = append(vmounts, v1.VolumeMount{
-				Name:      id,
-				MountPath: baseDir,
-				ReadOnly:  false,
-			})
-			// Raise 39 exit code for further analysis.
-			cmdStr += "; if [ $? -ne 0 ]; then exit 39; fi"
-
-			inits = append(inits, InitContainers{
-				Mounts: map[string]interface{}{
-					"volumeMounts": vmounts,
-				},
-				Name:    m.Name,
-				Image:   "kuberlab/board-init",
-				Command: fmt.Sprintf(`['sh', '-c', '%v']`, cmdStr),
-			})
+		p, err := volumeProvider(*v)
+		if err != nil {
+			return nil, err
 		}
-		if v.Model != nil {
-			baseDir := fmt.Sprintf("/model/%d", j)
-			vmounts = append(vmounts, v1.VolumeMount{
-				Name:      id,
-				MountPath: baseDir,
-				ReadOnly:  false,
-			})
-			inits = append(inits, InitContainers{
-				Name:  m.Name,
-				Image: "kuberlab/board-init",
-				Command: fmt.Sprintf(
-					`["/bin/sh", "-c", "mkdir -p %v; curl -L -o m.tar %v && tar -xvf m.tar -C %v"]`,
-					baseDir, v.Model.DownloadURL, baseDir,
-				),
-				Mounts: map[string]interface{}{"volumeMounts": vmounts},
-			})
+		steps, err := p.InitSteps(c, *v, VolumeInitContext{
+			Index:        j,
+			Name:         m.Name,
+			Revision:     task.revision(v.Name),
+			SecretMounts: secretMounts,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, step := range steps {
+			if step.Image == "" {
+				step.Image = initImage
+			}
+			inits = append(inits, step.Container())
 		}
-
 	}
 	return inits, nil
 }
+
+// getGitRepoName returns directory of the cloned repository: name of the
+// repository parsed from its URL, so the directory matches SubPath of
+// apputil.ParseGitURL, or the last segment of URL like git clone does.
 func getGitRepoName(repo string) string {
-	p := strings.Split(repo, "/")
+	if name := apputil.ParseGitURL(repo).Repo; name != "" {
+		return name
+	}
+	p := strings.Split(strings.TrimSuffix(repo, "/"), "/")
 	return strings.TrimSuffix(p[len(p)-1], ".git")
 }
 func (c *BoardConfig) KubeVolumesSpec(mounts []VolumeMount) ([]v1.Volume, []v1.VolumeMount, error) {
@@ -827,6 +871,9 @@ func (c *BoardConfig) KubeVolumesSpec(mounts []VolumeMount) ([]v1.Volume, []v1.V
 		if v == nil {
 			return nil, nil, fmt.Errorf("Source '%s' not found", m.Name)
 		}
+		if _, err := volumeProvider(*v); err != nil {
+			return nil, nil, err
+		}
 		if v.FlexVolume != nil {
 			if v.FlexVolume.SecretRef != nil && v.FlexVolume.SecretRef.Name != "" &&
 				!strings.HasPrefix(v.FlexVolume.SecretRef.Name, utils.KubeDeploymentEncode(c.Name)) {
@@ -843,25 +890,7 @@ func (c *BoardConfig) KubeVolumesSpec(mounts []VolumeMount) ([]v1.Volume, []v1.V
 		if len(m.MountPath) > 0 {
 			mountPath = m.MountPath
 		}
-		subPath := v.SubPath
-		if v.ClusterStorage != "" {
-			if !v.IsWorkspaceLocal && strings.HasPrefix(subPath, "/shared/") {
-				subPath = strings.TrimPrefix(subPath, "/")
-			} else if strings.HasPrefix(subPath, "/") {
-				subPath = strings.TrimPrefix(subPath, "/")
-				if len(subPath) > 0 {
-					subPath = c.Workspace + "/" + c.WorkspaceID + "/" + subPath
-				} else {
-					subPath = c.Workspace + "/" + c.WorkspaceID + "/" + c.Name
-				}
-			} else if len(subPath) > 0 {
-				subPath = c.Workspace + "/" + c.WorkspaceID + "/" + c.Name + "/" + subPath
-			} else {
-				subPath = c.Workspace + "/" + c.WorkspaceID + "/" + c.Name + "/" + v.Name
-			}
-		} else {
-			subPath = strings.TrimPrefix(subPath, "/")
-		}
+		subPath := c.volumeSubPath(v)
 		if len(m.SubPath) > 0 {
 			subPath = filepath.Join(subPath, m.SubPath)
 		}
@@ -889,26 +918,49 @@ func (c *BoardConfig) KubeVolumesSpec(mounts []VolumeMount) ([]v1.Volume, []v1.V
 	return kVolumes, kVolumesMount, nil
 }
 
+// volumeSubPath returns sub path of the volume itself, before component mount
+// sub path is applied. Cluster storage volumes are laid out as
+// workspace/workspaceID/project/...
+func (c *BoardConfig) volumeSubPath(v *Volume) string {
+	subPath := v.SubPath
+	if v.ClusterStorage == "" {
+		return strings.TrimPrefix(subPath, "/")
+	}
+	if !v.IsWorkspaceLocal && strings.HasPrefix(subPath, "/shared/") {
+		return strings.TrimPrefix(subPath, "/")
+	}
+	if strings.HasPrefix(subPath, "/") {
+		subPath = strings.TrimPrefix(subPath, "/")
+		if len(subPath) > 0 {
+			return c.Workspace + "/" + c.WorkspaceID + "/" + subPath
+		}
+		return c.Workspace + "/" + c.WorkspaceID + "/" + c.Name
+	}
+	if len(subPath) > 0 {
+		return c.Workspace + "/" + c.WorkspaceID + "/" + c.Name + "/" + subPath
+	}
+	return c.Workspace + "/" + c.WorkspaceID + "/" + c.Name + "/" + v.Name
+}
+
 func (c *BoardConfig) CleanUPVolumes() ([]v1.Volume, []v1.VolumeMount) {
 	added := make(map[string]bool)
 	kVolumes := make([]v1.Volume, 0)
 	kVolumesMount := make([]v1.VolumeMount, 0)
 	for _, v := range c.VolumesData {
-		subPath := v.SubPath
-		if v.ClusterStorage != "" {
-			if !strings.HasPrefix(subPath, "/") {
-				id := v.CommonID()
-				if _, ok := added[id]; !ok {
-					added[id] = true
-					kVolumes = append(kVolumes, v.V1Volume())
-					kVolumesMount = append(kVolumesMount, v1.VolumeMount{
-						Name:      id,
-						SubPath:   c.Workspace + "/" + c.WorkspaceID,
-						MountPath: "/kuberlab/" + id,
-						ReadOnly:  false,
-					})
-				}
-			}
+		subPath, ok := v.provider().CleanupSubPath(c, v)
+		if !ok {
+			continue
+		}
+		id := v.CommonID()
+		if _, ok := added[id]; !ok {
+			added[id] = true
+			kVolumes = append(kVolumes, v.V1Volume())
+			kVolumesMount = append(kVolumesMount, v1.VolumeMount{
+				Name:      id,
+				SubPath:   subPath,
+				MountPath: "/kuberlab/" + id,
+				ReadOnly:  false,
+			})
 		}
 	}
 	return kVolumes, kVolumesMount
@@ -951,25 +1003,11 @@ func (c *BoardConfig) getSecretVolumes(secrets []Secret) ([]v1.Volume, []v1.Volu
 			})
 		}
 	}
-	// Need curl https://storage.googleapis.com/pluk/kdataset-linux -o /usr/bin/kdataset on all nodes
+	// With hostPath delivery need curl https://storage.googleapis.com/pluk/kdataset-linux -o /usr/bin/kdataset on all nodes
 	if c.Kind == KindServing {
 		// Ignore additional volumes for serving from model.
 		return kvolumes, kvolumesMount, nil
 	}
-	kvolumesMount = append(kvolumesMount, v1.VolumeMount{
-		Name:      "kdataset",
-		MountPath: "/usr/bin/kdataset",
-		ReadOnly:  true,
-		SubPath:   "kdataset",
-	})
-	kvolumes = append(kvolumes, v1.Volume{
-		Name: "kdataset",
-		VolumeSource: v1.VolumeSource{
-			HostPath: &v1.HostPathVolumeSource{
-				Path: "/usr/bin/",
-			},
-		},
-	})
 	kvolumesMount = append(kvolumesMount, v1.VolumeMount{
 		Name:      "kuberlab-config",
 		MountPath: "/root/.kuberlab/config",
@@ -986,36 +1024,9 @@ func (c *BoardConfig) getSecretVolumes(secrets []Secret) ([]v1.Volume, []v1.Volu
 		},
 	})
 
-	kvolumesMount = append(kvolumesMount, v1.VolumeMount{
-		Name:      "tf-conf",
-		MountPath: "/usr/bin/tf_conf",
-		ReadOnly:  true,
-		SubPath:   "tf_conf",
-	})
-	kvolumes = append(kvolumes, v1.Volume{
-		Name: "tf-conf",
-		VolumeSource: v1.VolumeSource{
-			HostPath: &v1.HostPathVolumeSource{
-				Path: "/usr/bin/",
-			},
-		},
-	})
-
-	kvolumesMount = append(kvolumesMount, v1.VolumeMount{
-		Name:      "mlboardclient",
-		MountPath: kibernetikaPythonLibs,
-		ReadOnly:  true,
-	})
-	dirOrCreate := v1.HostPathDirectoryOrCreate
-	kvolumes = append(kvolumes, v1.Volume{
-		Name: "mlboardclient",
-		VolumeSource: v1.VolumeSource{
-			HostPath: &v1.HostPathVolumeSource{
-				Path: kibernetikaPythonLibs,
-				Type: &dirOrCreate,
-			},
-		},
-	})
+	helperVols, helperMounts := c.helperVolumes()
+	kvolumes = append(kvolumes, helperVols...)
+	kvolumesMount = append(kvolumesMount, helperMounts...)
 
 	return kvolumes, kvolumesMount, nil
 }
@@ -1037,7 +1048,7 @@ func (c *BoardConfig) DockerSecretNames() []string {
 func (c *BoardConfig) generateKuberlabConfig() *kuberlab.KubeResource {
 	config := &v1.ConfigMap{
 		Data: map[string]string{
-			"config": "pluk_url: 'http://pluk.kuberlab.svc.cluster.local:8082'\n",
+			"config": fmt.Sprintf("pluk_url: '%v'\n", c.Platform().PlukURL),
 		},
 		TypeMeta: meta_v1.TypeMeta{
 			Kind:       "ConfigMap",
@@ -1125,6 +1136,10 @@ func (c *BoardConfig) GetSecretName(secret Secret) string {
 	return utils.KubePodNameEncode(c.Name + "-" + secret.Name)
 }
 
+func (c *BoardConfig) workspaceSecretName() string {
+	return utils.KubePodNameEncode(fmt.Sprintf("%v-ws-key-%v", c.Name, c.WorkspaceID))
+}
+
 func (c *BoardConfig) GetWorkspaceSecret() string {
 	name := fmt.Sprintf("ws-key-%v", c.WorkspaceID)
 	for _, s := range c.Secrets {
@@ -1,69 +1,46 @@
 package mlapp
 
 import (
+	"context"
 	"fmt"
-	"io/ioutil"
-	"os"
-	"os/exec"
+	"sort"
 	"strings"
 
+	"github.com/kuberlab/lib/pkg/gitclient"
 	"github.com/sirupsen/logrus"
-	"github.com/pborman/uuid"
 	"k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
+// setRevisions applies revisions requested by task to rendered volumes.
 func (c *BoardConfig) setRevisions(volumes []v1.Volume, task Task) {
-	c.setGitRevisions(volumes, task)
-	c.setPlukeRevisions(volumes, task)
-}
-
-func (c *BoardConfig) setPlukeRevisions(volumes []v1.Volume, task Task) {
 	setRevision := func(vName string, rev string) {
 		fromConfig := c.volumeByName(vName)
 		if fromConfig == nil {
 			return
 		}
+		p := fromConfig.provider()
 		for i, v := range volumes {
-			if v.Name == fromConfig.CommonID() && v.FlexVolume != nil && v.FlexVolume.Options["kuberlabFS"] == "plukefs" {
-				volumes[i].FlexVolume.Options["version"] = rev
+			if v.Name == fromConfig.CommonID() {
+				p.SetRevision(&volumes[i], rev)
 			}
 		}
 	}
-
-	for _, dsRev := range task.DatasetRevisions {
-		if dsRev.Revision != "" {
-			setRevision(dsRev.VolumeName, dsRev.Revision)
-		}
-	}
-	for _, mdlRev := range task.ModelRevisions {
-		if mdlRev.Revision != "" {
-			setRevision(mdlRev.VolumeName, mdlRev.Revision)
-		}
-	}
-}
-
-func (c *BoardConfig) setGitRevisions(volumes []v1.Volume, task Task) {
-	setRevision := func(vName string, rev string) {
-		fromConfig := c.volumeByName(vName)
-		for i, v := range volumes {
-			if v.Name == fromConfig.CommonID() && v.GitRepo != nil {
-				volumes[i].GitRepo.Revision = rev
+	for _, revs := range [][]TaskRevision{task.GitRevisions, task.DatasetRevisions, task.ModelRevisions} {
+		for _, rev := range revs {
+			if rev.Revision != "" {
+				setRevision(rev.VolumeName, rev.Revision)
 			}
 		}
 	}
-
-	for _, gitRev := range task.GitRevisions {
-		if gitRev.Revision != "" {
-			setRevision(gitRev.VolumeName, gitRev.Revision)
-		}
-	}
 }
 
 type RepoInfo struct {
 	URL        string
 	Revision   string
 	PrivateKey string
+	UserName   string
+	Password   string
 }
 
 func (c *BoardConfig) existingRevisions(task Task) map[string]string {
@@ -100,7 +77,16 @@ func (c *BoardConfig) privateKeyFor(account string) string {
 	return ""
 }
 
+// DetermineGitSourceRevisions is DetermineGitSourceRevisionsContext without
+// cancellation. Client is not used.
 func (c *BoardConfig) DetermineGitSourceRevisions(client *kubernetes.Clientset, task Task) (map[string]string, error) {
+	return c.DetermineGitSourceRevisionsContext(context.Background(), task)
+}
+
+// DetermineGitSourceRevisionsContext resolves revisions of git sources used
+// by task. Repositories are resolved in parallel through GitResolver, errors
+// are returned per volume as GitRevisionErrors along with resolved revisions.
+func (c *BoardConfig) DetermineGitSourceRevisionsContext(ctx context.Context, task Task) (map[string]string, error) {
 	// First, collect all volumes to mount
 	// Also, determine what exactly need to get
 
@@ -154,66 +140,76 @@ func (c *BoardConfig) DetermineGitSourceRevisions(client *kubernetes.Clientset,
 			reposToDetect[v.Name] = &RepoInfo{
 				URL:        repo,
 				PrivateKey: pkey,
+				UserName:   v.GitRepo.UserName,
+				Password:   v.GitRepo.AccessToken,
 			}
 		}
 	}
 
-	// Get rid of public repos and detect them locally.
+	errs := make(GitRevisionErrors)
+	names := make([]string, 0, len(reposToDetect))
+	reqs := make([]gitclient.Request, 0, len(reposToDetect))
 	for k, v := range reposToDetect {
-		// Detect locally.
-		cmd := fmt.Sprintf("git ls-remote %v %v 2> /dev/null | head -1 | awk '{print $1}' | xargs printf '%v %%s\\n'", v.URL, v.Revision, k)
-
-		if v.PrivateKey != "" {
-			keyFileName := uuid.New()
-			if err := ioutil.WriteFile(keyFileName, []byte(v.PrivateKey), 0600); err != nil {
-				return nil, err
-			}
-			cmd = fmt.Sprintf(`GIT_SSH_COMMAND='ssh -i %v -o StrictHostKeyChecking=no' %v`, keyFileName, cmd)
-			//noinspection GoDeferInLoop
-			defer os.Remove(keyFileName)
-		}
-
-		logrus.Infof("Exec locally: %v", cmd)
-		out, err := exec.Command("bash", "-c", cmd).CombinedOutput()
-		if err != nil {
-			return nil, fmt.Errorf("Failed detect git revisions: %v, %v", string(out), err)
+		// Local repositories are not allowed in user configs.
+		if err := ValidateGitURL(v.URL); err != nil {
+			errs[k] = err
+			continue
 		}
-		parseLogsAddRevs(out, res)
-		if _, ok := res[k]; ok {
-			delete(reposToDetect, k)
+		names = append(names, k)
+		reqs = append(reqs, gitclient.Request{
+			URL:      v.URL,
+			Revision: v.Revision,
+			Auth: &gitclient.Auth{
+				Username:   v.UserName,
+				Password:   v.Password,
+				PrivateKey: []byte(v.PrivateKey),
+				KnownHosts: []byte(c.Platform().GitKnownHosts),
+			},
+		})
+	}
+	resolver := c.GitResolver
+	if resolver == nil {
+		resolver = gitclient.DefaultResolver
+	}
+	for i, r := range resolver.ResolveAll(ctx, reqs) {
+		if r.Err != nil {
+			logrus.Errorf("Failed detect revision of %v: %v", reqs[i].URL, r.Err)
+			errs[names[i]] = r.Err
+			continue
 		}
+		res[names[i]] = r.Hash
 	}
-
-	if len(reposToDetect) == 0 {
-		return res, nil
+	if len(errs) > 0 {
+		return res, errs
 	}
-
 	return res, nil
 }
 
-func parseLogsAddRevs(logsRaw []byte, res map[string]string) {
-	logrus.Infof("Result: %v", string(logsRaw))
-	logs := strings.Split(string(logsRaw), "\n")
+// GitRevisionErrors holds errors of revision detection per volume.
+type GitRevisionErrors map[string]error
 
-	for _, l := range logs {
-		if l == "" {
-			continue
-		}
-		splitted := strings.Split(l, " ")
-		if len(splitted) != 2 {
-			continue
-		}
-		name := splitted[0]
-		rev := splitted[1]
-
-		res[name] = rev
+func (e GitRevisionErrors) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	msgs := make([]string, 0, len(e))
+	for _, name := range names {
+		msgs = append(msgs, fmt.Sprintf("%v: %v", name, e[name]))
 	}
+	return "Failed detect git revisions: " + strings.Join(msgs, "; ")
 }
 
 func (c *BoardConfig) InjectGitRevisions(client *kubernetes.Clientset, task *Task) error {
-	refs, err := c.DetermineGitSourceRevisions(client, *task)
-	if err != nil {
-		return err
+	return c.InjectGitRevisionsContext(context.Background(), task)
+}
+
+func (c *BoardConfig) InjectGitRevisionsContext(ctx context.Context, task *Task) error {
+	// Revisions detected for some of repos are injected even if others failed.
+	refs, detectErr := c.DetermineGitSourceRevisionsContext(ctx, *task)
+	if _, ok := detectErr.(GitRevisionErrors); detectErr != nil && !ok {
+		return detectErr
 	}
 	logrus.Infof("Revisions: %v", refs)
 
@@ -231,5 +227,5 @@ func (c *BoardConfig) InjectGitRevisions(client *kubernetes.Clientset, task *Tas
 			task.GitRevisions = append(task.GitRevisions, TaskRevision{Revision: ref, VolumeName: name})
 		}
 	}
-	return nil
+	return detectErr
 }
@@ -0,0 +1,180 @@
+package mlapp
+
+import (
+	stdjson "encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	kuberlab "github.com/kuberlab/lib/pkg/kubernetes"
+	"k8s.io/api/core/v1"
+)
+
+const (
+	postRunVolume = "kuberlab-post-run"
+	postRunDir    = "/kuberlab-post-run"
+
+	defaultCommitAuthor = "kuberlab-robot"
+	defaultCommitEmail  = "robot@kuberlab.com"
+)
+
+// Commit script waits for the main container, then commits changes of the
+// repository to the branch and pushes it with credentials used for clone.
+// Main container writes its pid on start and exit code on finish, the pod
+// shares process namespace, so the wait stops without commit if the main
+// container is killed before writing exit code. Failures raise exit codes
+// from kubernetes.GitCommitExitReasons. Pushed commit is reported through
+// termination message, see GitCommitsFromPod.
+const gitCommitScript = `while [ ! -f "$POST_RUN_DIR/exit-code" ]; do ` +
+	`if [ -f "$POST_RUN_DIR/pid" ] && [ ! -d "/proc/$(cat "$POST_RUN_DIR/pid")" ]; then ` +
+	`[ -f "$POST_RUN_DIR/exit-code" ] || exit 0; fi; sleep 5; done; ` +
+	`[ "$(cat "$POST_RUN_DIR/exit-code")" = 0 ] || exit 0; ` +
+	`cd "$REPO_DIR" || exit 44; ` +
+	`git config --global --add safe.directory "$REPO_DIR"; ` +
+	`git checkout -q -B "$COMMIT_BRANCH" && git add -A || exit 44; ` +
+	`if git diff --cached --quiet; then exit 0; fi; ` +
+	`git commit -q --author="$AUTHOR_NAME <$AUTHOR_EMAIL>" -m "$COMMIT_MESSAGE" || exit 44; ` +
+	`git push -q origin "HEAD:refs/heads/$COMMIT_BRANCH" || exit 45; ` +
+	`printf '{"volumeName":"%s","branch":"%s","revision":"%s"}' ` +
+	`"$VOLUME_NAME" "$COMMIT_BRANCH" "$(git rev-parse HEAD)" > /dev/termination-log`
+
+// GitCommit is a commit pushed by the task.
+type GitCommit struct {
+	VolumeName string `json:"volumeName"`
+	Branch     string `json:"branch"`
+	Revision   string `json:"revision"`
+}
+
+// CommitBranch returns branch for task outputs: Revision.NewBranch or name
+// built from the job ID.
+func (t *Task) CommitBranch(jobID string) string {
+	if t.Revision != nil && t.Revision.NewBranch != "" {
+		return t.Revision.NewBranch
+	}
+	return t.Name + "-" + jobID
+}
+
+func (t *Task) commitAuthor() (string, string) {
+	name, email := defaultCommitAuthor, defaultCommitEmail
+	if t.Revision == nil {
+		return name, email
+	}
+	if t.Revision.AuthorName != "" {
+		name = t.Revision.AuthorName
+	} else if t.Revision.Author != "" {
+		name = t.Revision.Author
+	}
+	if t.Revision.AuthorEmail != "" {
+		email = t.Revision.AuthorEmail
+	}
+	return name, email
+}
+
+func (t *Task) commitMessage(jobID string) string {
+	if t.Revision != nil && t.Revision.Comment != "" {
+		return t.Revision.Comment
+	}
+	return fmt.Sprintf("Outputs of task %v, job %v", t.Name, jobID)
+}
+
+// gitCommitSteps returns containers committing git sources listed in
+// CommitGit of the component after the main container finished.
+func (c *BoardConfig) gitCommitSteps(task Task, r TaskResource, jobID string) ([]InitContainers, error) {
+	if len(r.CommitGit) == 0 {
+		return nil, nil
+	}
+	if r.Replicas > 1 {
+		return nil, fmt.Errorf("Commit of outputs is supported only for single replica components")
+	}
+	branch := task.CommitBranch(jobID)
+	if err := ValidateGitRevision(branch); err != nil {
+		return nil, err
+	}
+	_, secretMounts, err := c.getSecretVolumes(c.Secrets)
+	if err != nil {
+		return nil, err
+	}
+	author, email := task.commitAuthor()
+	if strings.ContainsAny(author+email, "<>\n") {
+		return nil, fmt.Errorf("Invalid commit author '%v <%v>'", author, email)
+	}
+
+	// Index of mount must match clone init step, see KubeInits.
+	mounts := r.VolumeMounts(c.VolumesData, c.DefaultMountPath, c.DefaultReadOnly)
+	var steps []InitContainers
+	for _, name := range r.CommitGit {
+		index := -1
+		for j, m := range mounts {
+			if m.Name == name {
+				index = j
+				break
+			}
+		}
+		v := c.volumeByName(name)
+		if index < 0 || v == nil || v.GitRepo == nil {
+			return nil, fmt.Errorf("Can't commit '%v': component '%v' doesn't use git source '%v'", name, r.Name, name)
+		}
+		baseDir := fmt.Sprintf("/gitdata/%d", index)
+		ctx := VolumeInitContext{SecretMounts: secretMounts}
+		step := InitStep{
+			Name:   kuberlab.GitCommitContainerPrefix + strconv.Itoa(index),
+			Image:  c.Platform().InitImage,
+			Script: gitCommitScript,
+			Params: []InitParam{
+				{Name: "POST_RUN_DIR", Value: postRunDir},
+				{Name: "VOLUME_NAME", Value: name},
+				{Name: "REPO_DIR", Value: baseDir + "/" + getGitRepoName(v.GitRepo.Repository)},
+				{Name: "COMMIT_BRANCH", Value: branch},
+				{Name: "COMMIT_MESSAGE", Value: task.commitMessage(jobID)},
+				{Name: "AUTHOR_NAME", Value: author},
+				{Name: "AUTHOR_EMAIL", Value: email},
+			},
+			Mounts: append(ctx.MountsAt(v.CommonID(), baseDir), postRunMount()),
+		}
+		steps = append(steps, step.Container())
+	}
+	return steps, nil
+}
+
+func postRunMount() v1.VolumeMount {
+	return v1.VolumeMount{Name: postRunVolume, MountPath: postRunDir}
+}
+
+func postRunVolumeSpec() v1.Volume {
+	return v1.Volume{Name: postRunVolume, VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}}
+}
+
+// GitCommitsFromPod returns commits pushed by the pod.
+func GitCommitsFromPod(pod *v1.Pod) []GitCommit {
+	var commits []GitCommit
+	for _, status := range pod.Status.ContainerStatuses {
+		terminated := status.State.Terminated
+		if !strings.HasPrefix(status.Name, kuberlab.GitCommitContainerPrefix) || terminated == nil ||
+			terminated.ExitCode != 0 || terminated.Message == "" {
+			continue
+		}
+		commit := GitCommit{}
+		if err := stdjson.Unmarshal([]byte(terminated.Message), &commit); err != nil || commit.Revision == "" {
+			continue
+		}
+		commits = append(commits, commit)
+	}
+	return commits
+}
+
+// RecordGitCommits records commits on the task, previous commit of the same
+// volume is replaced.
+func (t *Task) RecordGitCommits(commits []GitCommit) {
+	for _, commit := range commits {
+		found := false
+		for i := range t.GitCommits {
+			if t.GitCommits[i].VolumeName == commit.VolumeName {
+				t.GitCommits[i] = commit
+				found = true
+			}
+		}
+		if !found {
+			t.GitCommits = append(t.GitCommits, commit)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package mlapp
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	kuberlab "github.com/kuberlab/lib/pkg/kubernetes"
+	"k8s.io/api/core/v1"
+)
+
+func TestGitCommitStep(t *testing.T) {
+	c := gitTaskConfig("https://github.com/kuberlab/lib")
+	task := gitTask("")
+	task.Revision = &Revision{NewBranch: "outputs", AuthorName: "Jane", AuthorEmail: "jane@example.com"}
+	task.Resources[0].CommitGit = []string{"src"}
+
+	specs, err := c.GenerateTaskResources(task, "5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := specs[0].Resource.Object.(*kuberlab.WorkerSet).PodTemplate
+	Assert(2, len(pod.Spec.Containers), t)
+	if !strings.Contains(pod.Spec.Containers[0].Args[0], "echo $code > "+postRunDir+"/exit-code;") {
+		t.Fatalf("Main container doesn't report exit code: %v", pod.Spec.Containers[0].Args[0])
+	}
+	if !strings.Contains(pod.Spec.Containers[0].Args[0], "echo $$ > "+postRunDir+"/pid;") {
+		t.Fatalf("Main container doesn't report pid: %v", pod.Spec.Containers[0].Args[0])
+	}
+	Assert(true, *pod.Spec.ShareProcessNamespace, t)
+	commit := pod.Spec.Containers[1]
+	Assert("git-commit-0", commit.Name, t)
+	env := map[string]string{}
+	for _, e := range commit.Env {
+		env[e.Name] = e.Value
+	}
+	Assert("outputs", env["COMMIT_BRANCH"], t)
+	Assert("Jane", env["AUTHOR_NAME"], t)
+	Assert("/gitdata/0/lib", env["REPO_DIR"], t)
+	// Repository is cloned and committed in the same directory.
+	Assert(pod.Spec.InitContainers[0].VolumeMounts, commit.VolumeMounts[:len(commit.VolumeMounts)-1], t)
+
+	task.Resources[0].Replicas = 2
+	if _, err = c.GenerateTaskResources(task, "5"); err == nil {
+		t.Fatal("Expected error for multiple replicas")
+	}
+	task.Resources[0].Replicas = 1
+	task.Resources[0].CommitGit = []string{"other"}
+	if _, err = c.GenerateTaskResources(task, "5"); err == nil {
+		t.Fatal("Expected error for unknown source")
+	}
+}
+
+func TestRecordGitCommits(t *testing.T) {
+	pod := &v1.Pod{Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{
+		{Name: "main", State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Message: "{}"}}},
+		{Name: "git-commit-0", State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{
+			Message: `{"volumeName":"src","branch":"train-5","revision":"abc"}`,
+		}}},
+		{Name: "git-commit-1", State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 45}}},
+	}}}
+	task := &Task{GitCommits: []GitCommit{{VolumeName: "src", Branch: "train-4", Revision: "old"}}}
+	task.RecordGitCommits(GitCommitsFromPod(pod))
+	Assert([]GitCommit{{VolumeName: "src", Branch: "train-5", Revision: "abc"}}, task.GitCommits, t)
+	Assert("train-5", (&Task{Meta: Meta{Name: "train"}}).CommitBranch("5"), t)
+}
+
+func TestGitCommitScriptMainKilled(t *testing.T) {
+	if _, err := os.Stat("/proc/self"); err != nil {
+		t.Skip("procfs is not available")
+	}
+	dir := t.TempDir()
+	// Process which already exited stands for the killed main container.
+	main := exec.Command("true")
+	if err := main.Run(); err != nil {
+		t.Fatal(err)
+	}
+	pid := []byte(strconv.Itoa(main.ProcessState.Pid()))
+	if err := ioutil.WriteFile(filepath.Join(dir, "pid"), pid, 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", gitCommitScript)
+	cmd.Env = []string{"POST_RUN_DIR=" + dir, "REPO_DIR=" + filepath.Join(dir, "missing")}
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Commit script didn't stop after the main container: %v", err)
+	}
+}
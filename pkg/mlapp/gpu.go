@@ -4,6 +4,196 @@ type ComponentRequests interface {
 	GPURequests() int64
 	MemoryMBLimits() map[string]int64
 	CPUMiLimits() map[string]int64
+	// Downgrades components to free at least num GPUs, returns freed GPUs
 	DisableGPU(num int) int
+	// Restores downgraded components using at most num GPUs, returns used GPUs
+	EnableGPU(num int) int
 	Type() string
 }
+
+var (
+	_ ComponentRequests = &BoardConfig{}
+	_ ComponentRequests = &Task{}
+	_ ComponentRequests = &Serving{}
+	_ ComponentRequests = &ModelServing{}
+)
+
+// gpuComponent is a component which GPUs may be freed independently.
+type gpuComponent struct {
+	res *Resource
+	// Nil for components which can't be disabled
+	uix *Uix
+}
+
+func (g gpuComponent) gpu() int {
+	if g.res.Resources == nil {
+		return 0
+	}
+	return int(g.res.Resources.Accelerators.GPU)
+}
+
+func (g gpuComponent) disabled() bool {
+	return g.uix != nil && g.uix.Disabled
+}
+
+// downgraded returns GPUs of component disabled or moved to CPU image by
+// GPUDowngrade.
+func (g gpuComponent) downgraded() int {
+	if g.res.GPUFallback > 0 {
+		return int(g.res.GPUFallback)
+	}
+	if g.uix != nil && g.uix.Disabled && g.uix.DisabledReason == GPUDisabledMessage {
+		return g.gpu()
+	}
+	return 0
+}
+
+func gpuComponents(r ComponentRequests) []gpuComponent {
+	var res []gpuComponent
+	switch c := r.(type) {
+	case *BoardConfig:
+		for i := range c.Uix {
+			res = append(res, gpuComponent{res: &c.Uix[i].Resource, uix: &c.Uix[i]})
+		}
+	case *Task:
+		for i := range c.Resources {
+			res = append(res, gpuComponent{res: &c.Resources[i].Resource})
+		}
+	case *Serving:
+		res = append(res, gpuComponent{res: &c.Uix.Resource, uix: &c.Uix})
+	case *ModelServing:
+		res = append(res, gpuComponent{res: &c.Uix.Resource, uix: &c.Uix})
+	}
+	return res
+}
+
+// GPUDowngrade frees GPUs used by components.
+type GPUDowngrade struct {
+	// Run components on CPU image instead of disabling if they have one.
+	CPUFallback bool
+}
+
+func (d GPUDowngrade) fallback(g gpuComponent) bool {
+	return (d.CPUFallback || g.uix == nil) && g.res.Images.CPU != ""
+}
+
+// Disable downgrades components so that at least num GPUs are freed with
+// the least GPUs over num. All candidates are downgraded if they can't free
+// num GPUs. Returns number of freed GPUs.
+func (d GPUDowngrade) Disable(components []ComponentRequests, num int) int {
+	if num <= 0 {
+		return 0
+	}
+	var candidates []gpuComponent
+	var gpus []int
+	for _, r := range components {
+		for _, g := range gpuComponents(r) {
+			if g.gpu() == 0 || g.disabled() || (g.uix == nil && !d.fallback(g)) {
+				continue
+			}
+			candidates = append(candidates, g)
+			gpus = append(gpus, g.gpu())
+		}
+	}
+	freed := 0
+	for _, i := range selectGPUs(gpus, num, true) {
+		g := candidates[i]
+		freed += gpus[i]
+		if d.fallback(g) {
+			g.res.GPUFallback = uint(gpus[i])
+			g.res.Resources.Accelerators.GPU = 0
+		} else {
+			g.uix.Disabled = true
+			g.uix.DisabledReason = GPUDisabledMessage
+		}
+	}
+	return freed
+}
+
+// Enable restores downgraded components using at most num GPUs, as much of
+// them as possible. Returns number of used GPUs.
+func (d GPUDowngrade) Enable(components []ComponentRequests, num int) int {
+	var candidates []gpuComponent
+	var gpus []int
+	for _, r := range components {
+		for _, g := range gpuComponents(r) {
+			if n := g.downgraded(); n > 0 {
+				candidates = append(candidates, g)
+				gpus = append(gpus, n)
+			}
+		}
+	}
+	used := 0
+	for _, i := range selectGPUs(gpus, num, false) {
+		g := candidates[i]
+		used += gpus[i]
+		if g.res.GPUFallback > 0 {
+			g.res.Resources.Accelerators.GPU = g.res.GPUFallback
+			g.res.GPUFallback = 0
+		} else {
+			g.uix.Disabled = false
+			g.uix.DisabledReason = ""
+		}
+	}
+	return used
+}
+
+// selectGPUs returns indexes of subset with the least sum not less than num
+// if atLeast is set, or with the greatest sum not greater than num otherwise.
+// Subset with fewer components is preferred for the same sum.
+func selectGPUs(gpus []int, num int, atLeast bool) []int {
+	total := 0
+	for _, g := range gpus {
+		total += g
+	}
+	if atLeast && total <= num {
+		all := make([]int, len(gpus))
+		for i := range gpus {
+			all[i] = i
+		}
+		return all
+	}
+	const unreachable = int(^uint(0) >> 1)
+	// count[i][s] is the least number of first i components with sum s.
+	count := make([][]int, len(gpus)+1)
+	for i := range count {
+		count[i] = make([]int, total+1)
+		for s := range count[i] {
+			count[i][s] = unreachable
+		}
+	}
+	count[0][0] = 0
+	for i, g := range gpus {
+		for s := 0; s <= total; s++ {
+			count[i+1][s] = count[i][s]
+			if s >= g && count[i][s-g] != unreachable && count[i][s-g]+1 < count[i+1][s] {
+				count[i+1][s] = count[i][s-g] + 1
+			}
+		}
+	}
+	n := len(gpus)
+	best := 0
+	if atLeast {
+		for s := num; s <= total; s++ {
+			if count[n][s] != unreachable {
+				best = s
+				break
+			}
+		}
+	} else {
+		for s := num; s > 0; s-- {
+			if s <= total && count[n][s] != unreachable {
+				best = s
+				break
+			}
+		}
+	}
+	var res []int
+	for i := n; i > 0 && best > 0; i-- {
+		if count[i][best] != count[i-1][best] {
+			res = append([]int{i - 1}, res...)
+			best -= gpus[i-1]
+		}
+	}
+	return res
+}
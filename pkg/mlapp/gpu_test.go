@@ -0,0 +1,65 @@
+package mlapp
+
+import (
+	"testing"
+)
+
+func gpuUix(name string, gpu uint, cpuImage string) Uix {
+	u := Uix{Resource: Resource{
+		Resources: &ResourceRequest{Accelerators: ResourceAccelerators{GPU: gpu}},
+		Images:    Images{CPU: cpuImage, GPU: "gpu-image"},
+	}}
+	u.Name = name
+	return u
+}
+
+func TestSelectGPUs(t *testing.T) {
+	Assert([]int{1}, selectGPUs([]int{8, 1, 2}, 1, true), t)
+	Assert([]int{0}, selectGPUs([]int{3, 2, 2}, 3, true), t)
+	Assert([]int{1, 2}, selectGPUs([]int{5, 2, 2}, 4, true), t)
+	Assert([]int{0, 1, 2}, selectGPUs([]int{1, 1, 1}, 5, true), t)
+	Assert([]int{1, 2}, selectGPUs([]int{8, 1, 2}, 4, false), t)
+	Assert([]int(nil), selectGPUs([]int{8}, 4, false), t)
+}
+
+func TestDisableEnableGPU(t *testing.T) {
+	c := &BoardConfig{}
+	c.Uix = []Uix{gpuUix("big", 8, "cpu-image"), gpuUix("small", 1, ""), gpuUix("cpu", 0, "")}
+	Assert(1, c.DisableGPU(1), t)
+	Assert(false, c.Uix[0].Disabled, t)
+	Assert(true, c.Uix[1].Disabled, t)
+	Assert(false, c.Uix[2].Disabled, t)
+	Assert(int64(8), c.GPURequests(), t)
+
+	Assert(0, c.EnableGPU(0), t)
+	Assert(1, c.EnableGPU(4), t)
+	Assert(false, c.Uix[1].Disabled, t)
+	Assert("", c.Uix[1].DisabledReason, t)
+
+	// Fallback to CPU image keeps component running.
+	Assert(8, GPUDowngrade{CPUFallback: true}.Disable([]ComponentRequests{c}, 2), t)
+	Assert(false, c.Uix[0].Disabled, t)
+	Assert("cpu-image", c.Uix[0].Image(), t)
+	Assert(uint(8), c.Uix[0].GPUFallback, t)
+	Assert(8, c.EnableGPU(8), t)
+	Assert("gpu-image", c.Uix[0].Image(), t)
+	Assert(uint(0), c.Uix[0].GPUFallback, t)
+
+	// Task components are moved to CPU only if they have CPU image.
+	task := &Task{Resources: []TaskResource{
+		{Resource: gpuUix("", 2, "").Resource},
+		{Resource: gpuUix("", 1, "cpu-image").Resource},
+	}}
+	Assert(1, task.DisableGPU(2), t)
+	Assert(uint(2), task.Resources[0].Resources.Accelerators.GPU, t)
+	Assert(uint(0), task.Resources[1].Resources.Accelerators.GPU, t)
+	Assert(1, task.EnableGPU(1), t)
+	Assert(uint(1), task.Resources[1].Resources.Accelerators.GPU, t)
+
+	// Selection is made across all components.
+	serving := &Serving{Uix: gpuUix("serving", 2, "")}
+	c.Uix = []Uix{gpuUix("big", 4, "")}
+	Assert(2, GPUDowngrade{}.Disable([]ComponentRequests{c, serving}, 2), t)
+	Assert(false, c.Uix[0].Disabled, t)
+	Assert(true, serving.Disabled, t)
+}
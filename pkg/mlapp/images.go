@@ -0,0 +1,114 @@
+package mlapp
+
+import (
+	"context"
+	"encoding/base64"
+	stdjson "encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/kuberlab/lib/pkg/registry"
+	"k8s.io/api/core/v1"
+)
+
+// Image pinning policies, see BoardConfig.ImagePinning.
+const (
+	PinImagesAlways = "always"
+	PinImagesTasks  = "tasks"
+	PinImagesNever  = "never"
+)
+
+func (c *BoardConfig) pinImagesFor(kind string) bool {
+	switch c.ImagePinning {
+	case PinImagesAlways:
+		return true
+	case PinImagesTasks:
+		return kind == KindTask
+	}
+	return false
+}
+
+func (c *BoardConfig) imageResolver() *registry.Resolver {
+	if c.ImageResolver != nil {
+		return c.ImageResolver
+	}
+	return registry.DefaultResolver
+}
+
+// registryKeychain returns credentials from dockerconfigjson secrets.
+func registryKeychain(secrets []Secret) registry.Keychain {
+	return func(host string) *registry.Credentials {
+		for _, s := range secrets {
+			if s.Type != string(v1.SecretTypeDockerConfigJson) {
+				continue
+			}
+			config := DockerConfig{}
+			if err := stdjson.Unmarshal([]byte(s.Data[v1.DockerConfigJsonKey]), &config); err != nil {
+				continue
+			}
+			for server, auth := range config.Auths {
+				if registry.NormalizeRegistry(server) == host {
+					return auth.credentials()
+				}
+			}
+		}
+		return nil
+	}
+}
+
+func (a AuthConfig) credentials() *registry.Credentials {
+	creds := &registry.Credentials{
+		Username:      a.Username,
+		Password:      a.Password,
+		IdentityToken: a.IdentityToken,
+		RegistryToken: a.RegistryToken,
+	}
+	if a.Auth != "" && creds.Username == "" {
+		if data, err := base64.StdEncoding.DecodeString(a.Auth); err == nil {
+			parts := strings.SplitN(string(data), ":", 2)
+			if len(parts) == 2 {
+				creds.Username, creds.Password = parts[0], parts[1]
+			}
+		}
+	}
+	return creds
+}
+
+func (c *BoardConfig) pinImages(ctx context.Context, images *Images, secrets []Secret) error {
+	keychain := registryKeychain(secrets)
+	for _, image := range []*string{&images.CPU, &images.GPU} {
+		if *image == "" {
+			continue
+		}
+		pinned, err := c.imageResolver().Pin(ctx, *image, keychain)
+		if err != nil {
+			return fmt.Errorf("Failed resolve digest of image '%s': %v", *image, err)
+		}
+		*image = pinned
+	}
+	return nil
+}
+
+// PinTaskImages replaces image tags of task components with digests
+// according to ImagePinning policy.
+func (c *BoardConfig) PinTaskImages(ctx context.Context, task *Task) error {
+	if !c.pinImagesFor(KindTask) {
+		return nil
+	}
+	for i := range task.Resources {
+		if err := c.pinImages(ctx, &task.Resources[i].Images, c.Secrets); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pinDeploymentImages pins images of servings and Uix if ImagePinning policy
+// is always. They are generated without context, so resolving is bounded
+// only by resolver timeout.
+func (c *BoardConfig) pinDeploymentImages(images *Images, secrets []Secret) error {
+	if !c.pinImagesFor(KindServing) {
+		return nil
+	}
+	return c.pinImages(context.Background(), images, secrets)
+}
@@ -0,0 +1,74 @@
+package mlapp
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/api/core/v1"
+)
+
+func TestPinTaskImages(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "user" || pass != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/v2/team/app/manifests/v1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", digest)
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	auth := base64.StdEncoding.EncodeToString([]byte("user:secret"))
+
+	c := gitTaskConfig("https://github.com/kuberlab/lib")
+	c.Secrets = []Secret{{
+		Name: "docker",
+		Type: string(v1.SecretTypeDockerConfigJson),
+		Data: map[string]string{
+			v1.DockerConfigJsonKey: fmt.Sprintf(`{"auths":{"http://%s":{"auth":"%s"}}}`, host, auth),
+		},
+	}}
+	task := gitTask("")
+	task.Resources[0].Images = Images{CPU: host + "/team/app:v1"}
+
+	// Never by default.
+	Assert(nil, c.PinTaskImages(context.Background(), &task), t)
+	Assert(host+"/team/app:v1", task.Resources[0].Images.CPU, t)
+
+	c.ImagePinning = PinImagesTasks
+	Assert(nil, c.PinTaskImages(context.Background(), &task), t)
+	Assert(host+"/team/app:v1@"+digest, task.Resources[0].Images.CPU, t)
+	Assert(task.Resources[0].Images.CPU, c.GenerateRunManifest(task, "1").Resources[0].ImageDigest, t)
+
+	// Servings are pinned only with always policy.
+	images := Images{CPU: host + "/team/app:v1"}
+	Assert(nil, c.pinDeploymentImages(&images, c.Secrets), t)
+	Assert(host+"/team/app:v1", images.CPU, t)
+	c.ImagePinning = PinImagesAlways
+	Assert(nil, c.pinDeploymentImages(&images, c.Secrets), t)
+	Assert(host+"/team/app:v1@"+digest, images.CPU, t)
+	c.Uix = []Uix{{Meta: Meta{Name: "ui"}, Resource: Resource{Images: Images{CPU: host + "/team/app:v1"}}}}
+	resources, err := c.GenerateUIXResources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	deploy := resources[len(resources)-1].Object.(*appsv1.Deployment)
+	Assert(host+"/team/app:v1@"+digest, deploy.Spec.Template.Spec.Containers[0].Image, t)
+	Assert(host+"/team/app:v1", c.Uix[0].Images.CPU, t)
+
+	task.Resources[0].Images = Images{CPU: host + "/team/missing:v1"}
+	if err := c.PinTaskImages(context.Background(), &task); err == nil {
+		t.Fatal("Expected error for missing image")
+	}
+}
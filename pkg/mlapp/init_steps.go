@@ -0,0 +1,333 @@
+package mlapp
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"k8s.io/api/core/v1"
+)
+
+const defaultInitImage = "kuberlab/board-init"
+
+// Init scripts are constant. Everything that comes from the user (repository
+// URL, revision, download URL) is passed to the container through environment
+// variables and is never interpolated into the script itself. Git script
+// raises 39 exit code on failure for further analysis in
+// kubernetes.DetermineResourceState.
+const (
+	gitCloneScript = `cd "$BASE_DIR" && ` +
+		`git clone -- "$REPO_URL" "$REPO_DIR" && ` +
+		`cd "$REPO_DIR" && ` +
+		`{ [ -z "$REPO_REVISION" ] || git checkout "$REPO_REVISION"; } && ` +
+		`git config --local user.name kuberlab-robot && ` +
+		`git config --local user.email robot@kuberlab.com; ` +
+		`if [ $? -ne 0 ]; then exit 39; fi`
+
+	// Model archive is downloaded to temporary directory with retries and
+	// resume, verified against checksum (if known) and extracted according to
+	// its format. Each failure has its own exit code, see
+	// kubernetes.ModelInitExitReasons. Workspace secret is given only for
+	// dealer URLs, redirects are not followed with it: redirect of the dealer
+	// is resolved first and its target is downloaded without the secret.
+	modelDownloadScript = `url="$MODEL_URL"
+follow=-L
+set --
+if [ -n "$WORKSPACE_SECRET" ]; then
+  set -- -H "X-Workspace-Name: $WORKSPACE_NAME" -H "X-Workspace-Secret: $WORKSPACE_SECRET"
+  follow=
+  redirect="$(curl -fsS --retry 3 --retry-delay 2 -r 0-0 -o /dev/null -w '%{redirect_url}' "$@" -- "$url")" || exit 40
+  if [ -n "$redirect" ]; then
+    url="$redirect"
+    follow=-L
+    set --
+  fi
+fi
+tmp="$(mktemp -d)" || exit 40
+archive="$tmp/model"
+n=0
+until curl -fsS $follow --retry 3 --retry-delay 2 -C - "$@" -o "$archive" -- "$url"; do
+  n=$((n+1))
+  if [ "$n" -ge "$MODEL_RETRIES" ]; then rm -rf "$tmp"; exit 40; fi
+  sleep $((n*5))
+done
+if [ -n "$MODEL_SHA256" ]; then
+  echo "$MODEL_SHA256  $archive" | sha256sum -c - >/dev/null || { rm -rf "$tmp"; exit 41; }
+fi
+mkdir -p "$MODEL_DIR" || exit 43
+magic="$(head -c 4 "$archive" | od -An -tx1 | tr -d ' \n')"
+case "$magic" in
+  1f8b*) tar -xzf "$archive" -C "$MODEL_DIR" ;;
+  504b0304*) unzip -o -q "$archive" -d "$MODEL_DIR" ;;
+  *) tar -tf "$archive" >/dev/null 2>&1 || { rm -rf "$tmp"; exit 42; }
+     tar -xf "$archive" -C "$MODEL_DIR" ;;
+esac
+code=$?
+rm -rf "$tmp"
+if [ $code -ne 0 ]; then exit 43; fi`
+)
+
+var (
+	// Allowed characters of a repository or download URL. No whitespace,
+	// quotes, backslashes or shell expansion characters.
+	safeURLChars = regexp.MustCompile(`^[A-Za-z0-9._~:/?#@!&()*+,;=%\[\]-]+$`)
+	// scp-like git address: user@host:path
+	scpLikeGitURL = regexp.MustCompile(`^[A-Za-z0-9._-]+@[A-Za-z0-9.-]+:[A-Za-z0-9._~/-]+$`)
+	hexRevision   = regexp.MustCompile(`^[0-9a-fA-F]{4,64}$`)
+	sha256Hex     = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+	allowedGitSchemes = map[string]bool{
+		"https": true,
+		"http":  true,
+		"ssh":   true,
+		"git":   true,
+	}
+	allowedDownloadSchemes = map[string]bool{
+		"https": true,
+		"http":  true,
+	}
+)
+
+// InitParam is a named parameter of an init step. It is delivered to the
+// init container as an environment variable.
+type InitParam struct {
+	Name  string
+	Value string
+	// Take value from secret instead
+	Secret *v1.SecretKeySelector
+}
+
+// InitStep is a structured description of an init container.
+type InitStep struct {
+	// Name of init container
+	Name string
+	// Image used to run the step
+	Image string
+	// Constant shell script executed by the step
+	Script string
+	// Parameters passed to the script
+	Params []InitParam
+	// Volumes mounted into init container
+	Mounts []v1.VolumeMount
+}
+
+// Container renders the step to init container spec.
+func (s InitStep) Container() InitContainers {
+	env := make([]v1.EnvVar, 0, len(s.Params))
+	for _, p := range s.Params {
+		if p.Secret != nil {
+			env = append(env, v1.EnvVar{Name: p.Name, ValueFrom: &v1.EnvVarSource{SecretKeyRef: p.Secret}})
+			continue
+		}
+		env = append(env, v1.EnvVar{Name: p.Name, Value: p.Value})
+	}
+	mounts := s.Mounts
+	if mounts == nil {
+		mounts = []v1.VolumeMount{}
+	}
+	image := s.Image
+	if image == "" {
+		image = defaultInitImage
+	}
+	return InitContainers{
+		Name:    s.Name,
+		Image:   image,
+		Command: []string{"/bin/sh", "-c", s.Script},
+		Env:     env,
+		Mounts: map[string]interface{}{
+			"volumeMounts": mounts,
+		},
+	}
+}
+
+func gitCloneStep(name, repository, revision, baseDir string, mounts []v1.VolumeMount) (InitStep, error) {
+	if err := ValidateGitURL(repository); err != nil {
+		return InitStep{}, err
+	}
+	if revision != "" {
+		if err := ValidateGitRevision(revision); err != nil {
+			return InitStep{}, err
+		}
+	}
+	repoName := getGitRepoName(repository)
+	return InitStep{
+		Name:   name,
+		Script: gitCloneScript,
+		Params: []InitParam{
+			{Name: "BASE_DIR", Value: baseDir},
+			{Name: "REPO_URL", Value: repository},
+			{Name: "REPO_DIR", Value: baseDir + "/" + repoName},
+			{Name: "REPO_REVISION", Value: revision},
+		},
+		Mounts: mounts,
+	}, nil
+}
+
+const defaultModelRetries = 5
+
+func (c *BoardConfig) modelDownloadStep(name string, model *ModelSource, baseDir string, mounts []v1.VolumeMount) (InitStep, error) {
+	if err := ValidateDownloadURL(model.DownloadURL); err != nil {
+		return InitStep{}, err
+	}
+	checksum, err := modelChecksum(model.Checksum)
+	if err != nil {
+		return InitStep{}, err
+	}
+	params := []InitParam{
+		{Name: "MODEL_DIR", Value: baseDir},
+		{Name: "MODEL_URL", Value: model.DownloadURL},
+		{Name: "MODEL_SHA256", Value: checksum},
+		{Name: "MODEL_RETRIES", Value: strconv.Itoa(defaultModelRetries)},
+	}
+	// Secret of the workspace is sent only to the dealer.
+	if c.isDealerURL(model.DownloadURL) {
+		optional := true
+		params = append(params,
+			InitParam{Name: "WORKSPACE_NAME", Value: c.Workspace},
+			InitParam{
+				Name: "WORKSPACE_SECRET",
+				Secret: &v1.SecretKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: c.workspaceSecretName()},
+					Key:                  "token",
+					Optional:             &optional,
+				},
+			},
+		)
+	}
+	return InitStep{
+		Name:   name,
+		Script: modelDownloadScript,
+		Params: params,
+		Mounts: mounts,
+	}, nil
+}
+
+// isDealerURL checks that URL has the scheme and the host of DealerAPI.
+func (c *BoardConfig) isDealerURL(rawURL string) bool {
+	if c.DealerAPI == "" {
+		return false
+	}
+	dealer, err := url.Parse(c.DealerAPI)
+	if err != nil || dealer.Host == "" {
+		return false
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Scheme, dealer.Scheme) && strings.EqualFold(u.Host, dealer.Host)
+}
+
+// modelChecksum returns sha256 hex digest from checksum in form
+// "sha256:<hex>" or "<hex>".
+func modelChecksum(checksum string) (string, error) {
+	if checksum == "" {
+		return "", nil
+	}
+	sum := strings.ToLower(strings.TrimPrefix(checksum, "sha256:"))
+	if !sha256Hex.MatchString(sum) {
+		return "", fmt.Errorf("Invalid model checksum '%v': only sha256 is supported", checksum)
+	}
+	return sum, nil
+}
+
+// ValidateGitURL checks that repository address is either URL with one of
+// supported schemes or scp-like address (user@host:path).
+func ValidateGitURL(repository string) error {
+	if repository == "" {
+		return fmt.Errorf("Repository URL is empty")
+	}
+	if strings.HasPrefix(repository, "-") || !safeURLChars.MatchString(repository) {
+		return fmt.Errorf("Invalid repository URL '%v'", repository)
+	}
+	var path string
+	if scpLikeGitURL.MatchString(repository) {
+		path = repository[strings.Index(repository, ":")+1:]
+	} else {
+		u, err := url.Parse(repository)
+		if err != nil {
+			return fmt.Errorf("Invalid repository URL '%v': %v", repository, err)
+		}
+		if !allowedGitSchemes[u.Scheme] || u.Host == "" {
+			return fmt.Errorf("Invalid repository URL '%v': unsupported scheme or empty host", repository)
+		}
+		path = u.Path
+	}
+	for _, part := range strings.Split(path, "/") {
+		if part == ".." {
+			return fmt.Errorf("Invalid repository URL '%v': '..' is not allowed", repository)
+		}
+	}
+	if err := validateDirName(getGitRepoName(repository)); err != nil {
+		return fmt.Errorf("Invalid repository URL '%v': %v", repository, err)
+	}
+	return nil
+}
+
+// ValidateDownloadURL checks that URL is absolute http(s) URL without
+// characters that may be interpreted by shell.
+func ValidateDownloadURL(downloadURL string) error {
+	if downloadURL == "" {
+		return fmt.Errorf("Download URL is empty")
+	}
+	if !safeURLChars.MatchString(downloadURL) {
+		return fmt.Errorf("Invalid download URL '%v'", downloadURL)
+	}
+	u, err := url.Parse(downloadURL)
+	if err != nil {
+		return fmt.Errorf("Invalid download URL '%v': %v", downloadURL, err)
+	}
+	if !allowedDownloadSchemes[u.Scheme] || u.Host == "" {
+		return fmt.Errorf("Invalid download URL '%v': only absolute http(s) URLs are supported", downloadURL)
+	}
+	return nil
+}
+
+// ValidateGitRevision checks that revision is a commit hash or a valid
+// reference name according to git-check-ref-format rules.
+func ValidateGitRevision(rev string) error {
+	invalid := func(reason string) error {
+		return fmt.Errorf("Invalid git revision '%v': %v", rev, reason)
+	}
+	if hexRevision.MatchString(rev) {
+		return nil
+	}
+	if rev == "" || len(rev) > 255 {
+		return invalid("length must be between 1 and 255")
+	}
+	if rev == "@" {
+		return invalid("'@' is not allowed")
+	}
+	if strings.HasPrefix(rev, "-") {
+		return invalid("must not start with '-'")
+	}
+	for _, r := range rev {
+		if r < 0x21 || r == 0x7f {
+			return invalid("control characters and spaces are not allowed")
+		}
+		if strings.ContainsRune("~^:?*[\\'\"`$;&|<>(){}!#", r) {
+			return invalid(fmt.Sprintf("character '%c' is not allowed", r))
+		}
+	}
+	if strings.Contains(rev, "..") || strings.Contains(rev, "@{") || strings.Contains(rev, "//") {
+		return invalid("sequences '..', '@{' and '//' are not allowed")
+	}
+	if strings.HasSuffix(rev, "/") || strings.HasSuffix(rev, ".") || strings.HasSuffix(rev, ".lock") {
+		return invalid("must not end with '/', '.' or '.lock'")
+	}
+	for _, part := range strings.Split(rev, "/") {
+		if strings.HasPrefix(part, ".") {
+			return invalid("components must not start with '.'")
+		}
+	}
+	return nil
+}
+
+func validateDirName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.HasPrefix(name, "-") || strings.ContainsAny(name, "/\\") {
+		return fmt.Errorf("can not determine directory name")
+	}
+	return nil
+}
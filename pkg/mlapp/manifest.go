@@ -0,0 +1,166 @@
+package mlapp
+
+import (
+	"context"
+	stdjson "encoding/json"
+	"fmt"
+	"time"
+
+	kuberlab "github.com/kuberlab/lib/pkg/kubernetes"
+	"github.com/kuberlab/lib/pkg/types"
+	"github.com/kuberlab/lib/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const RunManifestKey = "manifest.json"
+
+// RunManifest records everything needed to reproduce the job: resolved
+// revisions, images, effective resources, env and args of each component.
+type RunManifest struct {
+	Workspace   string    `json:"workspace"`
+	WorkspaceID string    `json:"workspace_id"`
+	Project     string    `json:"project"`
+	ProjectID   string    `json:"project_id"`
+	Task        string    `json:"task"`
+	TaskType    string    `json:"task_type,omitempty"`
+	JobID       string    `json:"job_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	// Revisions of sources used by the job
+	GitRevisions     []TaskRevision `json:"git_revisions,omitempty"`
+	DatasetRevisions []TaskRevision `json:"dataset_revisions,omitempty"`
+	ModelRevisions   []TaskRevision `json:"model_revisions,omitempty"`
+	// Task components
+	Resources []ManifestResource `json:"resources"`
+}
+
+type ManifestResource struct {
+	// Component as it was configured
+	TaskResource TaskResource `json:"resource"`
+	// Image used for execution
+	Image string `json:"image"`
+	// Image pinned by digest, empty if it wasn't resolved
+	ImageDigest string `json:"image_digest,omitempty"`
+	// Effective resources after applying defaults and limits
+	Resources ResourceRequest `json:"resources"`
+	// Effective environment. Values from secrets are recorded by reference only
+	Env  []Env  `json:"env,omitempty"`
+	Args string `json:"args,omitempty"`
+}
+
+// GenerateRunManifest builds reproducibility manifest of the job. Revisions
+// must be already injected into the task, see InjectRevisions.
+func (c *BoardConfig) GenerateRunManifest(task Task, jobID string) *RunManifest {
+	return c.GenerateRunManifestContext(context.Background(), task, jobID)
+}
+
+// GenerateRunManifestContext builds manifest of the job. Images are pinned
+// by digest with ImageResolver regardless of ImagePinning, so the job can be
+// replayed with the same images; digest is left empty if it can't be
+// resolved.
+func (c *BoardConfig) GenerateRunManifestContext(ctx context.Context, task Task, jobID string) *RunManifest {
+	m := &RunManifest{
+		Workspace:        c.Workspace,
+		WorkspaceID:      c.WorkspaceID,
+		Project:          c.Name,
+		ProjectID:        c.ProjectID,
+		Task:             task.Name,
+		TaskType:         task.TaskType,
+		JobID:            jobID,
+		CreatedAt:        time.Now().UTC(),
+		GitRevisions:     task.GitRevisions,
+		DatasetRevisions: task.DatasetRevisions,
+		ModelRevisions:   task.ModelRevisions,
+		Resources:        make([]ManifestResource, 0, len(task.Resources)),
+	}
+	keychain := registryKeychain(c.Secrets)
+	for _, r := range task.Resources {
+		g := &TaskResourceGenerator{c: c, task: task, TaskResource: r, JobID: jobID}
+		res := ManifestResource{
+			TaskResource: r,
+			Image:        r.Image(),
+			Resources:    g.ResourcesSpec(),
+			Env:          g.Env(),
+			Args:         g.Args(),
+		}
+		digest, err := c.imageResolver().Pin(ctx, res.Image, keychain)
+		if err != nil {
+			logrus.Warnf("Failed resolve digest of image %v: %v", res.Image, err)
+		}
+		res.ImageDigest = digest
+		m.Resources = append(m.Resources, res)
+	}
+	return m
+}
+
+// ReplayTask rebuilds the task executed in the manifest. Images are pinned by
+// digest if it was resolved, resources are fixed to the effective ones.
+func (m *RunManifest) ReplayTask() Task {
+	task := Task{
+		Meta:             Meta{Name: m.Task},
+		TaskType:         m.TaskType,
+		GitRevisions:     append([]TaskRevision{}, m.GitRevisions...),
+		DatasetRevisions: append([]TaskRevision{}, m.DatasetRevisions...),
+		ModelRevisions:   append([]TaskRevision{}, m.ModelRevisions...),
+		Resources:        make([]TaskResource, 0, len(m.Resources)),
+	}
+	for _, res := range m.Resources {
+		r := res.TaskResource
+		image := res.Image
+		if res.ImageDigest != "" {
+			image = res.ImageDigest
+		}
+		r.Images = Images{CPU: image, GPU: image}
+		resources := res.Resources
+		r.Resources = &resources
+		task.Resources = append(task.Resources, r)
+	}
+	return task
+}
+
+func runManifestName(project, task, jobID string) string {
+	return utils.KubePodNameEncode(fmt.Sprintf("%s-%s-%s-manifest", project, task, jobID))
+}
+
+// RunManifestResource returns config map storing the manifest next to the job.
+func (c *BoardConfig) RunManifestResource(m *RunManifest) (*kuberlab.KubeResource, error) {
+	data, err := stdjson.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	cm := &v1.ConfigMap{
+		TypeMeta: meta_v1.TypeMeta{
+			Kind:       "ConfigMap",
+			APIVersion: "v1",
+		},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      runManifestName(c.Name, m.Task, m.JobID),
+			Namespace: c.GetNamespace(),
+			Labels: c.ResourceLabels(map[string]string{
+				types.TASK_ID_LABEL:   m.JobID,
+				types.TASK_NAME_LABEL: m.Task,
+			}),
+		},
+		Data: map[string]string{RunManifestKey: string(data)},
+	}
+	gv := cm.GroupVersionKind()
+	return &kuberlab.KubeResource{
+		Name:   cm.Name + ":manifest",
+		Kind:   &gv,
+		Object: cm,
+	}, nil
+}
+
+// RunManifestFromConfigMap reads manifest stored by RunManifestResource.
+func RunManifestFromConfigMap(cm *v1.ConfigMap) (*RunManifest, error) {
+	data, ok := cm.Data[RunManifestKey]
+	if !ok {
+		return nil, fmt.Errorf("Config map '%s' doesn't contain run manifest", cm.Name)
+	}
+	m := &RunManifest{}
+	if err := stdjson.Unmarshal([]byte(data), m); err != nil {
+		return nil, fmt.Errorf("Invalid run manifest: %v", err)
+	}
+	return m, nil
+}
@@ -0,0 +1,61 @@
+package mlapp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kuberlab/lib/pkg/registry"
+	"github.com/kuberlab/lib/pkg/types"
+	"k8s.io/api/core/v1"
+)
+
+func TestRunManifestReplay(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("b", 64)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Docker-Content-Digest", digest)
+	}))
+	defer server.Close()
+	image := strings.TrimPrefix(server.URL, "http://") + "/team/app:v1"
+
+	// Images are pinned in the manifest regardless of pinning policy.
+	c := gitTaskConfig("https://github.com/kuberlab/lib")
+	c.ImageResolver = registry.NewResolver(time.Minute)
+	task := gitTask("0123456789012345678901234567890123456789")
+	task.Resources[0].Images = Images{CPU: image}
+	task.Resources[0].RawArgs = "--epochs 10"
+	task.Resources[0].Env = []Env{{Name: "A", Value: "1"}}
+
+	specs, err := c.GenerateTaskResources(task, "5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deps := specs[0].Resource.Deps
+	cm := deps[len(deps)-1].Object.(*v1.ConfigMap)
+	Assert("5", cm.Labels[types.TASK_ID_LABEL], t)
+
+	m, err := RunManifestFromConfigMap(cm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert("5", m.JobID, t)
+	Assert(task.GitRevisions, m.GitRevisions, t)
+	Assert(image, m.Resources[0].Image, t)
+	Assert(image+"@"+digest, m.Resources[0].ImageDigest, t)
+	Assert("--epochs 10", m.Resources[0].Args, t)
+
+	replay := m.ReplayTask()
+	Assert(task.Name, replay.Name, t)
+	Assert(task.GitRevisions, replay.GitRevisions, t)
+	r := replay.Resources[0]
+	Assert(image+"@"+digest, r.Image(), t)
+	Assert(m.Resources[0].Resources, *r.Resources, t)
+	Assert(task.Resources[0].Env, r.Env, t)
+
+	// Replayed task produces the same manifest.
+	again := c.GenerateRunManifest(replay, "5")
+	Assert(m.Resources[0].Resources, again.Resources[0].Resources, t)
+	Assert(m.Resources[0].Env, again.Resources[0].Env, t)
+}
@@ -26,6 +26,8 @@ type ModelServing struct {
 	Workspace   string      `json:"workspace,omitempty"`
 	ServingType ServingType `json:"type,omitempty"`
 	Spec        ServingSpec `json:"spec,omitempty"`
+	// Weighted versions of the serving sharing its traffic
+	Variants []ServingVariant `json:"variants,omitempty"`
 }
 
 type BoardModelServing struct {
@@ -43,12 +45,44 @@ func (serv *ModelServing) GPURequests() int64 {
 	return gpus
 }
 
+func (serv *ModelServing) CPUMiLimits() map[string]int64 {
+	cpuMap := make(map[string]int64)
+	if serv.Uix.Resources != nil {
+		cpu, _ := serv.Uix.Resources.CPUMemLimits()
+		cpuMap[serv.Uix.Name] = cpu
+	} else {
+		cpuMap[serv.Uix.Name] = 0
+	}
+	return cpuMap
+}
+
+func (serv *ModelServing) MemoryMBLimits() map[string]int64 {
+	memoryMap := make(map[string]int64)
+	if serv.Uix.Resources != nil {
+		_, memory := serv.Uix.Resources.CPUMemLimits()
+		memoryMap[serv.Uix.Name] = memory
+	} else {
+		memoryMap[serv.Uix.Name] = 0
+	}
+	return memoryMap
+}
+
+func (serv *ModelServing) DisableGPU(num int) int {
+	return GPUDowngrade{}.Disable([]ComponentRequests{serv}, num)
+}
+
+func (serv *ModelServing) EnableGPU(num int) int {
+	return GPUDowngrade{}.Enable([]ComponentRequests{serv}, num)
+}
+
 func (serv *ModelServing) Type() string {
 	return KindServing
 }
 
 type ServingModelResourceGenerator struct {
 	UIXResourceGenerator
+	// Name of the serving variant, empty if serving has no variants
+	Variant string
 }
 
 func (serving ServingModelResourceGenerator) ExportMetrics() bool {
@@ -108,6 +142,9 @@ func (serving ServingModelResourceGenerator) SLabels() map[string]string {
 		types.ServingIDLabel:     serving.Name(),
 		"scope":                  "mlboard",
 	}
+	if serving.Variant != "" {
+		labels[types.ServingVariantLabel] = serving.Variant
+	}
 	return labels
 }
 
@@ -131,6 +168,9 @@ func (serving ServingModelResourceGenerator) Name() string {
 }
 
 func (serving ServingModelResourceGenerator) ComponentName() string {
+	if serving.Variant != "" {
+		return utils.KubeDeploymentEncode(fmt.Sprintf("%s-%s", serving.Name(), serving.Variant))
+	}
 	return utils.KubeDeploymentEncode(fmt.Sprintf("%s", serving.Name()))
 }
 
@@ -143,25 +183,8 @@ func (c *BoardConfig) GenerateModelServing(serving BoardModelServing, dealerLimi
 	// Do not use volume mounts, use mounts from sources
 	serving.UseDefaultVolumeMapping = true
 
-	volumes, mounts, err := c.KubeVolumesSpec(serving.VolumeMounts(c.VolumesData, c.DefaultMountPath, c.DefaultReadOnly))
-	if err != nil {
-		return nil, err
-	}
-
-	initContainers, err := c.KubeInits(serving.VolumeMounts(c.VolumesData, c.DefaultMountPath, c.DefaultReadOnly), nil, nil)
-	if err != nil {
-		return nil, err
-	}
-
 	if dealerLimits && serving.DealerAPI != "" && serving.WorkspaceSecret != "" {
-		dealer, err := dealerclient.NewClient(
-			serving.DealerAPI,
-			&dealerclient.AuthOpts{
-				WorkspaceSecret: serving.WorkspaceSecret,
-				Workspace:       serving.Workspace,
-				Insecure:        true,
-			},
-		)
+		dealer, err := servingDealer(serving)
 		if err != nil {
 			return nil, err
 		}
@@ -171,6 +194,112 @@ func (c *BoardConfig) GenerateModelServing(serving BoardModelServing, dealerLimi
 		}
 		c.BoardMetadata.Limits = limits
 	}
+	// Dealer of the serving is set on a copy, the config may be reused for
+	// other servings.
+	sc := *c
+	c = &sc
+	if c.DealerAPI == "" {
+		c.DealerAPI = serving.DealerAPI
+	}
+	// Model sources are resolved with the secret of the serving.
+	if c.VersionLister == nil && serving.DealerAPI != "" && serving.WorkspaceSecret != "" &&
+		c.unresolvedServingModels(serving) {
+		dealer, err := servingDealer(serving)
+		if err != nil {
+			return nil, err
+		}
+		c.VersionLister = dealer
+	}
+
+	if len(serving.Variants) > 0 {
+		return c.generateServingVariants(serving)
+	}
+	if err := c.checkModelServing(serving.ModelServing); err != nil {
+		return nil, err
+	}
+
+	res, deploy, err := c.modelServingDeployment(serving, "")
+	if err != nil {
+		return nil, err
+	}
+	setScaleToZero(deploy, serving.Autoscale)
+	res.Deps = []*kubernetes.KubeResource{generateServingServiceFromDeployment(deploy)}
+
+	for _, s := range c.Secrets {
+		res.Deps = append(res.Deps, c.secret2kubeResource(s))
+	}
+
+	resources = append(resources, res)
+
+	if serving.Autoscale != nil && serving.Autoscale.Enabled {
+		if autoscaler := c.generateHPA(deploy, serving.Autoscale); autoscaler != nil {
+			resources = append(resources, autoscaler)
+		}
+	}
+
+	return resources, nil
+}
+
+// unresolvedServingModels reports whether model sources of the serving or
+// of its variants must be resolved.
+func (c *BoardConfig) unresolvedServingModels(serving BoardModelServing) bool {
+	if unresolved, _ := c.unresolvedModels(nil); unresolved {
+		return true
+	}
+	for _, variant := range serving.Variants {
+		if unresolved, _ := c.withSources(variant.Sources).unresolvedModels(nil); unresolved {
+			return true
+		}
+	}
+	return false
+}
+
+func servingDealer(serving BoardModelServing) (*dealerclient.Client, error) {
+	return dealerclient.NewClient(
+		serving.DealerAPI,
+		&dealerclient.AuthOpts{
+			WorkspaceSecret: serving.WorkspaceSecret,
+			Workspace:       serving.Workspace,
+			Insecure:        true,
+		},
+	)
+}
+
+// checkModelServing checks the serving against workspace limits.
+func (c *BoardConfig) checkModelServing(serving ModelServing) error {
+	if err := c.CheckResourceLimit(serving.Uix.Resource, serving.Name); err != nil {
+		return err
+	}
+	if err := checkRollout(serving.Rollout, serving.Name); err != nil {
+		return err
+	}
+	if err := checkAutoscale(serving.Autoscale, serving.Name); err != nil {
+		return err
+	}
+	return c.checkRequestedQuota(&serving)
+}
+
+// modelServingDeployment generates Deployment of the serving or of its
+// variant if variant is not empty.
+func (c *BoardConfig) modelServingDeployment(serving BoardModelServing, variant string) (*kubernetes.KubeResource, *appsv1.Deployment, error) {
+	c, err := c.withModelSources(nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	volumes, mounts, err := c.componentVolumes(serving.Name, serving.VolumeMounts(c.VolumesData, c.DefaultMountPath, c.DefaultReadOnly))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	initContainers, err := c.KubeInits(serving.VolumeMounts(c.VolumesData, c.DefaultMountPath, c.DefaultReadOnly), nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	secrets := append(append([]Secret{}, c.Secrets...), serving.Secrets...)
+	if err := c.pinDeploymentImages(&serving.Images, secrets); err != nil {
+		return nil, nil, err
+	}
 
 	g := ServingModelResourceGenerator{
 		UIXResourceGenerator: UIXResourceGenerator{
@@ -180,6 +309,7 @@ func (c *BoardConfig) GenerateModelServing(serving BoardModelServing, dealerLimi
 			volumes:        volumes,
 			InitContainers: initContainers,
 		},
+		Variant: variant,
 	}
 
 	if g.PrivilegedMode() {
@@ -195,28 +325,9 @@ func (c *BoardConfig) GenerateModelServing(serving BoardModelServing, dealerLimi
 
 	res, err := kubernetes.GetTemplatedResource(DeploymentTpl, g.ComponentName()+":resource", g)
 	if err != nil {
-		return nil, fmt.Errorf("Failed parse template '%s': %v", g.ComponentName(), err)
-	}
-
-	deploy := res.Object.(*appsv1.Deployment)
-	res.Deps = []*kubernetes.KubeResource{generateServingServiceFromDeployment(deploy)}
-
-	for _, s := range c.Secrets {
-		res.Deps = append(res.Deps, c.secret2kubeResource(s))
+		return nil, nil, fmt.Errorf("Failed parse template '%s': %v", g.ComponentName(), err)
 	}
-
-	resources = append(resources, res)
-
-	if serving.Autoscale != nil && serving.Autoscale.Enabled {
-		if deploy.Spec.Template.Spec.Containers[0].Resources.Requests.Cpu().MilliValue() != 0 {
-			autoscaler := c.generateHPA(deploy, serving.Autoscale)
-			if autoscaler != nil {
-				resources = append(resources, autoscaler)
-			}
-		}
-	}
-
-	return resources, nil
+	return res, res.Object.(*appsv1.Deployment), nil
 }
 
 func (c *BoardConfig) secret2kubeResource(s Secret) *kubernetes.KubeResource {
@@ -0,0 +1,234 @@
+package mlapp
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/kuberlab/lib/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/api/core/v1"
+)
+
+const (
+	// Two or more mounts use the same mount path.
+	MountConflict = "conflict"
+	// Mount path is nested inside another mount and hides part of its content.
+	MountShadowing = "shadowing"
+	// Path leaves the directory it is allowed to use.
+	MountTraversal = "traversal"
+)
+
+// MountIssue describes a problem found in volume mounts of the component.
+type MountIssue struct {
+	Component string   `json:"component"`
+	Kind      string   `json:"kind"`
+	MountPath string   `json:"mountPath,omitempty"`
+	Volumes   []string `json:"volumes,omitempty"`
+	Message   string   `json:"message"`
+}
+
+// Fatal reports whether the issue prevents component from being deployed.
+// Shadowing is allowed, e.g. library directory inside of the source.
+func (i MountIssue) Fatal() bool {
+	return i.Kind != MountShadowing
+}
+
+type MountIssues []MountIssue
+
+// Err returns error combined from all fatal issues or nil.
+func (issues MountIssues) Err() error {
+	if len(issues) == 0 {
+		return nil
+	}
+	msgs := make([]string, 0)
+	for _, i := range issues {
+		if i.Fatal() {
+			msgs = append(msgs, i.Message)
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return errors.NewStatusReason(
+		http.StatusBadRequest,
+		fmt.Sprintf("Invalid volume mounts for '%s': %s", issues[0].Component, strings.Join(msgs, "; ")),
+		"Mount paths must be unique and sub paths must not leave the source directory",
+	)
+}
+
+// ValidateVolumeMounts checks mounts of the component: mount path conflicts,
+// mounts shadowing each other and sub paths escaping the source directory.
+func (c *BoardConfig) ValidateVolumeMounts(component string, mounts []VolumeMount) (MountIssues, error) {
+	_, _, issues, err := c.checkVolumeMounts(component, mounts)
+	return issues, err
+}
+
+// componentVolumes builds volumes and mounts for the component and fails if
+// they can not be deployed. Warnings are logged.
+func (c *BoardConfig) componentVolumes(component string, mounts []VolumeMount) ([]v1.Volume, []v1.VolumeMount, error) {
+	volumes, kmounts, issues, err := c.checkVolumeMounts(component, mounts)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, i := range issues {
+		if !i.Fatal() {
+			logrus.Warnf("[%v] %v", i.Component, i.Message)
+		}
+	}
+	if err := issues.Err(); err != nil {
+		return nil, nil, err
+	}
+	return volumes, kmounts, nil
+}
+
+func (c *BoardConfig) checkVolumeMounts(component string, mounts []VolumeMount) ([]v1.Volume, []v1.VolumeMount, MountIssues, error) {
+	if c.ResolveDuplicateMounts {
+		mounts = resolveDuplicateMounts(mounts)
+	}
+	issues := make(MountIssues, 0)
+	for _, m := range mounts {
+		v := c.volumeByName(m.Name)
+		if v == nil {
+			continue
+		}
+		if msg := c.subPathTraversal(v, m); msg != "" {
+			issues = append(issues, MountIssue{
+				Component: component,
+				Kind:      MountTraversal,
+				MountPath: m.MountPath,
+				Volumes:   []string{m.Name},
+				Message:   msg,
+			})
+		}
+	}
+	volumes, kmounts, err := c.KubeVolumesSpec(mounts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	issues = append(issues, c.mountPathIssues(component, kmounts)...)
+	return volumes, kmounts, issues, nil
+}
+
+// subPathTraversal checks that the volume sub path stays inside of the
+// workspace directory and mount sub path stays inside of the volume.
+func (c *BoardConfig) subPathTraversal(v *Volume, m VolumeMount) string {
+	base := c.volumeSubPath(v)
+	if v.ClusterStorage != "" {
+		root := c.Workspace + "/" + c.WorkspaceID
+		if !v.IsWorkspaceLocal && strings.HasPrefix(v.SubPath, "/shared/") {
+			root = "shared"
+		}
+		if !isSubPath(root, base) {
+			return fmt.Sprintf("source '%s' sub path '%s' leaves directory '%s'", v.Name, v.SubPath, root)
+		}
+	} else if hasDotDot(base) {
+		return fmt.Sprintf("source '%s' sub path '%s' must not contain '..'", v.Name, v.SubPath)
+	}
+	if len(m.SubPath) > 0 && !isSubPath(base, path.Join(base, m.SubPath)) {
+		return fmt.Sprintf("mount '%s' sub path '%s' leaves source '%s'", m.MountPath, m.SubPath, v.Name)
+	}
+	return ""
+}
+
+func (c *BoardConfig) mountPathIssues(component string, mounts []v1.VolumeMount) MountIssues {
+	names := make(map[string]string)
+	for _, v := range c.VolumesData {
+		names[v.CommonID()] = v.Name
+	}
+	volumeName := func(m v1.VolumeMount) string {
+		if n, ok := names[m.Name]; ok {
+			return n
+		}
+		return m.Name
+	}
+
+	issues := make(MountIssues, 0)
+	byPath := make(map[string][]string)
+	paths := make([]string, 0)
+	for _, m := range mounts {
+		if !strings.HasPrefix(m.MountPath, "/") || hasDotDot(m.MountPath) {
+			issues = append(issues, MountIssue{
+				Component: component,
+				Kind:      MountTraversal,
+				MountPath: m.MountPath,
+				Volumes:   []string{volumeName(m)},
+				Message:   fmt.Sprintf("mount path '%s' must be absolute and must not contain '..'", m.MountPath),
+			})
+			continue
+		}
+		p := path.Clean(m.MountPath)
+		if _, ok := byPath[p]; !ok {
+			paths = append(paths, p)
+		}
+		byPath[p] = append(byPath[p], volumeName(m))
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		if len(byPath[p]) > 1 {
+			issues = append(issues, MountIssue{
+				Component: component,
+				Kind:      MountConflict,
+				MountPath: p,
+				Volumes:   byPath[p],
+				Message:   fmt.Sprintf("'%s' is mounted %d times (%s)", p, len(byPath[p]), strings.Join(byPath[p], ", ")),
+			})
+		}
+	}
+	for i, parent := range paths {
+		for _, child := range paths[i+1:] {
+			if !isSubPath(parent, child) {
+				continue
+			}
+			issues = append(issues, MountIssue{
+				Component: component,
+				Kind:      MountShadowing,
+				MountPath: child,
+				Volumes:   []string{byPath[parent][0], byPath[child][0]},
+				Message: fmt.Sprintf(
+					"'%s' (%s) shadows part of '%s' (%s)",
+					child, byPath[child][0], parent, byPath[parent][0],
+				),
+			})
+		}
+	}
+	return issues
+}
+
+// resolveDuplicateMounts merges mounts of the same volume and sub path to
+// the same mount path. Merged mount is read-only only if all of them are.
+func resolveDuplicateMounts(mounts []VolumeMount) []VolumeMount {
+	resolved := make([]VolumeMount, 0, len(mounts))
+	index := make(map[string]int)
+	for _, m := range mounts {
+		key := m.Name + ":" + path.Clean(m.MountPath) + ":" + path.Clean("/"+m.SubPath)
+		if i, ok := index[key]; ok {
+			resolved[i].ReadOnly = resolved[i].ReadOnly && m.ReadOnly
+			continue
+		}
+		index[key] = len(resolved)
+		resolved = append(resolved, m)
+	}
+	return resolved
+}
+
+// isSubPath reports whether p is equal to root or located inside of it.
+func isSubPath(root, p string) bool {
+	root = path.Clean(root)
+	p = path.Clean(p)
+	if root == "/" {
+		return strings.HasPrefix(p, "/")
+	}
+	return p == root || strings.HasPrefix(p, root+"/")
+}
+
+func hasDotDot(p string) bool {
+	for _, part := range strings.Split(p, "/") {
+		if part == ".." {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package mlapp
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+)
+
+func mountsConfig() *BoardConfig {
+	c := gitTaskConfig("https://github.com/kuberlab/lib")
+	c.VolumesData = append(
+		c.VolumesData,
+		Volume{Name: "lib", ClusterStorage: "storage", VolumeSource: VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}},
+		Volume{Name: "data", ClusterStorage: "storage", SubPath: "/../../other", VolumeSource: VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}},
+	)
+	return c
+}
+
+func issueKinds(issues MountIssues) []string {
+	kinds := make([]string, 0)
+	for _, i := range issues {
+		kinds = append(kinds, i.Kind)
+	}
+	return kinds
+}
+
+func TestValidateVolumeMounts(t *testing.T) {
+	c := mountsConfig()
+
+	issues, err := c.ValidateVolumeMounts("worker", []VolumeMount{
+		{Name: "src", MountPath: "/notebooks"},
+		{Name: "lib", MountPath: "/notebooks/lib"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert([]string{MountShadowing}, issueKinds(issues), t)
+	Assert(nil, issues.Err(), t)
+
+	issues, _ = c.ValidateVolumeMounts("worker", []VolumeMount{
+		{Name: "src", MountPath: "/notebooks"},
+		{Name: "lib", MountPath: "/notebooks/"},
+	})
+	Assert([]string{MountConflict}, issueKinds(issues), t)
+	Assert([]string{"src", "lib"}, issues[0].Volumes, t)
+
+	issues, _ = c.ValidateVolumeMounts("worker", []VolumeMount{
+		{Name: "lib", MountPath: "/lib", SubPath: "../../../x"},
+		{Name: "data", MountPath: "/data"},
+		{Name: "src", MountPath: "/src/../etc"},
+	})
+	Assert([]string{MountTraversal, MountTraversal, MountTraversal}, issueKinds(issues), t)
+	if issues.Err() == nil {
+		t.Fatal("Traversal must be fatal")
+	}
+}
+
+func TestResolveDuplicateMounts(t *testing.T) {
+	c := mountsConfig()
+	mounts := []VolumeMount{
+		{Name: "lib", MountPath: "/lib", ReadOnly: true},
+		{Name: "lib", MountPath: "/lib/"},
+	}
+	if _, _, err := c.componentVolumes("worker", mounts); err == nil {
+		t.Fatal("Duplicate mounts must be rejected by default")
+	}
+	c.ResolveDuplicateMounts = true
+	_, kmounts, err := c.componentVolumes("worker", mounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert("/lib", kmounts[0].MountPath, t)
+	Assert(false, kmounts[0].ReadOnly, t)
+	Assert(1, len(kmounts), t)
+}
@@ -0,0 +1,98 @@
+package mlapp
+
+import (
+	"github.com/kuberlab/lib/pkg/dealerclient"
+	kuberlab "github.com/kuberlab/lib/pkg/kubernetes"
+	"github.com/kuberlab/lib/pkg/utils"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	WorkspaceQuotaName      = "workspace-quota"
+	WorkspaceLimitRangeName = "workspace-limits"
+)
+
+// WorkspaceNamespaceResources returns namespace of the workspace with
+// ResourceQuota and LimitRange enforcing workspace limits for anything
+// created in the namespace. Quota is set on requests: containers get
+// workspace limits by ResourceSpec, so quota on limits would admit a single
+// pod. Number of pods is not limited if maxPods is zero.
+func WorkspaceNamespaceResources(workspaceID, workspaceName string, limits *dealerclient.ResourceLimit, maxPods int64) []*kuberlab.KubeResource {
+	namespace := NamespaceName(workspaceID, workspaceName)
+	meta := func(name string) meta_v1.ObjectMeta {
+		return meta_v1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{KUBERLAB_WS_ID_LABEL: utils.KubeLabelEncode(workspaceID)},
+		}
+	}
+	ns := &v1.Namespace{
+		TypeMeta:   meta_v1.TypeMeta{Kind: "Namespace", APIVersion: "v1"},
+		ObjectMeta: meta(namespace),
+	}
+	ns.Namespace = ""
+	resources := []*kuberlab.KubeResource{namespaceResource(ns, ns.Name+":namespace")}
+
+	hard := v1.ResourceList{}
+	max := v1.ResourceList{}
+	if q := limits.CPUQuantity(); q != nil {
+		hard[v1.ResourceRequestsCPU] = *q
+		max[v1.ResourceCPU] = *q
+	}
+	if q := limits.MemoryQuantity(); q != nil {
+		hard[v1.ResourceRequestsMemory] = *q
+		max[v1.ResourceMemory] = *q
+	}
+	if q := limits.GPUQuantity(); q != nil {
+		hard[v1.DefaultResourceRequestsPrefix+gpuResourceName] = *q
+	}
+	if maxPods > 0 {
+		hard[v1.ResourcePods] = *resource.NewQuantity(maxPods, resource.DecimalSI)
+	}
+	if len(hard) > 0 {
+		quota := &v1.ResourceQuota{
+			TypeMeta:   meta_v1.TypeMeta{Kind: "ResourceQuota", APIVersion: "v1"},
+			ObjectMeta: meta(WorkspaceQuotaName),
+			Spec:       v1.ResourceQuotaSpec{Hard: hard},
+		}
+		resources = append(resources, namespaceResource(quota, namespace+":"+quota.Name))
+	}
+
+	// Containers without resources, e.g. sidecars, get default requests of
+	// ResourceSpec as requests and limits, so they don't take the whole
+	// workspace.
+	defaults := DefaultResourceRequests()
+	defaultRequest := v1.ResourceList{}
+	for name, q := range map[v1.ResourceName]*resource.Quantity{
+		v1.ResourceCPU:    minQuantity(defaults.CPUQuantity(), limits.CPUQuantity()),
+		v1.ResourceMemory: minQuantity(defaults.MemoryQuantity(), limits.MemoryQuantity()),
+	} {
+		defaultRequest[name] = *q
+	}
+	limitRange := &v1.LimitRange{
+		TypeMeta:   meta_v1.TypeMeta{Kind: "LimitRange", APIVersion: "v1"},
+		ObjectMeta: meta(WorkspaceLimitRangeName),
+		Spec: v1.LimitRangeSpec{Limits: []v1.LimitRangeItem{{
+			Type:           v1.LimitTypeContainer,
+			Default:        defaultRequest.DeepCopy(),
+			DefaultRequest: defaultRequest,
+		}}},
+	}
+	if len(max) > 0 {
+		limitRange.Spec.Limits[0].Max = max
+	}
+	resources = append(resources, namespaceResource(limitRange, namespace+":"+limitRange.Name))
+	return resources
+}
+
+func namespaceResource(obj runtime.Object, name string) *kuberlab.KubeResource {
+	gv := obj.GetObjectKind().GroupVersionKind()
+	return &kuberlab.KubeResource{
+		Name:   name,
+		Kind:   &gv,
+		Object: obj,
+	}
+}
@@ -0,0 +1,124 @@
+package mlapp
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kuberlab/lib/pkg/dealerclient"
+	kuberlab "github.com/kuberlab/lib/pkg/kubernetes"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestWorkspaceNamespaceResources(t *testing.T) {
+	cpu := resource.MustParse("4")
+	mem := resource.MustParse("100Mi")
+	gpu := int64(2)
+	limits := &dealerclient.ResourceLimit{CPU: &cpu, Memory: &mem, GPU: &gpu}
+	resources := WorkspaceNamespaceResources("21", "Demo", limits, 10)
+	Assert(3, len(resources), t)
+
+	ns := resources[0].Object.(*v1.Namespace)
+	Assert(NamespaceName("21", "Demo"), ns.Name, t)
+	Assert("Namespace", resources[0].Kind.Kind, t)
+
+	quota := resources[1].Object.(*v1.ResourceQuota)
+	Assert(ns.Name, quota.Namespace, t)
+	hard := quota.Spec.Hard
+	Assert("4", hard.Name(v1.ResourceRequestsCPU, resource.DecimalSI).String(), t)
+	_, ok := hard[v1.ResourceLimitsMemory]
+	Assert(false, ok, t)
+	Assert("100Mi", hard.Name(v1.ResourceRequestsMemory, resource.BinarySI).String(), t)
+	Assert("2", hard.Name("requests.nvidia.com/gpu", resource.DecimalSI).String(), t)
+	Assert("10", hard.Pods().String(), t)
+
+	item := resources[2].Object.(*v1.LimitRange).Spec.Limits[0]
+	Assert("50m", item.DefaultRequest.Cpu().String(), t)
+	// Default request doesn't exceed workspace limit.
+	Assert("100Mi", item.DefaultRequest.Memory().String(), t)
+	Assert("50m", item.Default.Cpu().String(), t)
+	Assert("100Mi", item.Max.Memory().String(), t)
+
+	// Without limits only defaults are set.
+	resources = WorkspaceNamespaceResources("21", "Demo", nil, 0)
+	Assert(2, len(resources), t)
+	item = resources[1].Object.(*v1.LimitRange).Spec.Limits[0]
+	Assert("128Mi", item.DefaultRequest.Memory().String(), t)
+	Assert(0, len(item.Max), t)
+}
+
+// admit applies defaults of the LimitRange to pods and checks them against
+// the ResourceQuota like admission controllers of the API server.
+func admit(quota *v1.ResourceQuota, limitRange *v1.LimitRange, pods ...v1.PodSpec) error {
+	item := limitRange.Spec.Limits[0]
+	used := v1.ResourceList{}
+	add := func(name v1.ResourceName, q resource.Quantity) {
+		total := used[name]
+		total.Add(q)
+		used[name] = total
+	}
+	for _, pod := range pods {
+		for _, c := range append(pod.InitContainers, pod.Containers...) {
+			requests, limits := v1.ResourceList{}, v1.ResourceList{}
+			for name, q := range item.Default {
+				limits[name] = q
+			}
+			for name, q := range c.Resources.Limits {
+				limits[name] = q
+				// Request defaults to explicit limit.
+				requests[name] = q
+			}
+			for name, q := range item.DefaultRequest {
+				if _, ok := c.Resources.Limits[name]; !ok {
+					requests[name] = q
+				}
+			}
+			for name, q := range c.Resources.Requests {
+				requests[name] = q
+			}
+			for name, max := range item.Max {
+				if q, ok := limits[name]; ok && q.Cmp(max) > 0 {
+					return fmt.Errorf("container %v: %v limit %v exceeds %v", c.Name, name, q.String(), max.String())
+				}
+			}
+			// Init containers are summed with containers, it is stricter
+			// than the API server.
+			for name, q := range requests {
+				add(v1.ResourceName("requests."+string(name)), q)
+			}
+			for name, q := range limits {
+				add(v1.ResourceName("limits."+string(name)), q)
+			}
+		}
+	}
+	for name, hard := range quota.Spec.Hard {
+		if q, ok := used[name]; ok && q.Cmp(hard) > 0 {
+			return fmt.Errorf("exceeded quota: %v used %v, limited %v", name, q.String(), hard.String())
+		}
+	}
+	return nil
+}
+
+func TestWorkspaceQuotaAdmitsPods(t *testing.T) {
+	cpu := resource.MustParse("2")
+	mem := resource.MustParse("2Gi")
+	limits := &dealerclient.ResourceLimit{CPU: &cpu, Memory: &mem}
+	resources := WorkspaceNamespaceResources("1", "ws", limits, 0)
+	quota := resources[1].Object.(*v1.ResourceQuota)
+	limitRange := resources[2].Object.(*v1.LimitRange)
+
+	// Task without resources along with the git commit sidecar.
+	c := gitTaskConfig("https://github.com/kuberlab/lib")
+	c.BoardMetadata.Limits = limits
+	task := gitTask("")
+	task.Resources[0].CommitGit = []string{"src"}
+	specs, err := c.GenerateTaskResources(task, "5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := specs[0].Resource.Object.(*kuberlab.WorkerSet).PodTemplate.Spec
+	Assert(2, len(pod.Containers), t)
+	if err := admit(quota, limitRange, pod, pod); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,156 @@
+package mlapp
+
+import (
+	"strings"
+)
+
+// OpenAPIDocument is OpenAPI 3 description of the serving interface.
+type OpenAPIDocument struct {
+	OpenAPI string                     `json:"openapi"`
+	Info    OpenAPIInfo                `json:"info"`
+	Paths   map[string]OpenAPIPathItem `json:"paths"`
+}
+
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type OpenAPIPathItem struct {
+	Post *OpenAPIOperation `json:"post,omitempty"`
+}
+
+type OpenAPIOperation struct {
+	OperationID string                     `json:"operationId,omitempty"`
+	RequestBody *OpenAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                        `json:"required,omitempty"`
+	Content  map[string]OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `json:"schema,omitempty"`
+}
+
+type OpenAPISchema struct {
+	Type        string                    `json:"type,omitempty"`
+	Format      string                    `json:"format,omitempty"`
+	Description string                    `json:"description,omitempty"`
+	Enum        []interface{}             `json:"enum,omitempty"`
+	Default     interface{}               `json:"default,omitempty"`
+	Items       *OpenAPISchema            `json:"items,omitempty"`
+	MinItems    *int                      `json:"minItems,omitempty"`
+	MaxItems    *int                      `json:"maxItems,omitempty"`
+	Properties  map[string]*OpenAPISchema `json:"properties,omitempty"`
+	Required    []string                  `json:"required,omitempty"`
+}
+
+// paramSchema returns schema of the param type, arrays are nested by shape.
+func paramSchema(typ string, shape []int) *OpenAPISchema {
+	s := &OpenAPISchema{}
+	typ = strings.ToLower(typ)
+	switch paramKind(typ) {
+	case paramString:
+		s.Type = "string"
+	case paramBytes:
+		s.Type, s.Format = "string", "byte"
+	case paramInteger:
+		s.Type = "integer"
+		if typ == "int32" || typ == "int64" {
+			s.Format = typ
+		}
+	case paramNumber:
+		s.Type = "number"
+		switch typ {
+		case "float", "float32":
+			s.Format = "float"
+		case "double", "float64":
+			s.Format = "double"
+		}
+	case paramBool:
+		s.Type = "boolean"
+	}
+	for i := len(shape) - 1; i >= 0; i-- {
+		s = &OpenAPISchema{Type: "array", Items: s}
+		if shape[i] >= 0 {
+			size := shape[i]
+			s.MinItems, s.MaxItems = &size, &size
+		}
+	}
+	return s
+}
+
+// RequestSchema returns schema of the request payload described by Params.
+func (s ServingSpec) RequestSchema() *OpenAPISchema {
+	schema := &OpenAPISchema{Type: "object", Properties: make(map[string]*OpenAPISchema)}
+	for _, p := range s.Params {
+		ps := paramSchema(p.Type, p.Shape)
+		ps.Description = p.Label
+		ps.Default = p.Value
+		// Options restrict items of arrays
+		item := ps
+		for item.Items != nil {
+			item = item.Items
+		}
+		item.Enum = p.options()
+		schema.Properties[p.Name] = ps
+		if p.Required {
+			schema.Required = append(schema.Required, p.Name)
+		}
+	}
+	return schema
+}
+
+// ResponseSchema returns schema of the response described by Response.
+func (s ServingSpec) ResponseSchema() *OpenAPISchema {
+	schema := &OpenAPISchema{Type: "object", Properties: make(map[string]*OpenAPISchema)}
+	for _, r := range s.Response {
+		rs := paramSchema(r.Type, r.Shape)
+		rs.Description = r.Description
+		schema.Properties[r.Name] = rs
+	}
+	return schema
+}
+
+// OpenAPI generates OpenAPI 3 document of the serving accepting requests
+// on path.
+func (s ServingSpec) OpenAPI(title, path string) *OpenAPIDocument {
+	request := OpenAPIMediaType{Schema: s.RequestSchema()}
+	contentType := "application/json"
+	if s.RawInput {
+		request = OpenAPIMediaType{Schema: &OpenAPISchema{Type: "string", Format: "binary"}}
+		contentType = "application/octet-stream"
+	}
+	op := &OpenAPIOperation{
+		OperationID: title,
+		RequestBody: &OpenAPIRequestBody{
+			Required: true,
+			Content:  map[string]OpenAPIMediaType{contentType: request},
+		},
+		Responses: map[string]OpenAPIResponse{
+			"200": {
+				Description: "Serving response",
+				Content:     map[string]OpenAPIMediaType{"application/json": {Schema: s.ResponseSchema()}},
+			},
+			"400": {Description: "Invalid request params"},
+		},
+	}
+	return &OpenAPIDocument{
+		OpenAPI: "3.0.3",
+		Info:    OpenAPIInfo{Title: title, Version: "1.0"},
+		Paths:   map[string]OpenAPIPathItem{path: {Post: op}},
+	}
+}
+
+// OpenAPI generates OpenAPI 3 document of the serving.
+func (s UniversalServing) OpenAPI(path string) *OpenAPIDocument {
+	return s.Spec.OpenAPI(s.Name, path)
+}
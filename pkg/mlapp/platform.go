@@ -0,0 +1,213 @@
+package mlapp
+
+import (
+	"k8s.io/api/core/v1"
+)
+
+const (
+	// Helpers are mounted from the node host paths.
+	HelpersHostPath = "hostPath"
+	// Helpers are copied from HelpersImage to the pod by init container.
+	HelpersInitContainer = "initContainer"
+	// Helpers are not delivered at all.
+	HelpersNone = "none"
+
+	helpersVolume   = "kuberlab-helpers"
+	helpersInitDir  = "/kuberlab-helpers"
+	helpersLibsPath = "python-libs"
+)
+
+// PlatformProfile describes cluster specific settings: where helper binaries
+// and python libs come from, which images and services are available
+// and how nodes are tainted.
+type PlatformProfile struct {
+	// Image used for init containers (git clone, model download)
+	InitImage string `json:"init_image,omitempty"`
+	// Delivery method of kdataset, tf_conf and python libs: hostPath, initContainer or none
+	HelpersDelivery string `json:"helpers_delivery,omitempty"`
+	// Host directory containing kdataset and tf_conf for hostPath delivery
+	HelpersHostDir string `json:"helpers_host_dir,omitempty"`
+	// Image for initContainer delivery. It must contain kdataset, tf_conf
+	// and python-libs directory in HelpersImageDir
+	HelpersImage    string `json:"helpers_image,omitempty"`
+	HelpersImageDir string `json:"helpers_image_dir,omitempty"`
+	// Pluk URL written to kuberlab config
+	PlukURL string `json:"pluk_url,omitempty"`
+	// Location of python libs inside of the containers (and on the host for hostPath delivery)
+	PythonLibsPath string `json:"python_libs_path,omitempty"`
+	// known_hosts content used to verify SSH git servers when detecting
+	// revisions, hashed entries are supported
+	GitKnownHosts string `json:"git_known_hosts,omitempty"`
+	// Tolerations added to every pod
+	DefaultTolerations []v1.Toleration `json:"default_tolerations,omitempty"`
+	// Tolerations added to pods requesting GPU
+	GPUTolerations []v1.Toleration `json:"gpu_tolerations,omitempty"`
+	// Priority levels available for Resource.Priority
+	PriorityClasses []PriorityClass `json:"priority_classes,omitempty"`
+	// Pod metric of GPU utilization in custom metrics API, used to
+	// autoscale servings by GPU
+	GPUUtilizationMetric string `json:"gpu_utilization_metric,omitempty"`
+	// Class and host of ingress-nginx Ingresses splitting traffic among
+	// serving variants, any host is matched if empty
+	ServingIngressClass string `json:"serving_ingress_class,omitempty"`
+	ServingIngressHost  string `json:"serving_ingress_host,omitempty"`
+}
+
+// PriorityClass maps priority level of components to PriorityClass.
+type PriorityClass struct {
+	// Level used in Resource.Priority and workspace limits
+	Level string `json:"level"`
+	// Name of PriorityClass object
+	Name  string `json:"name"`
+	Value int32  `json:"value"`
+	// Pods of the class wait for resources instead of preempting other pods
+	NonPreempting bool   `json:"non_preempting,omitempty"`
+	Description   string `json:"description,omitempty"`
+}
+
+var DefaultPlatformProfile = PlatformProfile{
+	InitImage:       defaultInitImage,
+	HelpersDelivery: HelpersHostPath,
+	HelpersHostDir:  "/usr/bin/",
+	HelpersImageDir: "/kuberlab",
+	PlukURL:         "http://pluk.kuberlab.svc.cluster.local:8082",
+	PythonLibsPath:  kibernetikaPythonLibs,
+	DefaultTolerations: []v1.Toleration{
+		{Key: "role.kuberlab.io/cpu-compute", Effect: v1.TaintEffectPreferNoSchedule},
+	},
+	GPUTolerations: []v1.Toleration{
+		{Key: "role.kuberlab.io/gpu-compute", Effect: v1.TaintEffectPreferNoSchedule},
+	},
+	PriorityClasses: []PriorityClass{
+		{
+			Level: "low", Name: "kuberlab-low", Value: 1000, NonPreempting: true,
+			Description: "Exploratory tasks, may be preempted by other components",
+		},
+		{Level: "normal", Name: "kuberlab-normal", Value: 10000, Description: "Regular tasks and Uix"},
+		{Level: "high", Name: "kuberlab-high", Value: 100000, Description: "Production servings"},
+	},
+	GPUUtilizationMetric: "DCGM_FI_DEV_GPU_UTIL",
+	ServingIngressClass:  "nginx",
+}
+
+// Platform returns platform profile of the config. Empty fields are taken
+// from DefaultPlatformProfile.
+func (c *BoardConfig) Platform() PlatformProfile {
+	if c.PlatformProfile == nil {
+		return DefaultPlatformProfile
+	}
+	p := *c.PlatformProfile
+	d := DefaultPlatformProfile
+	if p.InitImage == "" {
+		p.InitImage = d.InitImage
+	}
+	if p.HelpersDelivery == "" {
+		p.HelpersDelivery = d.HelpersDelivery
+	}
+	if p.HelpersHostDir == "" {
+		p.HelpersHostDir = d.HelpersHostDir
+	}
+	if p.HelpersImageDir == "" {
+		p.HelpersImageDir = d.HelpersImageDir
+	}
+	if p.PlukURL == "" {
+		p.PlukURL = d.PlukURL
+	}
+	if p.PythonLibsPath == "" {
+		p.PythonLibsPath = d.PythonLibsPath
+	}
+	if p.DefaultTolerations == nil {
+		p.DefaultTolerations = d.DefaultTolerations
+	}
+	if p.GPUTolerations == nil {
+		p.GPUTolerations = d.GPUTolerations
+	}
+	if p.PriorityClasses == nil {
+		p.PriorityClasses = d.PriorityClasses
+	}
+	if p.GPUUtilizationMetric == "" {
+		p.GPUUtilizationMetric = d.GPUUtilizationMetric
+	}
+	if p.ServingIngressClass == "" {
+		p.ServingIngressClass = d.ServingIngressClass
+	}
+	return p
+}
+
+func (c *BoardConfig) tolerations(gpu uint) []v1.Toleration {
+	p := c.Platform()
+	tolerations := append([]v1.Toleration{}, p.DefaultTolerations...)
+	if gpu > 0 {
+		tolerations = append(tolerations, p.GPUTolerations...)
+	}
+	if c.DeployResourceLabel != "" {
+		tolerations = append(tolerations, v1.Toleration{
+			Key:    "kuberlab.io/private-resource",
+			Value:  c.DeployResourceLabel,
+			Effect: v1.TaintEffectNoSchedule,
+		})
+	}
+	return tolerations
+}
+
+// helperVolumes returns volumes and mounts delivering kdataset, tf_conf
+// and python libs into the component.
+func (c *BoardConfig) helperVolumes() ([]v1.Volume, []v1.VolumeMount) {
+	p := c.Platform()
+	mounts := []v1.VolumeMount{
+		{Name: "kdataset", MountPath: "/usr/bin/kdataset", ReadOnly: true, SubPath: "kdataset"},
+		{Name: "tf-conf", MountPath: "/usr/bin/tf_conf", ReadOnly: true, SubPath: "tf_conf"},
+		{Name: "mlboardclient", MountPath: p.PythonLibsPath, ReadOnly: true},
+	}
+	switch p.HelpersDelivery {
+	case HelpersInitContainer:
+		for i := range mounts {
+			mounts[i].Name = helpersVolume
+		}
+		mounts[2].SubPath = helpersLibsPath
+		volumes := []v1.Volume{
+			{Name: helpersVolume, VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}},
+		}
+		return volumes, mounts
+	case HelpersNone:
+		return []v1.Volume{}, []v1.VolumeMount{}
+	}
+	dirOrCreate := v1.HostPathDirectoryOrCreate
+	volumes := []v1.Volume{
+		{Name: "kdataset", VolumeSource: v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: p.HelpersHostDir}}},
+		{Name: "tf-conf", VolumeSource: v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: p.HelpersHostDir}}},
+		{
+			Name: "mlboardclient",
+			VolumeSource: v1.VolumeSource{
+				HostPath: &v1.HostPathVolumeSource{Path: p.PythonLibsPath, Type: &dirOrCreate},
+			},
+		},
+	}
+	return volumes, mounts
+}
+
+const helpersCopyScript = `cp -a "$HELPERS_SRC/." "$HELPERS_DIR/" || exit 1`
+
+// helpersStep returns init step copying helpers from HelpersImage. It is
+// nil unless helpers are delivered by init container.
+func (c *BoardConfig) helpersStep() *InitStep {
+	p := c.Platform()
+	// Helper volumes are attached along with secret volumes.
+	if p.HelpersDelivery != HelpersInitContainer || c.Kind == KindServing || len(c.Secrets) == 0 {
+		return nil
+	}
+	image := p.HelpersImage
+	if image == "" {
+		image = p.InitImage
+	}
+	return &InitStep{
+		Name:   helpersVolume,
+		Image:  image,
+		Script: helpersCopyScript,
+		Params: []InitParam{
+			{Name: "HELPERS_SRC", Value: p.HelpersImageDir},
+			{Name: "HELPERS_DIR", Value: helpersInitDir},
+		},
+		Mounts: []v1.VolumeMount{{Name: helpersVolume, MountPath: helpersInitDir}},
+	}
+}
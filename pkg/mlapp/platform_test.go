@@ -0,0 +1,168 @@
+package mlapp
+
+import (
+	"testing"
+
+	"github.com/ghodss/yaml"
+	kuberlab "github.com/kuberlab/lib/pkg/kubernetes"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/version"
+)
+
+var platformTpl = `
+kind: MLApp
+metadata:
+  name: mlapp
+workspace: ws
+workspace_id: "1"
+project_id: "2"
+platform_profile:
+  init_image: registry.local/board-init:1.0
+  helpers_delivery: initContainer
+  helpers_image: registry.local/helpers:1.0
+  pluk_url: http://pluk.local:8082
+secrets:
+  - name: ws-key-1
+    data:
+      token: secret
+volumes_data:
+  - name: src
+    gitRepo:
+      repository: https://github.com/kuberlab/lib
+spec:
+  volumes:
+    - name: src
+      gitRepo:
+        repository: https://github.com/kuberlab/lib
+  uix:
+    - name: jupyter
+      image: tensorflow/tensorflow
+      volumes:
+        - name: src
+          mountPath: /src
+      ports:
+        - port: 80
+          targetPort: 8888
+          protocol: TCP
+          name: http
+  tasks:
+    - name: train
+      resources:
+        - name: worker
+          replicas: 1
+          image: tensorflow/tensorflow
+          command: python
+          resources:
+            accelerators:
+              gpu: 1
+          volumes:
+            - name: src
+              mountPath: /src
+`
+
+func platformConfig(t *testing.T) *BoardConfig {
+	conf := &BoardConfig{}
+	if err := yaml.Unmarshal([]byte(platformTpl), conf); err != nil {
+		t.Fatal(err)
+	}
+	return conf
+}
+
+func TestPlatform(t *testing.T) {
+	conf := &BoardConfig{}
+	Assert(DefaultPlatformProfile, conf.Platform(), t)
+
+	p := platformConfig(t).Platform()
+	Assert("registry.local/board-init:1.0", p.InitImage, t)
+	Assert("http://pluk.local:8082", p.PlukURL, t)
+	// Empty fields are taken from defaults.
+	Assert(DefaultPlatformProfile.HelpersImageDir, p.HelpersImageDir, t)
+	Assert(DefaultPlatformProfile.GPUTolerations, p.GPUTolerations, t)
+}
+
+func TestPlatformUIXResources(t *testing.T) {
+	conf := platformConfig(t)
+	resources, err := conf.GenerateUIXResources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert(2, len(resources), t)
+	config := resources[0].Object.(*v1.ConfigMap)
+	Assert("pluk_url: 'http://pluk.local:8082'\n", config.Data["config"], t)
+
+	pod := resources[1].Object.(*appsv1.Deployment).Spec.Template.Spec
+	// Helpers are copied by the first init container, then repository is
+	// cloned with the init image of the platform.
+	Assert(2, len(pod.InitContainers), t)
+	helpers := pod.InitContainers[0]
+	Assert(helpersVolume, helpers.Name, t)
+	Assert("registry.local/helpers:1.0", helpers.Image, t)
+	Assert([]v1.VolumeMount{{Name: helpersVolume, MountPath: helpersInitDir}}, helpers.VolumeMounts, t)
+	Assert("registry.local/board-init:1.0", pod.InitContainers[1].Image, t)
+
+	found := false
+	for _, v := range pod.Volumes {
+		if v.Name == helpersVolume {
+			found = true
+			Assert(true, v.EmptyDir != nil, t)
+		}
+		if v.HostPath != nil {
+			t.Fatalf("Unexpected host path volume %v", v.Name)
+		}
+	}
+	Assert(true, found, t)
+	mounts := map[string]v1.VolumeMount{}
+	for _, m := range pod.Containers[0].VolumeMounts {
+		mounts[m.MountPath] = m
+	}
+	Assert(helpersVolume, mounts["/usr/bin/kdataset"].Name, t)
+	Assert(helpersLibsPath, mounts[kibernetikaPythonLibs].SubPath, t)
+
+	// Only default tolerations without GPU.
+	Assert(DefaultPlatformProfile.DefaultTolerations, pod.Tolerations, t)
+}
+
+func TestPlatformTaskResources(t *testing.T) {
+	kubeVersion := kuberlab.MlBoardKubeVersion
+	defer func() { kuberlab.MlBoardKubeVersion = kubeVersion }()
+	kuberlab.MlBoardKubeVersion = &version.Info{Major: "1", Minor: "22"}
+
+	conf := platformConfig(t)
+	conf.PlatformProfile.HelpersDelivery = HelpersHostPath
+	specs, err := conf.GenerateTaskResources(conf.Tasks[0], "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := specs[0].Resource.Object.(*kuberlab.WorkerSet).PodTemplate.Spec
+	// No helpers init container with host path delivery.
+	Assert(1, len(pod.InitContainers), t)
+	Assert("registry.local/board-init:1.0", pod.InitContainers[0].Image, t)
+	hostPaths := map[string]string{}
+	for _, v := range pod.Volumes {
+		if v.HostPath != nil {
+			hostPaths[v.Name] = v.HostPath.Path
+		}
+	}
+	Assert("/usr/bin/", hostPaths["kdataset"], t)
+	Assert(kibernetikaPythonLibs, hostPaths["mlboardclient"], t)
+
+	// GPU tolerations follow default ones.
+	want := append(append([]v1.Toleration{}, DefaultPlatformProfile.DefaultTolerations...), DefaultPlatformProfile.GPUTolerations...)
+	Assert(want, pod.Tolerations, t)
+
+	conf.PlatformProfile.GPUTolerations = []v1.Toleration{{Key: "gpu", Operator: v1.TolerationOpExists}}
+	conf.PlatformProfile.HelpersDelivery = HelpersNone
+	specs, err = conf.GenerateTaskResources(conf.Tasks[0], "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod = specs[0].Resource.Object.(*kuberlab.WorkerSet).PodTemplate.Spec
+	want = append(append([]v1.Toleration{}, DefaultPlatformProfile.DefaultTolerations...), conf.PlatformProfile.GPUTolerations...)
+	Assert(want, pod.Tolerations, t)
+	for _, v := range pod.Volumes {
+		if v.Name == "kdataset" || v.Name == helpersVolume {
+			t.Fatalf("Helpers are delivered with %v", HelpersNone)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package mlapp
+
+import (
+	"strings"
+	"time"
+
+	kuberlab "github.com/kuberlab/lib/pkg/kubernetes"
+	"github.com/kuberlab/lib/pkg/utils"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Price is cost of resources per hour.
+type Price struct {
+	CPUCoreHour   float64 `json:"cpu_core_hour,omitempty"`
+	MemoryGiBHour float64 `json:"memory_gib_hour,omitempty"`
+	GPUHour       float64 `json:"gpu_hour,omitempty"`
+}
+
+// NodePrice overrides default prices on nodes with ml-node label.
+type NodePrice struct {
+	// Non-zero prices replace default ones
+	Price `json:",inline"`
+	// Accelerator type of the nodes, GPU-hour is taken from
+	// PricingModel.Accelerators if set
+	Accelerator string `json:"accelerator,omitempty"`
+}
+
+type PricingModel struct {
+	Currency string `json:"currency,omitempty"`
+	Default  Price  `json:"default"`
+	// GPU-hour by accelerator type
+	Accelerators map[string]float64 `json:"accelerators,omitempty"`
+	// Prices by value of ml-node label
+	Nodes map[string]NodePrice `json:"nodes,omitempty"`
+}
+
+// ComponentCost is estimated cost of the component with all replicas.
+type ComponentCost struct {
+	Name      string          `json:"name"`
+	Replicas  int             `json:"replicas"`
+	Node      string          `json:"node,omitempty"`
+	Resources ResourceRequest `json:"resources"`
+	Hourly    float64         `json:"hourly"`
+}
+
+type CostEstimate struct {
+	Currency   string          `json:"currency,omitempty"`
+	Components []ComponentCost `json:"components"`
+	Hourly     float64         `json:"hourly"`
+	// Cost of the run for tasks, zero if duration is unknown
+	PerRun   float64       `json:"per_run,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+func (m *PricingModel) price(node string) Price {
+	p := m.Default
+	n, ok := m.Nodes[node]
+	if !ok {
+		return p
+	}
+	if n.CPUCoreHour > 0 {
+		p.CPUCoreHour = n.CPUCoreHour
+	}
+	if n.MemoryGiBHour > 0 {
+		p.MemoryGiBHour = n.MemoryGiBHour
+	}
+	if n.GPUHour > 0 {
+		p.GPUHour = n.GPUHour
+	}
+	if gpu, ok := m.Accelerators[n.Accelerator]; ok && n.Accelerator != "" {
+		p.GPUHour = gpu
+	}
+	return p
+}
+
+func (m *PricingModel) hourly(cpu, memory *resource.Quantity, gpu int64, node string) float64 {
+	p := m.price(node)
+	cost := float64(gpu) * p.GPUHour
+	if cpu != nil {
+		cost += float64(cpu.MilliValue()) / 1000 * p.CPUCoreHour
+	}
+	if memory != nil {
+		cost += float64(memory.Value()) / (1 << 30) * p.MemoryGiBHour
+	}
+	return cost
+}
+
+// HourlyCost returns cost of one replica with resources computed by
+// ResourceSpec. Requests are charged, limits are used if requests are not set.
+func (m *PricingModel) HourlyCost(spec ResourceRequest, node string) float64 {
+	cpu, memory := spec.Requests.CPUQuantity(), spec.Requests.MemoryQuantity()
+	if cpu == nil {
+		cpu = spec.Limits.CPUQuantity()
+	}
+	if memory == nil {
+		memory = spec.Limits.MemoryQuantity()
+	}
+	return m.hourly(cpu, memory, int64(spec.Accelerators.GPU), node)
+}
+
+// nodeLabel returns ml-node label the component is scheduled to.
+func nodeLabel(r Resource, gpu uint) string {
+	if r.NodesLabel != "" {
+		return strings.TrimPrefix(r.NodesLabel, "knode:")
+	}
+	if gpu > 0 && utils.GetDefaultGPUNodeSelector() != "" {
+		return utils.GetDefaultGPUNodeSelector()
+	}
+	return utils.GetDefaultCPUNodeSelector()
+}
+
+func (c *BoardConfig) componentCost(m *PricingModel, name string, r Resource, replicas int) ComponentCost {
+	spec, _ := c.ExplainResources(r.Resources)
+	node := nodeLabel(r, spec.Accelerators.GPU)
+	return ComponentCost{
+		Name:      name,
+		Replicas:  replicas,
+		Node:      node,
+		Resources: spec,
+		Hourly:    m.HourlyCost(spec, node) * float64(replicas),
+	}
+}
+
+func (m *PricingModel) estimate(components []ComponentCost, duration time.Duration) *CostEstimate {
+	e := &CostEstimate{Currency: m.Currency, Components: components, Duration: duration}
+	for _, c := range components {
+		e.Hourly += c.Hourly
+	}
+	e.PerRun = e.Hourly * duration.Hours()
+	return e
+}
+
+func replicasOrOne(replicas int) int {
+	if replicas > 0 {
+		return replicas
+	}
+	return 1
+}
+
+// EstimateUIXCost estimates hourly cost of enabled Uix.
+func (c *BoardConfig) EstimateUIXCost(m *PricingModel) *CostEstimate {
+	var components []ComponentCost
+	for _, uix := range c.Uix {
+		g := UIXResourceGenerator{c: c, Uix: uix}
+		if g.Replicas() == 0 {
+			continue
+		}
+		components = append(components, c.componentCost(m, uix.Name, uix.Resource, g.Replicas()))
+	}
+	return m.estimate(components, 0)
+}
+
+// EstimateTaskCost estimates cost of the task run lasting duration. If
+// duration is zero, maximum execution time of the workspace is used.
+func (c *BoardConfig) EstimateTaskCost(m *PricingModel, task Task, duration time.Duration) *CostEstimate {
+	if duration == 0 {
+		duration = time.Duration(c.ActiveDeadlineSeconds()) * time.Second
+	}
+	var components []ComponentCost
+	for _, r := range task.Resources {
+		components = append(components, c.componentCost(m, r.Name, r.Resource, replicasOrOne(r.Replicas)))
+	}
+	return m.estimate(components, duration)
+}
+
+// EstimateServingCost estimates hourly cost of the serving, autoscaled
+// servings are estimated with minimal replicas.
+func (c *BoardConfig) EstimateServingCost(m *PricingModel, s UniversalServing) *CostEstimate {
+	replicas := replicasOrOne(s.Replicas)
+	if s.Autoscale != nil && s.Autoscale.Enabled && s.Autoscale.MinReplicas > 0 {
+		replicas = int(s.Autoscale.MinReplicas)
+	}
+	return m.estimate([]ComponentCost{c.componentCost(m, s.Name, s.Resource, replicas)}, 0)
+}
+
+// ActualCost returns cost of the component pods from their lifetimes,
+// running pods are charged till now. Finished pods without finish time are
+// not charged.
+func (m *PricingModel) ActualCost(state *kuberlab.ComponentState, now time.Time) float64 {
+	cost := 0.0
+	for _, rs := range state.ResourceStates {
+		if rs.StartTime == nil {
+			continue
+		}
+		finish := now
+		if rs.FinishTime != nil {
+			finish = rs.FinishTime.Time
+		} else if rs.Status == string(v1.PodFailed) || rs.Status == string(v1.PodSucceeded) {
+			continue
+		}
+		hours := finish.Sub(rs.StartTime.Time).Hours()
+		if hours <= 0 {
+			continue
+		}
+		cpu, memory := quantityOf(rs.Resources, v1.ResourceCPU), quantityOf(rs.Resources, v1.ResourceMemory)
+		gpu := kuberlab.NvidiaGPU(&rs.Resources.Limits).Value()
+		cost += m.hourly(cpu, memory, gpu, rs.MLNode) * hours
+	}
+	return cost
+}
+
+func quantityOf(r v1.ResourceRequirements, name v1.ResourceName) *resource.Quantity {
+	if q, ok := r.Requests[name]; ok {
+		return &q
+	}
+	if q, ok := r.Limits[name]; ok {
+		return &q
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package mlapp
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kuberlab/lib/pkg/dealerclient"
+	kuberlab "github.com/kuberlab/lib/pkg/kubernetes"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEstimateCost(t *testing.T) {
+	m := &PricingModel{
+		Currency:     "USD",
+		Default:      Price{CPUCoreHour: 0.05, MemoryGiBHour: 0.01, GPUHour: 1},
+		Accelerators: map[string]float64{"v100": 3},
+		Nodes:        map[string]NodePrice{"gpu-v100": {Accelerator: "v100"}},
+	}
+	cpu := resource.MustParse("2")
+	mem := resource.MustParse("4Gi")
+	c := &BoardConfig{}
+	c.BoardMetadata.Limits = &dealerclient.ResourceLimit{ExecutionTime: 120}
+	task := Task{Resources: []TaskResource{{Resource: Resource{
+		Replicas:   4,
+		NodesLabel: "knode:gpu-v100",
+		Resources: &ResourceRequest{
+			Accelerators: ResourceAccelerators{GPU: 1},
+			Requests:     &dealerclient.ResourceLimit{CPU: &cpu, Memory: &mem},
+		},
+	}}}}
+	task.Resources[0].Name = "worker"
+
+	e := c.EstimateTaskCost(m, task, 0)
+	Assert("gpu-v100", e.Components[0].Node, t)
+	// (2*0.05 + 4*0.01 + 3) * 4 replicas
+	Assert("12.56", formatCost(e.Hourly), t)
+	Assert(2*time.Hour, e.Duration, t)
+	Assert("25.12", formatCost(e.PerRun), t)
+
+	e = c.EstimateTaskCost(m, task, 30*time.Minute)
+	Assert("6.28", formatCost(e.PerRun), t)
+
+	start := meta_v1.NewTime(time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC))
+	finish := meta_v1.NewTime(start.Add(90 * time.Minute))
+	state := &kuberlab.ComponentState{ResourceStates: []*kuberlab.ResourceState{
+		{
+			MLNode:     "gpu-v100",
+			StartTime:  &start,
+			FinishTime: &finish,
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceCPU: cpu},
+				Limits:   v1.ResourceList{kuberlab.ResourceNvidiaGPU: resource.MustParse("1")},
+			},
+		},
+		{
+			StartTime: &start,
+			Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceMemory: mem}},
+		},
+		{Status: "Pending"},
+		// Finished without known finish time, e.g. evicted.
+		{
+			Status:    string(v1.PodFailed),
+			StartTime: &start,
+			Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: cpu}},
+		},
+	}}
+	// 1.5h * (0.1 + 3) + 2h * 0.04
+	Assert("4.73", formatCost(m.ActualCost(state, start.Add(2*time.Hour))), t)
+}
+
+func formatCost(v float64) string {
+	return fmt.Sprintf("%.2f", v)
+}
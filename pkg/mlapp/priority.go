@@ -0,0 +1,99 @@
+package mlapp
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/kuberlab/lib/pkg/errors"
+	kuberlab "github.com/kuberlab/lib/pkg/kubernetes"
+	"k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func (p PlatformProfile) priorityClass(level string) *PriorityClass {
+	for i := range p.PriorityClasses {
+		if p.PriorityClasses[i].Level == level {
+			return &p.PriorityClasses[i]
+		}
+	}
+	return nil
+}
+
+// PriorityClassResources returns PriorityClass objects of the priority
+// levels, they are created once per cluster.
+func (p PlatformProfile) PriorityClassResources() []*kuberlab.KubeResource {
+	var resources []*kuberlab.KubeResource
+	for _, pc := range p.PriorityClasses {
+		obj := &schedulingv1.PriorityClass{
+			TypeMeta:    meta_v1.TypeMeta{Kind: "PriorityClass", APIVersion: "scheduling.k8s.io/v1"},
+			ObjectMeta:  meta_v1.ObjectMeta{Name: pc.Name},
+			Value:       pc.Value,
+			Description: pc.Description,
+		}
+		if pc.NonPreempting {
+			never := v1.PreemptNever
+			obj.PreemptionPolicy = &never
+		}
+		gv := obj.GroupVersionKind()
+		resources = append(resources, &kuberlab.KubeResource{
+			Name:   pc.Name + ":priority-class",
+			Kind:   &gv,
+			Object: obj,
+		})
+	}
+	return resources
+}
+
+// checkPriority checks that priority level of the resource exists and
+// doesn't exceed workspace MaxPriority. Priority is rejected if MaxPriority
+// is not a known level.
+func (c *BoardConfig) checkPriority(res Resource, resName string) error {
+	if res.Priority == "" {
+		return nil
+	}
+	p := c.Platform()
+	pc := p.priorityClass(res.Priority)
+	if pc == nil {
+		return errors.NewStatusReason(
+			http.StatusBadRequest,
+			fmt.Sprintf("Invalid priority '%v' for resource %v", res.Priority, resName),
+			"Unknown priority level",
+		)
+	}
+	if c.BoardMetadata.Limits == nil || c.BoardMetadata.Limits.MaxPriority == "" {
+		return nil
+	}
+	max := p.priorityClass(c.BoardMetadata.Limits.MaxPriority)
+	if max == nil {
+		// Limit can't be checked, so no priority is allowed.
+		return errors.NewStatusReason(
+			http.StatusForbidden,
+			fmt.Sprintf(
+				"Invalid priority '%v' for resource %v: unknown workspace maximum priority '%v'",
+				res.Priority, resName, c.BoardMetadata.Limits.MaxPriority,
+			),
+			"Workspace priority limit is not configured on the cluster",
+		)
+	}
+	if pc.Value > max.Value {
+		return errors.NewStatusReason(
+			http.StatusForbidden,
+			fmt.Sprintf(
+				"Invalid priority '%v' for resource %v: maximum allowed: %v",
+				res.Priority, resName, c.BoardMetadata.Limits.MaxPriority,
+			),
+			"Priority exceeds workspace limit",
+		)
+	}
+	return nil
+}
+
+// priorityClassName returns PriorityClass of the resource, empty if
+// priority is not set.
+func (c *BoardConfig) priorityClassName(res Resource) string {
+	if pc := c.Platform().priorityClass(res.Priority); pc != nil && res.Priority != "" {
+		return pc.Name
+	}
+	return ""
+}
@@ -0,0 +1,49 @@
+package mlapp
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/kuberlab/lib/pkg/dealerclient"
+	"github.com/kuberlab/lib/pkg/errors"
+	kuberlab "github.com/kuberlab/lib/pkg/kubernetes"
+	"k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+)
+
+func TestPriority(t *testing.T) {
+	resources := DefaultPlatformProfile.PriorityClassResources()
+	Assert(3, len(resources), t)
+	low := resources[0].Object.(*schedulingv1.PriorityClass)
+	Assert("kuberlab-low", low.Name, t)
+	Assert(v1.PreemptNever, *low.PreemptionPolicy, t)
+
+	c := gitTaskConfig("https://github.com/kuberlab/lib")
+	task := gitTask("master")
+	task.Resources[0].Priority = "urgent"
+	_, err := c.GenerateTaskResources(task, "1")
+	Assert(http.StatusBadRequest, err.(*errors.Error).Status, t)
+
+	c.BoardMetadata.Limits = &dealerclient.ResourceLimit{MaxPriority: "normal"}
+	task.Resources[0].Priority = "high"
+	_, err = c.GenerateTaskResources(task, "1")
+	Assert(http.StatusForbidden, err.(*errors.Error).Status, t)
+
+	task.Resources[0].Priority = "low"
+	specs, err := c.GenerateTaskResources(task, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := specs[0].Resource.Object.(*kuberlab.WorkerSet).PodTemplate
+	Assert("kuberlab-low", pod.Spec.PriorityClassName, t)
+
+	// Unknown maximum doesn't allow any priority.
+	c.BoardMetadata.Limits.MaxPriority = "urgent"
+	_, err = c.GenerateTaskResources(task, "1")
+	Assert(http.StatusForbidden, err.(*errors.Error).Status, t)
+
+	task.Resources[0].Priority = ""
+	specs, _ = c.GenerateTaskResources(task, "1")
+	pod = specs[0].Resource.Object.(*kuberlab.WorkerSet).PodTemplate
+	Assert("", pod.Spec.PriorityClassName, t)
+}
@@ -0,0 +1,217 @@
+package mlapp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/kuberlab/lib/pkg/dealerclient"
+	"github.com/kuberlab/lib/pkg/errors"
+	"github.com/kuberlab/lib/pkg/types"
+	"github.com/kuberlab/lib/pkg/utils"
+	"k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const gpuResourceName = v1.ResourceName("nvidia.com/gpu")
+
+// Usage is amount of workspace resources used or requested by components.
+type Usage struct {
+	CPUMi    int64 `json:"cpu_mi"`
+	MemoryMB int64 `json:"memory_mb"`
+	GPU      int64 `json:"gpu"`
+	// Running tasks
+	Runs int64 `json:"runs"`
+}
+
+func (u Usage) add(o Usage) Usage {
+	return Usage{
+		CPUMi:    u.CPUMi + o.CPUMi,
+		MemoryMB: u.MemoryMB + o.MemoryMB,
+		GPU:      u.GPU + o.GPU,
+		Runs:     u.Runs + o.Runs,
+	}
+}
+
+// RequestedUsage sums requests of components as they are generated by
+// ResourceSpec with the workspace limits, multiplied by replicas. GPUs are
+// counted as requested. Launch of a task is one run.
+func RequestedUsage(r ComponentRequests, limits *dealerclient.ResourceLimit) Usage {
+	u := Usage{}
+	for _, comp := range gpuComponents(r) {
+		if comp.disabled() {
+			continue
+		}
+		replicas := int64(replicasOrOne(comp.res.Replicas))
+		spec := ResourceSpec(comp.res.Resources, limits, DefaultResourceRequests())
+		if q := spec.Requests.CPUQuantity(); q != nil {
+			u.CPUMi += q.MilliValue() * replicas
+		}
+		if q := spec.Requests.MemoryQuantity(); q != nil {
+			u.MemoryMB += q.ScaledValue(6) * replicas
+		}
+		u.GPU += int64(comp.gpu()) * replicas
+	}
+	if r.Type() == KindTask {
+		u.Runs = 1
+	}
+	return u
+}
+
+// QuotaChecker checks workspace limits against resources used by running
+// Uix, tasks and servings of the workspace.
+type QuotaChecker struct {
+	Client kubernetes.Interface
+}
+
+// WorkspaceUsage sums resources of active pods in the workspace. Pods for
+// which skip returns true are not counted.
+func (q *QuotaChecker) WorkspaceUsage(ctx context.Context, workspaceID string, skip func(pod *v1.Pod) bool) (Usage, error) {
+	pods, err := q.Client.CoreV1().Pods(meta_v1.NamespaceAll).List(
+		ctx,
+		resourceSelector(map[string]string{KUBERLAB_WS_ID_LABEL: workspaceID}),
+	)
+	if err != nil {
+		return Usage{}, err
+	}
+	u := Usage{}
+	runs := make(map[string]bool)
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed || pod.DeletionTimestamp != nil {
+			continue
+		}
+		if skip != nil && skip(pod) {
+			continue
+		}
+		u = u.add(podUsage(pod))
+		if jobID := pod.Labels[types.TASK_ID_LABEL]; jobID != "" {
+			runs[jobID] = true
+		}
+	}
+	u.Runs = int64(len(runs))
+	return u, nil
+}
+
+// podUsage sums requests of containers like ResourceQuota of the
+// workspace, limit is used if request is not set.
+func podUsage(pod *v1.Pod) Usage {
+	u := Usage{}
+	for _, c := range pod.Spec.Containers {
+		value := func(name v1.ResourceName, scaled func(q v1.ResourceList) int64) int64 {
+			if _, ok := c.Resources.Requests[name]; ok {
+				return scaled(c.Resources.Requests)
+			}
+			if _, ok := c.Resources.Limits[name]; ok {
+				return scaled(c.Resources.Limits)
+			}
+			return 0
+		}
+		u.CPUMi += value(v1.ResourceCPU, func(l v1.ResourceList) int64 { return l.Cpu().MilliValue() })
+		u.MemoryMB += value(v1.ResourceMemory, func(l v1.ResourceList) int64 { return l.Memory().ScaledValue(6) })
+		u.GPU += value(gpuResourceName, func(l v1.ResourceList) int64 {
+			gpu := l[gpuResourceName]
+			return gpu.Value()
+		})
+	}
+	return u
+}
+
+// CheckQuota checks the launch against workspace limits. Launch which
+// exceeds limits by itself is rejected with 403, launch which doesn't fit
+// because of used resources gets 429 and may be queued and retried.
+func CheckQuota(limits *dealerclient.ResourceLimit, used, requested Usage) error {
+	if limits == nil {
+		return nil
+	}
+	type check struct {
+		name      string
+		limit     int64
+		used      int64
+		requested int64
+	}
+	var checks []check
+	if q := limits.CPUQuantity(); q != nil {
+		checks = append(checks, check{"CPU (millicores)", q.MilliValue(), used.CPUMi, requested.CPUMi})
+	}
+	if q := limits.MemoryQuantity(); q != nil {
+		checks = append(checks, check{"memory (MB)", q.ScaledValue(6), used.MemoryMB, requested.MemoryMB})
+	}
+	if q := limits.GPUQuantity(); q != nil {
+		checks = append(checks, check{"GPU", q.Value(), used.GPU, requested.GPU})
+	}
+	if limits.ParallelRuns > 0 {
+		checks = append(checks, check{"parallel runs", limits.ParallelRuns, used.Runs, requested.Runs})
+	}
+	for _, c := range checks {
+		if c.requested == 0 {
+			continue
+		}
+		if c.requested > c.limit {
+			return errors.NewStatusReason(
+				http.StatusForbidden,
+				fmt.Sprintf("Workspace quota exceeded: requested %v %v, limit is %v", c.requested, c.name, c.limit),
+				"Reduce requested resources or ask to increase workspace limits",
+			)
+		}
+		if c.used+c.requested > c.limit {
+			return errors.NewStatusReason(
+				http.StatusTooManyRequests,
+				fmt.Sprintf(
+					"Workspace quota exceeded: requested %v %v, %v of %v are in use",
+					c.requested, c.name, c.used, c.limit,
+				),
+				"Launch will be possible after running components release resources",
+			)
+		}
+	}
+	return nil
+}
+
+// CheckQuota checks launch of the components against workspace limits.
+// Running pods of the components being launched are not counted.
+func (q *QuotaChecker) CheckQuota(ctx context.Context, c *BoardConfig, launch ComponentRequests) error {
+	limits := c.BoardMetadata.Limits
+	if limits == nil {
+		return nil
+	}
+	used, err := q.WorkspaceUsage(ctx, c.WorkspaceID, relaunchedPods(c, launch))
+	if err != nil {
+		return fmt.Errorf("Failed get workspace usage: %v", err)
+	}
+	return CheckQuota(limits, used, RequestedUsage(launch, limits))
+}
+
+// checkRequestedQuota rejects components which exceed workspace limits
+// regardless of workspace usage.
+func (c *BoardConfig) checkRequestedQuota(r ComponentRequests) error {
+	return CheckQuota(c.BoardMetadata.Limits, Usage{}, RequestedUsage(r, c.BoardMetadata.Limits))
+}
+
+// relaunchedPods returns pods replaced by the launch.
+func relaunchedPods(c *BoardConfig, launch ComponentRequests) func(pod *v1.Pod) bool {
+	return func(pod *v1.Pod) bool {
+		l := pod.Labels
+		switch s := launch.(type) {
+		case *BoardConfig:
+			return l[KUBERLAB_PROJECT_ID] == utils.KubeLabelEncode(c.ProjectID) && l[types.ComponentTypeLabel] == "ui"
+		case *Serving:
+			name := fmt.Sprintf("%s-%s-%s", s.Name, s.TaskName, s.Build)
+			return l[KUBERLAB_PROJECT_ID] == utils.KubeLabelEncode(c.ProjectID) &&
+				l[types.ServingIDLabel] == utils.KubeLabelEncode(name)
+		case *ModelServing:
+			return l[types.ServingIDLabel] == utils.KubeLabelEncode(s.Name)
+		}
+		return false
+	}
+}
+
+// ActiveDeadlineSeconds returns limit of task execution time, ExecutionTime
+// of workspace limits is in minutes.
+func (c *BoardConfig) ActiveDeadlineSeconds() int64 {
+	if c.BoardMetadata.Limits == nil || c.BoardMetadata.Limits.ExecutionTime <= 0 {
+		return 0
+	}
+	return c.BoardMetadata.Limits.ExecutionTime * 60
+}
@@ -0,0 +1,121 @@
+package mlapp
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/kuberlab/lib/pkg/dealerclient"
+	"github.com/kuberlab/lib/pkg/errors"
+	"github.com/kuberlab/lib/pkg/types"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func quotaPod(name string, phase v1.PodPhase, labels map[string]string, cpu, mem string, gpu int64) *v1.Pod {
+	l := map[string]string{KUBERLAB_WS_ID_LABEL: "1"}
+	for k, v := range labels {
+		l[k] = v
+	}
+	limits := v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse(cpu),
+		v1.ResourceMemory: resource.MustParse(mem),
+	}
+	if gpu > 0 {
+		limits[gpuResourceName] = *resource.NewQuantity(gpu, resource.DecimalSI)
+	}
+	return &v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{Name: name, Namespace: "ns", Labels: l},
+		Spec: v1.PodSpec{Containers: []v1.Container{
+			{Name: "main", Resources: v1.ResourceRequirements{Limits: limits}},
+		}},
+		Status: v1.PodStatus{Phase: phase},
+	}
+}
+
+func TestQuotaChecker(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		quotaPod("ui", v1.PodRunning, map[string]string{KUBERLAB_PROJECT_ID: "2", types.ComponentTypeLabel: "ui"}, "1", "1Gi", 0),
+		quotaPod("worker-0", v1.PodRunning, map[string]string{types.TASK_ID_LABEL: "10"}, "2", "2Gi", 1),
+		quotaPod("worker-1", v1.PodPending, map[string]string{types.TASK_ID_LABEL: "10"}, "2", "2Gi", 1),
+		quotaPod("old", v1.PodSucceeded, map[string]string{types.TASK_ID_LABEL: "9"}, "8", "8Gi", 4),
+	)
+	q := &QuotaChecker{Client: client}
+	used, err := q.WorkspaceUsage(context.Background(), "1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert(Usage{CPUMi: 5000, MemoryMB: 5370, GPU: 2, Runs: 1}, used, t)
+
+	gpu := int64(3)
+	c := &BoardConfig{}
+	c.WorkspaceID = "1"
+	c.ProjectID = "2"
+	c.BoardMetadata.Limits = &dealerclient.ResourceLimit{GPU: &gpu, ParallelRuns: 2}
+	task := &Task{Resources: []TaskResource{{Resource: Resource{Resources: &ResourceRequest{
+		Accelerators: ResourceAccelerators{GPU: 1},
+	}}}}}
+	task.Resources[0].Name = "worker"
+	if err := q.CheckQuota(context.Background(), c, task); err != nil {
+		t.Fatal(err)
+	}
+
+	task.Resources[0].Resources.Accelerators.GPU = 2
+	err = q.CheckQuota(context.Background(), c, task)
+	Assert(http.StatusTooManyRequests, err.(*errors.Error).Status, t)
+
+	task.Resources[0].Resources.Accelerators.GPU = 4
+	err = q.CheckQuota(context.Background(), c, task)
+	Assert(http.StatusForbidden, err.(*errors.Error).Status, t)
+	Assert("Workspace quota exceeded: requested 4 GPU, limit is 3", err.Error(), t)
+
+	c.BoardMetadata.Limits.ParallelRuns = 1
+	task.Resources[0].Resources.Accelerators.GPU = 0
+	err = q.CheckQuota(context.Background(), c, task)
+	Assert(http.StatusTooManyRequests, err.(*errors.Error).Status, t)
+
+	// Uix of the project are replaced on relaunch.
+	cpu := resource.MustParse("5")
+	c.BoardMetadata.Limits = &dealerclient.ResourceLimit{CPU: &cpu}
+	c.Uix = []Uix{{Resource: Resource{Resources: &ResourceRequest{
+		Requests: &dealerclient.ResourceLimit{CPU: &cpu},
+	}}}}
+	err = q.CheckQuota(context.Background(), c, c)
+	Assert(http.StatusTooManyRequests, err.(*errors.Error).Status, t)
+	c.Uix[0].Resources.Requests.CPU = resource.NewMilliQuantity(1000, resource.DecimalSI)
+	if err := q.CheckQuota(context.Background(), c, c); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRequestedUsage(t *testing.T) {
+	mem := resource.MustParse("4Gi")
+	limits := &dealerclient.ResourceLimit{Memory: &mem}
+	c := &BoardConfig{}
+	c.BoardMetadata.Limits = limits
+	requested := resource.MustParse("8Gi")
+	task := &Task{Resources: []TaskResource{{Resource: Resource{Resources: &ResourceRequest{
+		Requests: &dealerclient.ResourceLimit{Memory: &requested},
+	}}}}}
+	task.Resources[0].Name = "worker"
+
+	// Request is reduced to the workspace limit like in ExplainResources.
+	spec, _ := c.ExplainResources(task.Resources[0].Resources)
+	Assert("4Gi", spec.Requests.Memory.String(), t)
+	Assert(int64(4295), RequestedUsage(task, limits).MemoryMB, t)
+	if err := c.checkRequestedQuota(task); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each replica gets the request.
+	task.Resources[0].Replicas = 4
+	Assert(Usage{MemoryMB: 4 * 4295, Runs: 1}, RequestedUsage(task, limits), t)
+	err := c.checkRequestedQuota(task)
+	Assert(http.StatusForbidden, err.(*errors.Error).Status, t)
+
+	// Components without requests use defaults.
+	task.Resources[0].Resources = nil
+	Assert(Usage{MemoryMB: 4 * 135, Runs: 1}, RequestedUsage(task, limits), t)
+}
@@ -1,7 +1,11 @@
 package mlapp
 
 import (
+	"fmt"
+	"sort"
+
 	"github.com/kuberlab/lib/pkg/dealerclient"
+	"k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 )
 
@@ -9,6 +13,8 @@ type ResourceRequest struct {
 	Accelerators ResourceAccelerators        `json:"accelerators"`
 	Requests     *dealerclient.ResourceLimit `json:"requests,omitempty"`
 	Limits       *dealerclient.ResourceLimit `json:"limits,omitempty"`
+	// Size of memory-backed /dev/shm, counted in memory of the container
+	SharedMemory *resource.Quantity `json:"shared_memory,omitempty"`
 }
 
 func (r *ResourceRequest) CPUMemLimits() (int64, int64) {
@@ -37,7 +43,46 @@ type ResourceAccelerators struct {
 	GPU uint `json:"gpu"`
 }
 
+// Sources of effective resource values, see QuantityTrace.
+const (
+	SourceNone           = "none"
+	SourceUser           = "user"
+	SourceDefault        = "default"
+	SourceUserLimit      = "user-limit"
+	SourceWorkspaceLimit = "workspace-limit"
+)
+
+// QuantityTrace describes where effective value came from.
+type QuantityTrace struct {
+	// Effective value, empty if not set
+	Value string `json:"value,omitempty"`
+	// Value requested by user, empty if not set
+	Requested string `json:"requested,omitempty"`
+	Source    string `json:"source"`
+	Message   string `json:"message"`
+}
+
+type QuantityTraces struct {
+	Request QuantityTrace `json:"request"`
+	Limit   QuantityTrace `json:"limit"`
+}
+
+// ResourceTrace explains computation of ResourceRequest by ResourceSpec.
+type ResourceTrace struct {
+	CPU              QuantityTraces `json:"cpu"`
+	Memory           QuantityTraces `json:"memory"`
+	GPU              QuantityTrace  `json:"gpu"`
+	EphemeralStorage QuantityTraces `json:"ephemeral_storage"`
+	SharedMemory     QuantityTrace  `json:"shared_memory"`
+}
+
 func ResourceSpec(r *ResourceRequest, limitVal *dealerclient.ResourceLimit, defaultReq dealerclient.ResourceLimit) ResourceRequest {
+	res, _ := ResourceSpecTrace(r, limitVal, defaultReq)
+	return res
+}
+
+// ResourceSpecTrace is ResourceSpec which also returns source of each value.
+func ResourceSpecTrace(r *ResourceRequest, limitVal *dealerclient.ResourceLimit, defaultReq dealerclient.ResourceLimit) (ResourceRequest, ResourceTrace) {
 
 	if r == nil {
 		r = &ResourceRequest{}
@@ -51,36 +96,178 @@ func ResourceSpec(r *ResourceRequest, limitVal *dealerclient.ResourceLimit, defa
 		//gpu limit from global
 		gpuLimitCluster = limitVal.GPUQuantity()
 	}
+	var trace ResourceTrace
 	cpuRequest := r.Requests.CPUQuantity()
 	cpuDefault := defaultReq.CPUQuantity()
 	cpuLimit := r.Limits.CPUQuantity()
 	cpuLimitCluster := limitVal.CPUQuantity()
 	cpu1, cpu2 := setQuantity(cpuRequest, cpuDefault, cpuLimit, cpuLimitCluster)
+	trace.CPU = traceQuantity(cpuRequest, cpuDefault, cpuLimit, cpuLimitCluster, cpu1, cpu2)
 
 	memoryRequest := r.Requests.MemoryQuantity()
 	memoryDefault := defaultReq.MemoryQuantity()
 	memoryLimit := r.Limits.MemoryQuantity()
 	memoryLimitCluster := limitVal.MemoryQuantity()
 	memory1, memory2 := setQuantity(memoryRequest, memoryDefault, memoryLimit, memoryLimitCluster)
+	trace.Memory = traceQuantity(memoryRequest, memoryDefault, memoryLimit, memoryLimitCluster, memory1, memory2)
 
 	gpuRequest := quantityUint(r.Accelerators.GPU)
 	gpuDefault := quantityUint(0)
 	gpuLimit := quantityUint(0)
-	gpu1, _ := setQuantity(gpuRequest, gpuDefault, gpuLimit, gpuLimitCluster)
+	gpu1, gpu2 := setQuantity(gpuRequest, gpuDefault, gpuLimit, gpuLimitCluster)
+	trace.GPU = traceQuantity(gpuRequest, gpuDefault, gpuLimit, gpuLimitCluster, gpu1, gpu2).Request
+
+	storageRequest := r.Requests.EphemeralStorageQuantity()
+	storageLimit := r.Limits.EphemeralStorageQuantity()
+	storageLimitCluster := limitVal.EphemeralStorageQuantity()
+	storage1, storage2 := setQuantity(storageRequest, nil, storageLimit, storageLimitCluster)
+	trace.EphemeralStorage = traceQuantity(storageRequest, nil, storageLimit, storageLimitCluster, storage1, storage2)
+
+	// Shared memory is limited by memory of the container as well.
+	shmLimit := minQuantity(limitVal.SharedMemoryQuantity(), memory2)
+	shm, _ := setQuantity(positiveQuantity(r.SharedMemory), nil, nil, shmLimit)
+	trace.SharedMemory = traceQuantity(positiveQuantity(r.SharedMemory), nil, nil, shmLimit, shm, shmLimit).Request
 
-	return ResourceRequest{
+	res := ResourceRequest{
 		Accelerators: ResourceAccelerators{
 			GPU: quantity2Uint(gpu1),
 		},
 		Limits: &dealerclient.ResourceLimit{
-			CPU:    cpu2,
-			Memory: memory2,
+			CPU:              cpu2,
+			Memory:           memory2,
+			EphemeralStorage: storage2,
 		},
 		Requests: &dealerclient.ResourceLimit{
-			CPU:    cpu1,
-			Memory: memory1,
+			CPU:              cpu1,
+			Memory:           memory1,
+			EphemeralStorage: storage1,
 		},
+		SharedMemory: shm,
+	}
+
+	// Hugepages are set only if requested, request must be equal to limit.
+	for _, size := range hugePageSizes(r) {
+		req := r.Limits.HugePagesQuantity(size)
+		if req == nil {
+			req = r.Requests.HugePagesQuantity(size)
+		}
+		value, _ := setQuantity(req, nil, nil, limitVal.HugePagesQuantity(size))
+		if res.Limits.HugePages == nil {
+			res.Limits.HugePages = make(map[string]*resource.Quantity)
+			res.Requests.HugePages = make(map[string]*resource.Quantity)
+		}
+		res.Limits.HugePages[size] = value
+		res.Requests.HugePages[size] = value
+	}
+	return res, trace
+}
+
+func hugePageSizes(r *ResourceRequest) []string {
+	seen := make(map[string]bool)
+	var sizes []string
+	for _, l := range []*dealerclient.ResourceLimit{r.Limits, r.Requests} {
+		if l == nil {
+			continue
+		}
+		for size := range l.HugePages {
+			if l.HugePagesQuantity(size) != nil && !seen[size] {
+				seen[size] = true
+				sizes = append(sizes, size)
+			}
+		}
+	}
+	sort.Strings(sizes)
+	return sizes
+}
+
+func positiveQuantity(q *resource.Quantity) *resource.Quantity {
+	if q == nil || q.Sign() <= 0 {
+		return nil
 	}
+	return q
+}
+
+// DefaultResourceRequests are used for CPU and memory requests of
+// components which don't set them if workspace has limits.
+func DefaultResourceRequests() dealerclient.ResourceLimit {
+	cpu := resource.MustParse("50m")
+	mem := resource.MustParse("128Mi")
+	return dealerclient.ResourceLimit{CPU: &cpu, Memory: &mem}
+}
+
+// ResourceExplanation is effective resources of the component with source
+// of each value.
+type ResourceExplanation struct {
+	Component string          `json:"component"`
+	Resources ResourceRequest `json:"resources"`
+	Trace     ResourceTrace   `json:"trace"`
+}
+
+// ExplainResources returns resources which will be used for the request
+// at launch with explanation of each value.
+func (c *BoardConfig) ExplainResources(r *ResourceRequest) (ResourceRequest, ResourceTrace) {
+	return ResourceSpecTrace(r, c.BoardMetadata.Limits, DefaultResourceRequests())
+}
+
+// ExplainTaskResources explains effective resources of task components,
+// it may be shown before launch.
+func (c *BoardConfig) ExplainTaskResources(task Task) []ResourceExplanation {
+	res := make([]ResourceExplanation, 0, len(task.Resources))
+	for _, r := range task.Resources {
+		spec, trace := c.ExplainResources(r.Resources)
+		res = append(res, ResourceExplanation{Component: r.Name, Resources: spec, Trace: trace})
+	}
+	return res
+}
+
+// traceQuantity explains result of setQuantity.
+func traceQuantity(req, defaultReq, limit, clusterLimit, resReq, resLimit *resource.Quantity) QuantityTraces {
+	t := QuantityTraces{
+		Request: QuantityTrace{Value: quantity2String(resReq), Requested: quantity2String(req)},
+		Limit:   QuantityTrace{Value: quantity2String(resLimit), Requested: quantity2String(limit)},
+	}
+	limitSource := SourceNone
+	switch {
+	case resLimit == nil:
+		t.Limit.Message = "not set"
+	case limit == nil:
+		limitSource = SourceWorkspaceLimit
+		t.Limit.Message = "workspace limit " + resLimit.String()
+	case limit.Cmp(*resLimit) > 0:
+		limitSource = SourceWorkspaceLimit
+		t.Limit.Message = fmt.Sprintf("requested %v, reduced to workspace limit %v", limit, resLimit)
+	default:
+		limitSource = SourceUser
+		t.Limit.Message = "requested " + limit.String()
+	}
+	t.Limit.Source = limitSource
+
+	// Request is reduced to the limit if it exceeds it.
+	clampedSource := limitSource
+	if limitSource == SourceUser {
+		clampedSource = SourceUserLimit
+	}
+	clamped := func(v *resource.Quantity) bool {
+		return v != nil && resLimit != nil && v.Cmp(*resLimit) > 0
+	}
+	switch {
+	case resReq == nil:
+		t.Request.Source = SourceNone
+		t.Request.Message = "not set"
+	case req != nil && clamped(req):
+		t.Request.Source = clampedSource
+		t.Request.Message = fmt.Sprintf("requested %v, reduced to limit %v (%v)", req, resReq, limitSource)
+	case req != nil:
+		t.Request.Source = SourceUser
+		t.Request.Message = "requested " + req.String()
+	case clamped(defaultReq):
+		t.Request.Source = clampedSource
+		t.Request.Message = fmt.Sprintf("default %v reduced to limit %v (%v)", defaultReq, resReq, limitSource)
+	default:
+		t.Request.Source = SourceDefault
+		t.Request.Message = "default " + resReq.String()
+	}
+	return t
 }
 
 func setQuantity(req *resource.Quantity, defaultReq *resource.Quantity, limit *resource.Quantity, clusterLimit *resource.Quantity) (*resource.Quantity, *resource.Quantity) {
@@ -146,3 +333,27 @@ func quantityString(v string) *resource.Quantity {
 	}
 	return &q
 }
+
+const sharedMemoryVolume = "kuberlab-shm"
+
+func withSharedMemoryMount(mounts []v1.VolumeMount, spec ResourceRequest) []v1.VolumeMount {
+	if spec.SharedMemory == nil {
+		return mounts
+	}
+	res := append([]v1.VolumeMount{}, mounts...)
+	return append(res, v1.VolumeMount{Name: sharedMemoryVolume, MountPath: "/dev/shm"})
+}
+
+func withSharedMemoryVolume(volumes []v1.Volume, spec ResourceRequest) []v1.Volume {
+	if spec.SharedMemory == nil {
+		return volumes
+	}
+	res := append([]v1.Volume{}, volumes...)
+	return append(res, v1.Volume{
+		Name: sharedMemoryVolume,
+		VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{
+			Medium:    v1.StorageMediumMemory,
+			SizeLimit: spec.SharedMemory,
+		}},
+	})
+}
@@ -0,0 +1,103 @@
+package mlapp
+
+import (
+	"testing"
+
+	"github.com/kuberlab/lib/pkg/dealerclient"
+	kuberlab "github.com/kuberlab/lib/pkg/kubernetes"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestResourceSpecTrace(t *testing.T) {
+	q := func(v string) *resource.Quantity {
+		r := resource.MustParse(v)
+		return &r
+	}
+	gpu := int64(1)
+	limits := &dealerclient.ResourceLimit{CPU: q("2"), Memory: q("4Gi"), GPU: &gpu}
+	req := &ResourceRequest{
+		Accelerators: ResourceAccelerators{GPU: 2},
+		Requests:     &dealerclient.ResourceLimit{Memory: q("8Gi")},
+		Limits:       &dealerclient.ResourceLimit{CPU: q("1"), Memory: q("16Gi")},
+	}
+	spec, trace := ResourceSpecTrace(req, limits, DefaultResourceRequests())
+	Assert(ResourceSpec(req, limits, DefaultResourceRequests()), spec, t)
+
+	Assert("50m", trace.CPU.Request.Value, t)
+	Assert(SourceDefault, trace.CPU.Request.Source, t)
+	Assert("1", trace.CPU.Limit.Value, t)
+	Assert(SourceUser, trace.CPU.Limit.Source, t)
+
+	Assert("4Gi", trace.Memory.Request.Value, t)
+	Assert("8Gi", trace.Memory.Request.Requested, t)
+	Assert(SourceWorkspaceLimit, trace.Memory.Request.Source, t)
+	Assert("4Gi", trace.Memory.Limit.Value, t)
+	Assert(SourceWorkspaceLimit, trace.Memory.Limit.Source, t)
+	Assert("requested 16Gi, reduced to workspace limit 4Gi", trace.Memory.Limit.Message, t)
+
+	Assert("1", trace.GPU.Value, t)
+	Assert(SourceWorkspaceLimit, trace.GPU.Source, t)
+
+	// Request above user's own limit and no workspace limits.
+	req.Requests.CPU = q("3")
+	spec, trace = ResourceSpecTrace(req, nil, DefaultResourceRequests())
+	Assert("1", spec.Requests.CPU.String(), t)
+	Assert(SourceUserLimit, trace.CPU.Request.Source, t)
+	Assert(SourceUser, trace.Memory.Request.Source, t)
+	Assert(SourceUser, trace.GPU.Source, t)
+
+	// Defaults are not applied without limits.
+	_, trace = ResourceSpecTrace(nil, nil, DefaultResourceRequests())
+	Assert(SourceNone, trace.CPU.Request.Source, t)
+	Assert(SourceNone, trace.Memory.Limit.Source, t)
+}
+
+func TestResourceSpecStorage(t *testing.T) {
+	q := func(v string) *resource.Quantity {
+		r := resource.MustParse(v)
+		return &r
+	}
+	limits := &dealerclient.ResourceLimit{
+		Memory:           q("8Gi"),
+		EphemeralStorage: q("10Gi"),
+		SharedMemory:     q("4Gi"),
+		HugePages:        map[string]*resource.Quantity{"2Mi": q("512Mi")},
+	}
+	req := &ResourceRequest{
+		Requests: &dealerclient.ResourceLimit{EphemeralStorage: q("1Gi")},
+		Limits: &dealerclient.ResourceLimit{
+			Memory:           q("2Gi"),
+			EphemeralStorage: q("20Gi"),
+			HugePages:        map[string]*resource.Quantity{"2Mi": q("1Gi")},
+		},
+		SharedMemory: q("6Gi"),
+	}
+	spec, trace := ResourceSpecTrace(req, limits, DefaultResourceRequests())
+	Assert("1Gi", spec.Requests.EphemeralStorage.String(), t)
+	Assert("10Gi", spec.Limits.EphemeralStorage.String(), t)
+	Assert(SourceWorkspaceLimit, trace.EphemeralStorage.Limit.Source, t)
+	// Shared memory is limited by memory of the container.
+	Assert("2Gi", spec.SharedMemory.String(), t)
+	Assert("6Gi", trace.SharedMemory.Requested, t)
+	Assert("512Mi", spec.Limits.HugePages["2Mi"].String(), t)
+	Assert("512Mi", spec.Requests.HugePages["2Mi"].String(), t)
+
+	c := gitTaskConfig("https://github.com/kuberlab/lib")
+	c.BoardMetadata.Limits = limits
+	task := gitTask("master")
+	task.Resources[0].Resources = req
+	specs, err := c.GenerateTaskResources(task, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := specs[0].Resource.Object.(*kuberlab.WorkerSet).PodTemplate
+	container := pod.Spec.Containers[0]
+	Assert("10Gi", container.Resources.Limits.StorageEphemeral().String(), t)
+	hugepages := container.Resources.Requests[v1.ResourceName("hugepages-2Mi")]
+	Assert("512Mi", hugepages.String(), t)
+	shm := pod.Spec.Volumes[len(pod.Spec.Volumes)-1]
+	Assert(v1.StorageMediumMemory, shm.EmptyDir.Medium, t)
+	Assert("2Gi", shm.EmptyDir.SizeLimit.String(), t)
+	Assert("/dev/shm", container.VolumeMounts[len(container.VolumeMounts)-1].MountPath, t)
+}
@@ -0,0 +1,253 @@
+package mlapp
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/kuberlab/lib/pkg/apputil"
+	"github.com/kuberlab/lib/pkg/errors"
+)
+
+// ResponseLimits bound rendering of the response template.
+type ResponseLimits struct {
+	// Maximum size of the response in bytes
+	MaxSize int
+	Timeout time.Duration
+}
+
+var DefaultResponseLimits = ResponseLimits{MaxSize: 1 << 20, Timeout: time.Second}
+
+// responseFuncs are template functions available in response templates.
+// They don't access environment or network and their results are bounded
+// by size of arguments, e.g. indent, repeat and rand functions allocate
+// size given by the template before the response is limited.
+var responseFuncs = []string{
+	// strings
+	"upper", "lower", "title", "untitle", "trim", "trimAll", "trimPrefix", "trimSuffix",
+	"trunc", "abbrev", "substr", "initials", "contains", "hasPrefix", "hasSuffix",
+	"quote", "squote", "snakecase", "camelcase", "kebabcase", "toString", "toStrings",
+	"splitList", "b64enc", "b64dec",
+	// numbers
+	"add", "add1", "sub", "mul", "div", "mod", "max", "min", "floor", "ceil", "round",
+	"int", "int64", "float64", "atoi",
+	// defaults and encoding
+	"default", "empty", "coalesce", "ternary", "toJson",
+	// lists and dicts
+	"list", "first", "last", "rest", "initial", "has", "compact", "reverse", "sortAlpha",
+	"dict", "get", "hasKey", "keys", "values", "pluck", "pick", "omit",
+}
+
+// maxPrintfWidth bounds width and precision of printf verbs.
+const maxPrintfWidth = 1000
+
+var (
+	printfVerb   = regexp.MustCompile(`%[^a-zA-Z%]*`)
+	printfNumber = regexp.MustCompile(`[0-9]+`)
+)
+
+// printf is fmt.Sprintf rejecting formats which pad the result to a size
+// given by the template.
+func printf(format string, args ...interface{}) (string, error) {
+	for _, verb := range printfVerb.FindAllString(format, -1) {
+		if strings.Contains(verb, "*") {
+			return "", fmt.Errorf("printf: width from arguments is not allowed")
+		}
+		for _, n := range printfNumber.FindAllString(verb, -1) {
+			if v, err := strconv.Atoi(n); err != nil || v > maxPrintfWidth {
+				return "", fmt.Errorf("printf: width %v exceeds %v", n, maxPrintfWidth)
+			}
+		}
+	}
+	return fmt.Sprintf(format, args...), nil
+}
+
+// ResponseFuncMap returns functions available in response templates.
+func ResponseFuncMap() template.FuncMap {
+	all := apputil.FuncMap()
+	f := template.FuncMap{"printf": printf}
+	for _, name := range responseFuncs {
+		if fn, ok := all[name]; ok {
+			f[name] = fn
+		}
+	}
+	return f
+}
+
+// FilterOutputs returns model outputs matching OutFilter, all outputs are
+// returned if it is empty. Filter entries are names or glob patterns.
+func (s ServingSpec) FilterOutputs(outputs map[string]interface{}) map[string]interface{} {
+	if len(s.OutFilter) == 0 {
+		return outputs
+	}
+	res := make(map[string]interface{})
+	for name, v := range outputs {
+		for _, f := range s.OutFilter {
+			if ok, _ := path.Match(f, name); ok {
+				res[name] = v
+				break
+			}
+		}
+	}
+	return res
+}
+
+// errRenderStopped stops rendering which exceeded the timeout.
+var errRenderStopped = fmt.Errorf("rendering stopped")
+
+// limitedBuffer fails writes above max bytes or after rendering is stopped.
+type limitedBuffer struct {
+	bytes.Buffer
+	max     int
+	stopped *int32
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if atomic.LoadInt32(b.stopped) != 0 {
+		return 0, errRenderStopped
+	}
+	if b.Len()+len(p) > b.max {
+		return 0, fmt.Errorf("response exceeds %v bytes", b.max)
+	}
+	return b.Buffer.Write(p)
+}
+
+// stoppableFuncs wraps functions so that they fail after rendering is
+// stopped.
+func stoppableFuncs(funcs template.FuncMap, stopped *int32) template.FuncMap {
+	res := make(template.FuncMap, len(funcs))
+	for name, fn := range funcs {
+		v := reflect.ValueOf(fn)
+		res[name] = reflect.MakeFunc(v.Type(), func(args []reflect.Value) []reflect.Value {
+			if atomic.LoadInt32(stopped) != 0 {
+				panic(errRenderStopped)
+			}
+			if v.Type().IsVariadic() {
+				return v.CallSlice(args)
+			}
+			return v.Call(args)
+		}).Interface()
+	}
+	return res
+}
+
+// checkResponseTemplate rejects templates which work may grow faster than
+// the outputs: nested range and template calls, including recursive ones.
+func checkResponseTemplate(tpl *template.Template) error {
+	if len(tpl.Templates()) > 1 {
+		return fmt.Errorf("template definitions are not allowed")
+	}
+	var check func(node parse.Node, inRange bool) error
+	check = func(node parse.Node, inRange bool) error {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return nil
+			}
+			for _, child := range n.Nodes {
+				if err := check(child, inRange); err != nil {
+					return err
+				}
+			}
+		case *parse.TemplateNode:
+			return fmt.Errorf("template calls are not allowed")
+		case *parse.RangeNode:
+			if inRange {
+				return fmt.Errorf("nested range is not allowed")
+			}
+			if err := check(n.List, true); err != nil {
+				return err
+			}
+			return check(n.ElseList, false)
+		case *parse.IfNode:
+			if err := check(n.List, inRange); err != nil {
+				return err
+			}
+			return check(n.ElseList, inRange)
+		case *parse.WithNode:
+			if err := check(n.List, inRange); err != nil {
+				return err
+			}
+			return check(n.ElseList, inRange)
+		}
+		return nil
+	}
+	return check(tpl.Tree.Root, false)
+}
+
+// RenderResponse filters model outputs and renders ResponseTemplate with
+// them. Filtered outputs are encoded to JSON if there is no template. Zero
+// limits are taken from DefaultResponseLimits.
+//
+// Template execution can't be interrupted: after the timeout it goes on in
+// background until the next function call or write, which fail. Range
+// isn't nested, so it makes at most a pass over the ranged value. Outputs
+// must not be changed till then.
+func (s ServingSpec) RenderResponse(outputs map[string]interface{}, limits ResponseLimits) ([]byte, error) {
+	if limits.MaxSize <= 0 {
+		limits.MaxSize = DefaultResponseLimits.MaxSize
+	}
+	if limits.Timeout <= 0 {
+		limits.Timeout = DefaultResponseLimits.Timeout
+	}
+	filtered := s.FilterOutputs(outputs)
+	if s.ResponseTemplate == "" {
+		data, err := stdjson.Marshal(filtered)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > limits.MaxSize {
+			return nil, responseError(fmt.Errorf("response exceeds %v bytes", limits.MaxSize))
+		}
+		return data, nil
+	}
+
+	stopped := new(int32)
+	tpl, err := template.New("response").Funcs(stoppableFuncs(ResponseFuncMap(), stopped)).Parse(s.ResponseTemplate)
+	if err != nil {
+		return nil, responseError(err)
+	}
+	if err := checkResponseTemplate(tpl); err != nil {
+		return nil, responseError(err)
+	}
+	buf := &limitedBuffer{max: limits.MaxSize, stopped: stopped}
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("%v", r)
+			}
+		}()
+		done <- tpl.Execute(buf, filtered)
+	}()
+	timeout := time.NewTimer(limits.Timeout)
+	defer timeout.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			return nil, responseError(err)
+		}
+		return buf.Bytes(), nil
+	case <-timeout.C:
+		atomic.StoreInt32(stopped, 1)
+		return nil, responseError(fmt.Errorf("rendering exceeds %v", limits.Timeout))
+	}
+}
+
+func responseError(err error) error {
+	return errors.NewStatusReason(
+		http.StatusBadRequest,
+		fmt.Sprintf("Failed render response template: %v", err),
+		"Invalid response template",
+	)
+}
@@ -0,0 +1,92 @@
+package mlapp
+
+import (
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kuberlab/lib/pkg/errors"
+)
+
+func TestRenderResponse(t *testing.T) {
+	outputs := map[string]interface{}{
+		"detection_boxes":  []float64{0.1, 0.2},
+		"detection_labels": []string{"cat"},
+		"raw_logits":       []float64{1, 2, 3},
+	}
+	spec := ServingSpec{OutFilter: []string{"detection_*"}}
+	Assert(2, len(spec.FilterOutputs(outputs)), t)
+
+	data, err := spec.RenderResponse(outputs, ResponseLimits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert(`{"detection_boxes":[0.1,0.2],"detection_labels":["cat"]}`, string(data), t)
+
+	spec.ResponseTemplate = `{"label": "{{ index .detection_labels 0 | upper }}", "boxes": {{ toJson .detection_boxes }}{{ if .raw_logits }}, "logits": 1{{ end }}}`
+	data, err = spec.RenderResponse(outputs, ResponseLimits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Filtered outputs are not available in the template.
+	Assert(`{"label": "CAT", "boxes": [0.1,0.2]}`, string(data), t)
+
+	_, err = spec.RenderResponse(outputs, ResponseLimits{MaxSize: 10})
+	Assert(http.StatusBadRequest, err.(*errors.Error).Status, t)
+	Assert(true, strings.Contains(err.Error(), "exceeds 10 bytes"), t)
+
+	spec.ResponseTemplate = `{{ env "HOME" }}`
+	_, err = spec.RenderResponse(outputs, ResponseLimits{})
+	Assert(true, strings.Contains(err.Error(), `function "env" not defined`), t)
+}
+
+func TestRenderResponseBounds(t *testing.T) {
+	spec := ServingSpec{}
+	for tpl, msg := range map[string]string{
+		`{{ indent 1000000000 "x" }}`:                                         `function "indent" not defined`,
+		`{{ randAlphaNum 1000000000 }}`:                                       `function "randAlphaNum" not defined`,
+		`{{ printf "%1000000000d" 1 }}`:                                       "width 1000000000 exceeds",
+		`{{ printf "%*d" 1000000000 1 }}`:                                     "width from arguments",
+		`{{ range .a }}{{ range .a }}{{ end }}{{ end }}`:                      "nested range",
+		`{{ range .a }}{{ with .b }}{{ range . }}{{ end }}{{ end }}{{ end }}`: "nested range",
+		`{{ define "a" }}{{ end }}`:                                           "definitions are not allowed",
+		`{{ template "response" }}`:                                           "template calls are not allowed",
+	} {
+		spec.ResponseTemplate = tpl
+		_, err := spec.RenderResponse(map[string]interface{}{}, ResponseLimits{})
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Fatalf("Expected error '%v' for %v, got %v", msg, tpl, err)
+		}
+	}
+	spec.ResponseTemplate = `{{ printf "%.3f|%5s" 0.12345 "ab" }}`
+	data, err := spec.RenderResponse(map[string]interface{}{}, ResponseLimits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert("0.123|   ab", string(data), t)
+}
+
+func TestRenderResponseTimeout(t *testing.T) {
+	words := make([]interface{}, 10000)
+	for i := range words {
+		words[i] = strconv.Itoa(len(words) - i)
+	}
+	outputs := map[string]interface{}{"words": words, "items": make([]interface{}, 100000)}
+	spec := ServingSpec{ResponseTemplate: `{{ range .items }}{{ if sortAlpha $.words }}{{ end }}{{ end }}`}
+
+	goroutines := runtime.NumGoroutine()
+	_, err := spec.RenderResponse(outputs, ResponseLimits{Timeout: 20 * time.Millisecond})
+	Assert(http.StatusBadRequest, err.(*errors.Error).Status, t)
+	Assert(true, strings.Contains(err.Error(), "rendering exceeds"), t)
+
+	// Rendering stops at the next function call.
+	for i := 0; runtime.NumGoroutine() > goroutines; i++ {
+		if i == 100 {
+			t.Fatal("Rendering goes on after the timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -1,6 +1,13 @@
 package mlapp
 
-import "github.com/sirupsen/logrus"
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/kuberlab/lib/pkg/dealerclient"
+	"github.com/kuberlab/lib/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
 
 type GetRevisionsFunc func(task *Task) []TaskRevision
 type AddRevisionFunc func(rev TaskRevision)
@@ -14,8 +21,7 @@ func (c *BoardConfig) injectVersionedRevisions(
 		if !checkVolume(v) {
 			continue
 		}
-		version, ok := v.FlexVolume.Options["version"]
-		if ok {
+		if version := v.provider().DefaultRevision(v); version != "" {
 			revisionMap[v.Name] = version
 		}
 	}
@@ -72,6 +78,9 @@ func (c *BoardConfig) InjectModelRevisions(task *Task) {
 		task.ModelRevisions = append(task.ModelRevisions, rev)
 	}
 	checkVolume := func(v Volume) bool {
+		if v.Model != nil {
+			return true
+		}
 		if v.FlexVolume != nil {
 			t, ok := v.FlexVolume.Options["type"]
 			if !ok {
@@ -84,3 +93,134 @@ func (c *BoardConfig) InjectModelRevisions(task *Task) {
 	}
 	c.injectVersionedRevisions(task, getRevs, addRev, checkVolume)
 }
+
+type ModelVersionGetter interface {
+	GetModelVersion(workspace, name, version string) (*dealerclient.ModelVersion, error)
+}
+
+// ResolveModelSources fills version, checksum and download URL of model
+// sources from model version metadata. Version is taken from model revisions
+// of the task if given, otherwise from the source; constraints like "latest"
+// or "^1.2" are resolved to concrete versions first. Versions which differ
+// from the source are recorded on the task, so the task and its pods refer
+// to the same version. Volumes are copied, so the volumes given to the
+// config are not changed.
+func (c *BoardConfig) ResolveModelSources(lister VersionLister, task *Task) error {
+	volumes := append([]Volume(nil), c.VolumesData...)
+	for i, v := range volumes {
+		if v.Model == nil {
+			continue
+		}
+		m := modelSourceFor(v, task)
+		if !unresolvedModel(m) {
+			continue
+		}
+		if IsVersionConstraint(m.Version) {
+			versions, err := listVersions(lister, m.Workspace, m.Model, true)
+			if err != nil {
+				return fmt.Errorf("Failed list versions of %v/%v: %v", m.Workspace, m.Model, err)
+			}
+			version, err := resolveVersion(m.Version, versions)
+			if err != nil {
+				return errors.NewStatusReason(
+					http.StatusBadRequest,
+					fmt.Sprintf("Can't resolve version of '%v' (%v/%v): %v", v.Name, m.Workspace, m.Model, err),
+					"Use exact version, tag, 'latest' or semver constraint like '^1.2' or '>=2.0 <3'",
+				)
+			}
+			if version != m.Version {
+				m = ModelSource{Workspace: m.Workspace, Model: m.Model, Version: version}
+			}
+		}
+		version, err := lister.GetModelVersion(m.Workspace, m.Model, m.Version)
+		if err != nil {
+			return fmt.Errorf("Failed get model version %v/%v:%v: %v", m.Workspace, m.Model, m.Version, err)
+		}
+		if m.Checksum == "" {
+			m.Checksum = version.Checksum
+		}
+		if m.DownloadURL == "" {
+			m.DownloadURL = version.DownloadURL
+		}
+		volumes[i].Model = &m
+		if task != nil && (task.revision(v.Name) != "" || m.Version != v.Model.Version) {
+			task.setModelRevision(v.Name, m.Version)
+		}
+		logrus.Infof("Model source [%v=%v/%v:%v], checksum: %v", v.Name, m.Workspace, m.Model, m.Version, m.Checksum)
+	}
+	c.VolumesData = volumes
+	return nil
+}
+
+// modelSourceFor returns model source of the volume at the revision of the
+// task. Checksum and download URL belong to the version of the source, so
+// they are dropped if the task requests another version.
+func modelSourceFor(v Volume, task *Task) ModelSource {
+	m := *v.Model
+	if revision := task.revision(v.Name); revision != "" && revision != m.Version {
+		m = ModelSource{Workspace: m.Workspace, Model: m.Model, Version: revision}
+	}
+	return m
+}
+
+func unresolvedModel(m ModelSource) bool {
+	return m.Version != "" && (m.Checksum == "" || m.DownloadURL == "" || IsVersionConstraint(m.Version))
+}
+
+// setModelRevision records version of the model volume, revisions are copied
+// as the task may share them with the caller.
+func (t *Task) setModelRevision(volume, version string) {
+	revs := make([]TaskRevision, 0, len(t.ModelRevisions)+1)
+	found := false
+	for _, rev := range t.ModelRevisions {
+		if rev.VolumeName == volume {
+			rev.Revision = version
+			found = true
+		}
+		revs = append(revs, rev)
+	}
+	if !found {
+		revs = append(revs, TaskRevision{VolumeName: volume, Revision: version})
+	}
+	t.ModelRevisions = revs
+}
+
+// unresolvedModels reports whether model sources must be resolved for the
+// task and whether they can't be used without dealer: sources without
+// download URL or with version constraint.
+func (c *BoardConfig) unresolvedModels(task *Task) (unresolved, required bool) {
+	for _, v := range c.VolumesData {
+		if v.Model == nil {
+			continue
+		}
+		if m := modelSourceFor(v, task); unresolvedModel(m) {
+			unresolved = true
+			required = required || m.DownloadURL == "" || explicitConstraint(m.Version)
+		}
+	}
+	return unresolved, required
+}
+
+// withModelSources returns copy of the config with model sources resolved
+// for the task by the dealer client of the config, the config itself is not
+// changed. Nothing is requested if all sources are resolved. Sources with
+// download URL are used without checksum if dealer is not configured.
+func (c *BoardConfig) withModelSources(task *Task) (*BoardConfig, error) {
+	unresolved, required := c.unresolvedModels(task)
+	if !unresolved {
+		return c, nil
+	}
+	lister, err := c.versionLister()
+	if err != nil && required {
+		return nil, fmt.Errorf("Can't resolve model sources: %v", err)
+	}
+	if err != nil {
+		logrus.Warnf("Model sources are used without checksum: %v", err)
+		return c, nil
+	}
+	resolved := *c
+	if err := resolved.ResolveModelSources(lister, task); err != nil {
+		return nil, err
+	}
+	return &resolved, nil
+}
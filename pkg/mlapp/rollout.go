@@ -0,0 +1,118 @@
+package mlapp
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/kuberlab/lib/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Rollout configures how Deployment of Uix or serving is updated.
+type Rollout struct {
+	// RollingUpdate or Recreate. Recreate is used by default for components
+	// requesting GPU which can't be allocated twice during update.
+	Strategy appsv1.DeploymentStrategyType `json:"strategy,omitempty"`
+	// Pods (number or percent) created above desired replicas on RollingUpdate
+	MaxSurge *intstr.IntOrString `json:"max_surge,omitempty"`
+	// Pods (number or percent) unavailable on RollingUpdate
+	MaxUnavailable *intstr.IntOrString `json:"max_unavailable,omitempty"`
+	// Seconds a new pod must be ready to be available
+	MinReadySeconds int32 `json:"min_ready_seconds,omitempty"`
+	// Seconds before the rollout is considered failed
+	ProgressDeadlineSeconds int32 `json:"progress_deadline_seconds,omitempty"`
+}
+
+func checkRollout(r *Rollout, resName string) error {
+	if r == nil {
+		return nil
+	}
+	invalid := func(msg string) error {
+		return errors.NewStatusReason(
+			http.StatusBadRequest,
+			fmt.Sprintf("Invalid rollout of resource %v: %v", resName, msg),
+			"Invalid rollout strategy",
+		)
+	}
+	switch r.Strategy {
+	case "", appsv1.RollingUpdateDeploymentStrategyType:
+	case appsv1.RecreateDeploymentStrategyType:
+		if r.MaxSurge != nil || r.MaxUnavailable != nil {
+			return invalid("max_surge and max_unavailable are not allowed for Recreate")
+		}
+	default:
+		return invalid(fmt.Sprintf("unknown strategy '%v'", r.Strategy))
+	}
+	surge, err := rolloutPods(r.MaxSurge)
+	if err != nil {
+		return invalid(fmt.Sprintf("max_surge: %v", err))
+	}
+	unavailable, err := rolloutPods(r.MaxUnavailable)
+	if err != nil {
+		return invalid(fmt.Sprintf("max_unavailable: %v", err))
+	}
+	// Defaults are not zero, so both must be set to stall the update.
+	if r.MaxSurge != nil && r.MaxUnavailable != nil && surge == 0 && unavailable == 0 {
+		return invalid("max_surge and max_unavailable can't be both 0")
+	}
+	if r.MinReadySeconds < 0 || r.ProgressDeadlineSeconds < 0 {
+		return invalid("seconds must not be negative")
+	}
+	if r.ProgressDeadlineSeconds > 0 && r.ProgressDeadlineSeconds <= r.MinReadySeconds {
+		return invalid("progress_deadline_seconds must be greater than min_ready_seconds")
+	}
+	return nil
+}
+
+// rolloutPods returns pods of max_surge or max_unavailable per 100 replicas,
+// percent is rounded up like in Deployment controller.
+func rolloutPods(v *intstr.IntOrString) (int, error) {
+	if v == nil {
+		return 0, nil
+	}
+	n, err := intstr.GetScaledValueFromIntOrPercent(v, 100, true)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("must not be negative")
+	}
+	return n, nil
+}
+
+func (ui UIXResourceGenerator) rollout() Rollout {
+	if ui.Rollout != nil {
+		return *ui.Rollout
+	}
+	return Rollout{}
+}
+
+// DeploymentStrategy returns strategy of the Deployment, nil means
+// default RollingUpdate.
+func (ui UIXResourceGenerator) DeploymentStrategy() *appsv1.DeploymentStrategy {
+	r := ui.rollout()
+	rolling := r.MaxSurge != nil || r.MaxUnavailable != nil
+	if r.Strategy == appsv1.RecreateDeploymentStrategyType ||
+		(r.Strategy == "" && !rolling && ui.ResourcesSpec().Accelerators.GPU > 0) {
+		return &appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}
+	}
+	if !rolling {
+		return nil
+	}
+	return &appsv1.DeploymentStrategy{
+		Type: appsv1.RollingUpdateDeploymentStrategyType,
+		RollingUpdate: &appsv1.RollingUpdateDeployment{
+			MaxSurge:       r.MaxSurge,
+			MaxUnavailable: r.MaxUnavailable,
+		},
+	}
+}
+
+func (ui UIXResourceGenerator) MinReadySeconds() int32 {
+	return ui.rollout().MinReadySeconds
+}
+
+func (ui UIXResourceGenerator) ProgressDeadlineSeconds() int32 {
+	return ui.rollout().ProgressDeadlineSeconds
+}
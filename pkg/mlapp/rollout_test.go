@@ -0,0 +1,62 @@
+package mlapp
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/kuberlab/lib/pkg/errors"
+	kuberlab "github.com/kuberlab/lib/pkg/kubernetes"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/version"
+)
+
+func TestRollout(t *testing.T) {
+	kubeVersion := kuberlab.MlBoardKubeVersion
+	defer func() { kuberlab.MlBoardKubeVersion = kubeVersion }()
+	kuberlab.MlBoardKubeVersion = &version.Info{Major: "1", Minor: "22"}
+
+	uix := Uix{Meta: Meta{Name: "jupyter"}, Resource: Resource{Images: Images{CPU: "jupyter:latest"}}}
+	uix.Resources = &ResourceRequest{Accelerators: ResourceAccelerators{GPU: 1}}
+	c := &BoardConfig{Config: Config{Meta: Meta{Name: "project"}, Spec: Spec{Uix: []Uix{uix}}}}
+
+	deployment := func() *appsv1.Deployment {
+		resources, err := c.GenerateUIXResources()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resources[1].Object.(*appsv1.Deployment)
+	}
+	// GPU can't be allocated for the new pod while the old one is running.
+	Assert(appsv1.RecreateDeploymentStrategyType, deployment().Spec.Strategy.Type, t)
+
+	surge := intstr.FromString("50%")
+	c.Uix[0].Rollout = &Rollout{MaxSurge: &surge, MinReadySeconds: 10, ProgressDeadlineSeconds: 300}
+	d := deployment()
+	Assert(appsv1.RollingUpdateDeploymentStrategyType, d.Spec.Strategy.Type, t)
+	Assert(surge, *d.Spec.Strategy.RollingUpdate.MaxSurge, t)
+	Assert(int32(10), d.Spec.MinReadySeconds, t)
+	Assert(int32(300), *d.Spec.ProgressDeadlineSeconds, t)
+
+	c.Uix[0].Rollout.Strategy = appsv1.RecreateDeploymentStrategyType
+	_, err := c.GenerateUIXResources()
+	Assert(http.StatusBadRequest, err.(*errors.Error).Status, t)
+
+	// Rolling update without surge and unavailable pods never progresses.
+	zero := intstr.FromInt(0)
+	zeroPercent := intstr.FromString("0%")
+	c.Uix[0].Rollout = &Rollout{MaxSurge: &zero, MaxUnavailable: &zeroPercent}
+	_, err = c.GenerateUIXResources()
+	Assert(http.StatusBadRequest, err.(*errors.Error).Status, t)
+	Assert("Invalid rollout of resource jupyter: max_surge and max_unavailable can't be both 0", err.Error(), t)
+	one := intstr.FromInt(1)
+	c.Uix[0].Rollout.MaxUnavailable = &one
+	Assert(appsv1.RollingUpdateDeploymentStrategyType, deployment().Spec.Strategy.Type, t)
+	c.Uix[0].Rollout.MaxUnavailable = nil
+	Assert(appsv1.RollingUpdateDeploymentStrategyType, deployment().Spec.Strategy.Type, t)
+
+	invalid := intstr.FromString("ten")
+	c.Uix[0].Rollout.MaxUnavailable = &invalid
+	_, err = c.GenerateUIXResources()
+	Assert(http.StatusBadRequest, err.(*errors.Error).Status, t)
+}
@@ -0,0 +1,279 @@
+package mlapp
+
+import (
+	"encoding/base64"
+	stdjson "encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kuberlab/lib/pkg/errors"
+)
+
+// Kinds of serving param types.
+const (
+	paramString  = "string"
+	paramBytes   = "bytes"
+	paramInteger = "integer"
+	paramNumber  = "number"
+	paramBool    = "boolean"
+)
+
+var paramKinds = map[string]string{
+	"string": paramString, "str": paramString, "text": paramString,
+	"bytes": paramBytes, "byte": paramBytes, "image": paramBytes, "file": paramBytes,
+	"audio": paramBytes, "video": paramBytes,
+	"int": paramInteger, "int8": paramInteger, "int16": paramInteger, "int32": paramInteger,
+	"int64": paramInteger, "uint8": paramInteger, "uint16": paramInteger, "uint32": paramInteger,
+	"uint64": paramInteger, "integer": paramInteger,
+	"float": paramNumber, "float16": paramNumber, "float32": paramNumber, "float64": paramNumber,
+	"double": paramNumber, "number": paramNumber,
+	"bool": paramBool, "boolean": paramBool,
+}
+
+// integerBits are bit sizes of sized integer types, other integer types are
+// int64. Types with "uint" prefix are unsigned.
+var integerBits = map[string]int{
+	"int8":   8,
+	"int16":  16,
+	"int32":  32,
+	"int64":  64,
+	"uint8":  8,
+	"uint16": 16,
+	"uint32": 32,
+	"uint64": 64,
+}
+
+var integerLiteral = regexp.MustCompile(`^[+-]?[0-9]+$`)
+
+// paramKind returns JSON kind of the param type, unknown types accept any
+// value.
+func paramKind(typ string) string {
+	return paramKinds[strings.ToLower(typ)]
+}
+
+// ValidateRequest checks payload of the serving request against Params.
+// Values are coerced to param types and missing params are set to their
+// defaults. Params not described in the spec are passed as is.
+func (s ServingSpec) ValidateRequest(payload map[string]interface{}) (map[string]interface{}, error) {
+	if s.RawInput {
+		return payload, nil
+	}
+	res := make(map[string]interface{}, len(payload))
+	for k, v := range payload {
+		res[k] = v
+	}
+	var problems []string
+	for _, p := range s.Params {
+		v, ok := payload[p.Name]
+		if !ok || v == nil {
+			if p.Required {
+				problems = append(problems, fmt.Sprintf("%v: required", p.Name))
+				continue
+			}
+			if p.Value == nil {
+				continue
+			}
+			v = p.Value
+		}
+		coerced, err := p.coerce(v)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%v: %v", p.Name, err))
+			continue
+		}
+		res[p.Name] = coerced
+	}
+	if len(problems) > 0 {
+		return nil, errors.NewStatusReason(
+			http.StatusBadRequest,
+			"Invalid request params: "+strings.Join(problems, "; "),
+			"Invalid serving request",
+		)
+	}
+	return res, nil
+}
+
+func (p ServingSpecParam) coerce(v interface{}) (interface{}, error) {
+	return p.coerceShape(v, p.Shape)
+}
+
+func (p ServingSpecParam) coerceShape(v interface{}, shape []int) (interface{}, error) {
+	if len(shape) == 0 {
+		return p.coerceScalar(v)
+	}
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected array of %v dimensions", len(shape))
+	}
+	if shape[0] >= 0 && len(items) != shape[0] {
+		return nil, fmt.Errorf("expected %v items, got %v", shape[0], len(items))
+	}
+	res := make([]interface{}, len(items))
+	for i, item := range items {
+		c, err := p.coerceShape(item, shape[1:])
+		if err != nil {
+			return nil, fmt.Errorf("[%v]: %v", i, err)
+		}
+		res[i] = c
+	}
+	return res, nil
+}
+
+func (p ServingSpecParam) coerceScalar(v interface{}) (interface{}, error) {
+	res, err := p.coerceType(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Options) > 0 {
+		value := fmt.Sprint(res)
+		for _, o := range p.options() {
+			if fmt.Sprint(o) == value {
+				return res, nil
+			}
+		}
+		return nil, fmt.Errorf("'%v' is not one of [%v]", value, strings.Join(p.Options, ", "))
+	}
+	return res, nil
+}
+
+// coerceType converts scalar value to the param type.
+func (p ServingSpecParam) coerceType(v interface{}) (interface{}, error) {
+	switch paramKind(p.Type) {
+	case paramString:
+		return coerceString(v)
+	case paramBytes:
+		return coerceBytes(v)
+	case paramInteger:
+		return coerceInteger(v, strings.ToLower(p.Type))
+	case paramNumber:
+		return coerceNumber(v)
+	case paramBool:
+		return coerceBool(v)
+	}
+	return v, nil
+}
+
+// options returns Options converted to the param type, options which
+// don't match the type are skipped.
+func (p ServingSpecParam) options() []interface{} {
+	var options []interface{}
+	for _, o := range p.Options {
+		if v, err := p.coerceType(o); err == nil {
+			options = append(options, v)
+		}
+	}
+	return options
+}
+
+func coerceString(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case string:
+		return val, nil
+	case float64, bool, stdjson.Number:
+		return fmt.Sprint(val), nil
+	}
+	return nil, fmt.Errorf("expected string, got %T", v)
+}
+
+func coerceBytes(v interface{}) (interface{}, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("expected base64 string, got %T", v)
+	}
+	if _, err := base64.StdEncoding.DecodeString(s); err != nil {
+		return nil, fmt.Errorf("invalid base64: %v", err)
+	}
+	return s, nil
+}
+
+func numberOf(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case int:
+		return float64(val), true
+	case int64:
+		return float64(val), true
+	case stdjson.Number:
+		f, err := val.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// integerText returns decimal text of integer value. Integer literals are
+// kept as is to be parsed exactly, other numbers like 1e3 must be integral.
+func integerText(v interface{}) (string, bool) {
+	switch val := v.(type) {
+	case int:
+		return strconv.Itoa(val), true
+	case int64:
+		return strconv.FormatInt(val, 10), true
+	case stdjson.Number:
+		if integerLiteral.MatchString(string(val)) {
+			return string(val), true
+		}
+	case string:
+		if s := strings.TrimSpace(val); integerLiteral.MatchString(s) {
+			return s, true
+		}
+	}
+	f, ok := numberOf(v)
+	if !ok || math.IsInf(f, 0) || f != math.Trunc(f) {
+		return "", false
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64), true
+}
+
+func coerceInteger(v interface{}, typ string) (interface{}, error) {
+	s, ok := integerText(v)
+	if !ok {
+		return nil, fmt.Errorf("expected integer, got '%v'", v)
+	}
+	bits, ok := integerBits[typ]
+	if !ok {
+		bits = 64
+	}
+	if !strings.HasPrefix(typ, "uint") {
+		i, err := strconv.ParseInt(s, 10, bits)
+		if err != nil {
+			return nil, fmt.Errorf("%v is out of %v range", s, typ)
+		}
+		return i, nil
+	}
+	u, err := strconv.ParseUint(strings.TrimPrefix(s, "+"), 10, bits)
+	if err != nil {
+		return nil, fmt.Errorf("%v is out of %v range", s, typ)
+	}
+	if u > math.MaxInt64 {
+		return u, nil
+	}
+	return int64(u), nil
+}
+
+func coerceNumber(v interface{}) (interface{}, error) {
+	f, ok := numberOf(v)
+	if !ok || math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("expected number, got '%v'", v)
+	}
+	return f, nil
+}
+
+func coerceBool(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case bool:
+		return val, nil
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(val))
+		if err == nil {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("expected boolean, got '%v'", v)
+}
@@ -0,0 +1,165 @@
+package mlapp
+
+import (
+	stdjson "encoding/json"
+	"math"
+	"net/http"
+	"testing"
+
+	"github.com/kuberlab/lib/pkg/errors"
+)
+
+func servingSpec() ServingSpec {
+	return ServingSpec{
+		Params: []ServingSpecParam{
+			{Name: "image", Type: "bytes", Label: "Input image", Required: true},
+			{Name: "threshold", Type: "float", Value: 0.5},
+			{Name: "top_k", Type: "int32"},
+			{Name: "mode", Type: "string", Options: []string{"fast", "accurate"}},
+			{Name: "box", Type: "int", Shape: []int{-1, 4}},
+		},
+		Response: []ServingResponseParam{
+			{Name: "boxes", Type: "float", Shape: []int{-1, 4}},
+			{Name: "labels", Type: "string", Shape: []int{-1}},
+		},
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	spec := servingSpec()
+	var payload map[string]interface{}
+	data := `{"image": "aGVsbG8=", "top_k": "5", "mode": "fast", "box": [[1, 2, 3, 4]], "extra": 1}`
+	if err := stdjson.Unmarshal([]byte(data), &payload); err != nil {
+		t.Fatal(err)
+	}
+	res, err := spec.ValidateRequest(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert(0.5, res["threshold"], t)
+	Assert(int64(5), res["top_k"], t)
+	Assert(int64(4), res["box"].([]interface{})[0].([]interface{})[3], t)
+	Assert(float64(1), res["extra"], t)
+
+	payload = map[string]interface{}{
+		"top_k": 1.5,
+		"mode":  "slow",
+		"box":   []interface{}{[]interface{}{1.0, 2.0}},
+	}
+	_, err = spec.ValidateRequest(payload)
+	Assert(http.StatusBadRequest, err.(*errors.Error).Status, t)
+	Assert(
+		"Invalid request params: image: required; top_k: expected integer, got '1.5'; "+
+			"mode: 'slow' is not one of [fast, accurate]; box: [0]: expected 4 items, got 2",
+		err.Error(),
+		t,
+	)
+}
+
+func TestCoerceInteger(t *testing.T) {
+	valid := []struct {
+		typ   string
+		value interface{}
+		want  interface{}
+	}{
+		{"int8", 127.0, int64(127)},
+		{"int8", -128.0, int64(-128)},
+		{"uint32", "4294967295", int64(4294967295)},
+		{"int", -9.2e18, int64(-9.2e18)},
+		{"uint64", 1e19, uint64(1e19)},
+		{"int16", "1e3", int64(1000)},
+		{"int64", stdjson.Number("9007199254740993"), int64(9007199254740993)},
+		{"int64", "9223372036854775807", int64(math.MaxInt64)},
+		{"int64", stdjson.Number("-9223372036854775808"), int64(math.MinInt64)},
+		{"uint64", stdjson.Number("18446744073709551615"), uint64(math.MaxUint64)},
+		{"uint64", "+9007199254740993", int64(9007199254740993)},
+	}
+	for _, v := range valid {
+		got, err := coerceInteger(v.value, v.typ)
+		if err != nil {
+			t.Fatalf("%v %v: %v", v.typ, v.value, err)
+		}
+		Assert(v.want, got, t)
+	}
+	invalid := []struct {
+		typ   string
+		value interface{}
+	}{
+		{"int8", 128.0},
+		{"uint8", -1.0},
+		{"int64", 9.3e18},
+		{"int", math.Pow(2, 63)},
+		{"integer", -1e19},
+		{"uint64", math.Pow(2, 64)},
+		{"uint64", 1e20},
+		{"int64", "9223372036854775808"},
+		{"int64", stdjson.Number("-9223372036854775809")},
+		{"uint64", stdjson.Number("18446744073709551616")},
+		{"uint64", "-1"},
+		{"int", "1.5"},
+		{"int", math.Inf(1)},
+		{"int", "NaN"},
+	}
+	for _, v := range invalid {
+		if got, err := coerceInteger(v.value, v.typ); err == nil {
+			t.Errorf("%v %v must be rejected, got %v", v.typ, v.value, got)
+		}
+	}
+}
+
+func TestCoerceNumber(t *testing.T) {
+	for _, v := range []interface{}{"NaN", "Inf", "-Inf", math.NaN(), stdjson.Number("1e400")} {
+		if got, err := coerceNumber(v); err == nil {
+			t.Errorf("%v must be rejected, got %v", v, got)
+		}
+	}
+	got, err := coerceNumber(stdjson.Number("0.5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert(0.5, got, t)
+}
+
+func TestServingOpenAPIEnum(t *testing.T) {
+	spec := ServingSpec{Params: []ServingSpecParam{
+		{Name: "level", Type: "int32", Options: []string{"1", "2", "x"}},
+		{Name: "scale", Type: "float", Shape: []int{2}, Options: []string{"0.5", "1"}},
+		{Name: "flag", Type: "bool", Options: []string{"true"}},
+	}}
+	request := spec.RequestSchema()
+	Assert([]interface{}{int64(1), int64(2)}, request.Properties["level"].Enum, t)
+	Assert([]interface{}{0.5, 1.0}, request.Properties["scale"].Items.Enum, t)
+	Assert([]interface{}{true}, request.Properties["flag"].Enum, t)
+	data, err := stdjson.Marshal(request.Properties["level"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert(`{"type":"integer","format":"int32","enum":[1,2]}`, string(data), t)
+
+	// Options are matched by value.
+	res, err := spec.ValidateRequest(map[string]interface{}{"level": "02", "flag": "True"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert(int64(2), res["level"], t)
+	Assert(true, res["flag"], t)
+}
+
+func TestServingOpenAPI(t *testing.T) {
+	s := UniversalServing{Spec: servingSpec()}
+	s.Name = "detector"
+	doc := s.OpenAPI("/detector/predict")
+	op := doc.Paths["/detector/predict"].Post
+	request := op.RequestBody.Content["application/json"].Schema
+	Assert([]string{"image"}, request.Required, t)
+	Assert("byte", request.Properties["image"].Format, t)
+	Assert([]interface{}{"fast", "accurate"}, request.Properties["mode"].Enum, t)
+	box := request.Properties["box"]
+	Assert("array", box.Items.Type, t)
+	Assert(4, *box.Items.MaxItems, t)
+	Assert("integer", box.Items.Items.Type, t)
+
+	response := op.Responses["200"].Content["application/json"].Schema
+	Assert("float", response.Properties["boxes"].Items.Items.Format, t)
+	Assert("3.0.3", doc.OpenAPI, t)
+}
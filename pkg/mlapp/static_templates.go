@@ -7,10 +7,7 @@ import (
 	"strings"
 	"text/template"
 
-	"k8s.io/apimachinery/pkg/api/resource"
-
 	"github.com/kuberlab/lib/pkg/apputil"
-	"github.com/kuberlab/lib/pkg/dealerclient"
 	"github.com/kuberlab/lib/pkg/kubernetes"
 	"github.com/kuberlab/lib/pkg/types"
 	"github.com/kuberlab/lib/pkg/utils"
@@ -33,6 +30,16 @@ metadata:
 spec:
   replicas: {{ .Replicas }}
   revisionHistoryLimit: 1
+  {{- with .DeploymentStrategy }}
+  strategy:
+{{ toYaml . | indent 4 }}
+  {{- end }}
+  {{- if .MinReadySeconds }}
+  minReadySeconds: {{ .MinReadySeconds }}
+  {{- end }}
+  {{- if .ProgressDeadlineSeconds }}
+  progressDeadlineSeconds: {{ .ProgressDeadlineSeconds }}
+  {{- end }}
   selector:
     matchLabels:
       {{- range $key, $value := .DLabels }}
@@ -65,21 +72,19 @@ spec:
       {{- range $i, $value := .InitContainers }}
       - name: {{ $value.Name }}
         image: {{ $value.Image }}
-        command: {{ $value.Command }}
+        command: {{ toJson $value.Command }}
+        {{- if $value.Env }}
+{{ toYaml $value.EnvSpec | indent 8 }}
+        {{- end }}
 {{ toYaml $value.Mounts | indent 8 }}
       {{- end }}
       {{- end }}
-      tolerations:
-      - key: role.kuberlab.io/cpu-compute
-        effect: PreferNoSchedule
-      {{- if gt .ResourcesSpec.Accelerators.GPU 0 }}
-      - key: role.kuberlab.io/gpu-compute
-        effect: PreferNoSchedule
+      {{- if .PriorityClassName }}
+      priorityClassName: {{ .PriorityClassName }}
       {{- end }}
-      {{- if .DeployResourceLabel }}
-      - key: kuberlab.io/private-resource
-        value: {{ .DeployResourceLabel }}
-        effect: NoSchedule
+      {{- if gt (len .Tolerations) 0 }}
+      tolerations:
+{{ toYaml .Tolerations | indent 6 }}
       {{- end }}
       {{- if gt (len .DockerSecretNames) 0 }}
       imagePullSecrets:
@@ -161,6 +166,12 @@ spec:
             {{- if .ResourcesSpec.Requests.MemoryQuantity }}
             memory: "{{ .ResourcesSpec.Requests.MemoryQuantity }}"
             {{- end }}
+            {{- if .ResourcesSpec.Requests.EphemeralStorageQuantity }}
+            ephemeral-storage: "{{ .ResourcesSpec.Requests.EphemeralStorageQuantity }}"
+            {{- end }}
+            {{- range $size, $value := .ResourcesSpec.Requests.HugePages }}
+            hugepages-{{ $size }}: "{{ $value }}"
+            {{- end }}
           limits:
             {{- if gt .ResourcesSpec.Accelerators.GPU 0 }}
             {{- if and (eq .KubeVersionMajor 1) (lt .KubeVersionMinor 9) }}
@@ -175,6 +186,12 @@ spec:
             {{- if .ResourcesSpec.Limits.MemoryQuantity }}
             memory: "{{ .ResourcesSpec.Limits.MemoryQuantity }}"
             {{- end }}
+            {{- if .ResourcesSpec.Limits.EphemeralStorageQuantity }}
+            ephemeral-storage: "{{ .ResourcesSpec.Limits.EphemeralStorageQuantity }}"
+            {{- end }}
+            {{- range $size, $value := .ResourcesSpec.Limits.HugePages }}
+            hugepages-{{ $size }}: "{{ $value }}"
+            {{- end }}
 {{ toYaml .Mounts | indent 8 }}
 {{ toYaml .Volumes | indent 6 }}
 `
@@ -257,6 +274,9 @@ func (ui UIXResourceGenerator) NodeSelectors() map[string]string {
 func (ui UIXResourceGenerator) DeployResourceLabel() string {
 	return ui.c.DeployResourceLabel
 }
+func (ui UIXResourceGenerator) Tolerations() []v1.Toleration {
+	return ui.c.tolerations(ui.ResourcesSpec().Accelerators.GPU)
+}
 func (ui UIXResourceGenerator) DockerSecretNames() []string {
 	return ui.c.DockerSecretNames()
 }
@@ -275,13 +295,12 @@ func (ui UIXResourceGenerator) Conda() string {
 }
 
 func (ui UIXResourceGenerator) ResourcesSpec() ResourceRequest {
-	cpu, _ := resource.ParseQuantity("50m")
-	mem, _ := resource.ParseQuantity("128Mi")
-	return ResourceSpec(
-		ui.Resources,
-		ui.c.BoardMetadata.Limits,
-		dealerclient.ResourceLimit{CPU: &cpu, Memory: &mem},
-	)
+	res, _ := ui.c.ExplainResources(ui.Resources)
+	return res
+}
+
+func (ui UIXResourceGenerator) PriorityClassName() string {
+	return ui.c.priorityClassName(ui.Resource)
 }
 
 func (ui UIXResourceGenerator) Replicas() int {
@@ -311,13 +330,13 @@ func (ui UIXResourceGenerator) PythonPath() string {
 
 func (ui UIXResourceGenerator) Mounts() interface{} {
 	return map[string]interface{}{
-		"volumeMounts": ui.mounts,
+		"volumeMounts": withSharedMemoryMount(ui.mounts, ui.ResourcesSpec()),
 	}
 }
 
 func (ui UIXResourceGenerator) Volumes() interface{} {
 	return map[string]interface{}{
-		"volumes": ui.volumes,
+		"volumes": withSharedMemoryVolume(ui.volumes, ui.ResourcesSpec()),
 	}
 }
 
@@ -362,6 +381,9 @@ func (ui UIXResourceGenerator) Args() string {
 
 func (c *BoardConfig) GenerateUIXResources() ([]*kubernetes.KubeResource, error) {
 	resources := []*kubernetes.KubeResource{}
+	if err := c.checkRequestedQuota(c); err != nil {
+		return nil, err
+	}
 	for _, uix := range c.Uix {
 		//if uix.Disabled {
 		//	continue
@@ -370,8 +392,11 @@ func (c *BoardConfig) GenerateUIXResources() ([]*kubernetes.KubeResource, error)
 		if err := c.CheckResourceLimit(uix.Resource, uix.Name); err != nil {
 			return nil, err
 		}
+		if err := checkRollout(uix.Rollout, uix.Name); err != nil {
+			return nil, err
+		}
 
-		volumes, mounts, err := c.KubeVolumesSpec(uix.VolumeMounts(c.VolumesData, c.DefaultMountPath, c.DefaultReadOnly))
+		volumes, mounts, err := c.componentVolumes(uix.Name, uix.VolumeMounts(c.VolumesData, c.DefaultMountPath, c.DefaultReadOnly))
 		if err != nil {
 			return nil, fmt.Errorf("Failed get volumes '%s': %v", uix.Name, err)
 		}
@@ -379,6 +404,9 @@ func (c *BoardConfig) GenerateUIXResources() ([]*kubernetes.KubeResource, error)
 		if err != nil {
 			return nil, fmt.Errorf("Failed generate init spec '%s': %v", uix.Name, err)
 		}
+		if err := c.pinDeploymentImages(&uix.Images, c.Secrets); err != nil {
+			return nil, err
+		}
 		g := UIXResourceGenerator{c: c, Uix: uix, mounts: mounts, volumes: volumes, InitContainers: initContainers}
 
 		if g.PrivilegedMode() {
@@ -503,7 +531,7 @@ func (serving ServingResourceGenerator) ComponentName() string {
 
 func (c *BoardConfig) GenerateServingResources(serving Serving) ([]*kubernetes.KubeResource, error) {
 	resources := []*kubernetes.KubeResource{}
-	volumes, mounts, err := c.KubeVolumesSpec(serving.VolumeMounts(c.VolumesData, c.DefaultMountPath, c.DefaultReadOnly))
+	volumes, mounts, err := c.componentVolumes(serving.Name, serving.VolumeMounts(c.VolumesData, c.DefaultMountPath, c.DefaultReadOnly))
 	if err != nil {
 		return nil, fmt.Errorf("Failed get volumes '%s': %v", serving.Name, err)
 	}
@@ -515,6 +543,15 @@ func (c *BoardConfig) GenerateServingResources(serving Serving) ([]*kubernetes.K
 	if err := c.CheckResourceLimit(serving.Uix.Resource, serving.Name); err != nil {
 		return nil, err
 	}
+	if err := checkRollout(serving.Rollout, serving.Name); err != nil {
+		return nil, err
+	}
+	if err := c.checkRequestedQuota(&serving); err != nil {
+		return nil, err
+	}
+	if err := c.pinDeploymentImages(&serving.Images, c.Secrets); err != nil {
+		return nil, err
+	}
 
 	g := ServingResourceGenerator{
 		TaskName:  serving.TaskName,
@@ -673,11 +710,13 @@ func baseEnv(c *BoardConfig, r Resource) ([]Env, string) {
 		// Add for all resources except for serving from model.
 		envs = append(envs, Env{
 			Name:            "WORKSPACE_SECRET",
-			ValueFromSecret: utils.KubePodNameEncode(fmt.Sprintf("%v-ws-key-%v", c.Name, c.WorkspaceID)),
+			ValueFromSecret: c.workspaceSecretName(),
 			SecretKey:       "token",
 		})
 	}
-	pythonPath = append(pythonPath, kibernetikaPythonLibs)
+	if c.Platform().HelpersDelivery != HelpersNone {
+		pythonPath = append(pythonPath, c.Platform().PythonLibsPath)
+	}
 
 	return envs, strings.Join(pythonPath, ":")
 }
@@ -2,14 +2,12 @@ package mlapp
 
 import (
 	"fmt"
-	"k8s.io/apimachinery/pkg/api/resource"
 	"strconv"
 	"strings"
 	"sync"
 
 	kuberlab "github.com/kuberlab/lib/pkg/kubernetes"
 	//"github.com/kuberlab/lib/pkg/mlapp/ssh"
-	"github.com/kuberlab/lib/pkg/dealerclient"
 	"github.com/kuberlab/lib/pkg/types"
 	"github.com/kuberlab/lib/pkg/utils"
 	"k8s.io/api/core/v1"
@@ -37,24 +35,28 @@ spec:
   hostname: "{{ .BuildName }}"
   subdomain: "{{ .BuildName }}"
   restartPolicy: Never
-  tolerations:
-  - key: role.kuberlab.io/cpu-compute
-    effect: PreferNoSchedule
-  {{- if gt .ResourcesSpec.Accelerators.GPU 0 }}
-  - key: role.kuberlab.io/gpu-compute
-    effect: PreferNoSchedule
+  {{- if gt (len .PostRunContainers) 0 }}
+  shareProcessNamespace: true
+  {{- end }}
+  {{- if .ActiveDeadlineSeconds }}
+  activeDeadlineSeconds: {{ .ActiveDeadlineSeconds }}
   {{- end }}
-  {{- if .DeployResourceLabel }}
-  - key: kuberlab.io/private-resource
-    value: {{ .DeployResourceLabel }}
-    effect: NoSchedule
+  {{- if .PriorityClassName }}
+  priorityClassName: {{ .PriorityClassName }}
+  {{- end }}
+  {{- if gt (len .Tolerations) 0 }}
+  tolerations:
+{{ toYaml .Tolerations | indent 2 }}
   {{- end }}
   {{- if gt (len .InitContainers) 0 }}
   initContainers:
   {{- range $i, $value := .InitContainers }}
   - name: {{ $value.Name }}
     image: {{ $value.Image }}
-    command: {{ $value.Command }}
+    command: {{ toJson $value.Command }}
+    {{- if $value.Env }}
+{{ toYaml $value.EnvSpec | indent 4 }}
+    {{- end }}
 {{ toYaml $value.Mounts | indent 4 }}
   {{- end }}
   {{- end }}
@@ -71,10 +73,16 @@ spec:
       {{- if .Conda }}
       source activate {{ .Conda }};
       {{- end }}
+      {{- if gt (len .PostRunContainers) 0 }}
+      echo $$ > {{ .PostRunDir }}/pid;
+      {{- end }}
       export PYTHONPATH=$PYTHONPATH:{{ .PythonPath }};
       cd {{ .WorkDir }};
       {{ .Command | indent 6 }} {{ .Args }};
       code=$?;
+      {{- if gt (len .PostRunContainers) 0 }}
+      echo $code > {{ .PostRunDir }}/exit-code;
+      {{- end }}
       exit $code
     image: {{ .Image }}
     imagePullPolicy: Always
@@ -115,6 +123,12 @@ spec:
         {{- if .ResourcesSpec.Requests.MemoryQuantity }}
         memory: "{{ .ResourcesSpec.Requests.MemoryQuantity }}"
         {{- end }}
+        {{- if .ResourcesSpec.Requests.EphemeralStorageQuantity }}
+        ephemeral-storage: "{{ .ResourcesSpec.Requests.EphemeralStorageQuantity }}"
+        {{- end }}
+        {{- range $size, $value := .ResourcesSpec.Requests.HugePages }}
+        hugepages-{{ $size }}: "{{ $value }}"
+        {{- end }}
       limits:
         {{- if gt .ResourcesSpec.Accelerators.GPU 0 }}
         {{- if and (eq .KubeVersionMajor 1) (lt .KubeVersionMinor 9) }}
@@ -129,7 +143,22 @@ spec:
         {{- if .ResourcesSpec.Limits.MemoryQuantity }}
         memory: "{{ .ResourcesSpec.Limits.MemoryQuantity }}"
         {{- end }}
+        {{- if .ResourcesSpec.Limits.EphemeralStorageQuantity }}
+        ephemeral-storage: "{{ .ResourcesSpec.Limits.EphemeralStorageQuantity }}"
+        {{- end }}
+        {{- range $size, $value := .ResourcesSpec.Limits.HugePages }}
+        hugepages-{{ $size }}: "{{ $value }}"
+        {{- end }}
 {{ toYaml .Mounts | indent 4 }}
+  {{- range $i, $value := .PostRunContainers }}
+  - name: {{ $value.Name }}
+    image: {{ $value.Image }}
+    command: {{ toJson $value.Command }}
+    {{- if $value.Env }}
+{{ toYaml $value.EnvSpec | indent 4 }}
+    {{- end }}
+{{ toYaml $value.Mounts | indent 4 }}
+  {{- end }}
 {{ toYaml .Volumes | indent 2 }}
 `
 
@@ -143,6 +172,8 @@ type TaskResourceGenerator struct {
 	volumes        []v1.Volume
 	mounts         []v1.VolumeMount
 	InitContainers []InitContainers
+	// Containers started along with the main one and waiting for it to finish
+	PostRunContainers []InitContainers
 }
 
 func (t *TaskResourceGenerator) KubeVersion() *version.Info {
@@ -166,9 +197,16 @@ func (t *TaskResourceGenerator) KubeVersionMinor() int {
 }
 
 func (t *TaskResourceGenerator) ResourcesSpec() ResourceRequest {
-	cpu, _ := resource.ParseQuantity("50m")
-	mem, _ := resource.ParseQuantity("128Mi")
-	return ResourceSpec(t.Resources, t.c.BoardMetadata.Limits, dealerclient.ResourceLimit{CPU: &cpu, Memory: &mem})
+	res, _ := t.c.ExplainResources(t.Resources)
+	return res
+}
+
+func (t *TaskResourceGenerator) PriorityClassName() string {
+	return t.c.priorityClassName(t.TaskResource.Resource)
+}
+
+func (t *TaskResourceGenerator) ActiveDeadlineSeconds() int64 {
+	return t.c.ActiveDeadlineSeconds()
 }
 
 func (t *TaskResourceGenerator) DockerSecretNames() []string {
@@ -188,6 +226,9 @@ func (t *TaskResourceGenerator) PythonPath() string {
 	_, pythonPath := baseEnv(t.c, t.TaskResource.Resource)
 	return pythonPath
 }
+func (t *TaskResourceGenerator) Tolerations() []v1.Toleration {
+	return t.c.tolerations(t.ResourcesSpec().Accelerators.GPU)
+}
 func (t *TaskResourceGenerator) DeployResourceLabel() string {
 	return t.c.DeployResourceLabel
 }
@@ -229,12 +270,12 @@ func (t *TaskResourceGenerator) BuildName() string {
 }
 func (t *TaskResourceGenerator) Mounts() interface{} {
 	return map[string]interface{}{
-		"volumeMounts": t.mounts,
+		"volumeMounts": withSharedMemoryMount(t.mounts, t.ResourcesSpec()),
 	}
 }
 func (t *TaskResourceGenerator) Volumes() interface{} {
 	return map[string]interface{}{
-		"volumes": t.volumes,
+		"volumes": withSharedMemoryVolume(t.volumes, t.ResourcesSpec()),
 	}
 }
 func (t *TaskResourceGenerator) Namespace() string {
@@ -261,17 +302,30 @@ func (t *TaskResourceGenerator) Args() string {
 	return t.RawArgs
 }
 
+// PostRunDir is shared with post run containers, see gitCommitScript.
+func (t *TaskResourceGenerator) PostRunDir() string {
+	return postRunDir
+}
+
 func (t *TaskResourceGenerator) PrivilegedMode() bool {
 	return t.NodesLabel == "knode:movidius"
 }
 
 func (c *BoardConfig) GenerateTaskResources(task Task, jobID string) ([]TaskResourceSpec, error) {
 	taskSpec := make([]TaskResourceSpec, 0)
+	if err := c.checkRequestedQuota(&task); err != nil {
+		return nil, err
+	}
+	// Model sources are resolved on a copy of the config for this task.
+	c, err := c.withModelSources(&task)
+	if err != nil {
+		return nil, err
+	}
 	for _, r := range task.Resources {
 		if err := c.CheckResourceLimit(r.Resource, r.Name); err != nil {
 			return nil, err
 		}
-		volumes, mounts, err := c.KubeVolumesSpec(r.VolumeMounts(c.VolumesData, c.DefaultMountPath, c.DefaultReadOnly))
+		volumes, mounts, err := c.componentVolumes(task.Name+"-"+r.Name, r.VolumeMounts(c.VolumesData, c.DefaultMountPath, c.DefaultReadOnly))
 		if err != nil {
 			return nil, fmt.Errorf("Failed get volumes for '%s-%s': %v", task.Name, r.Name, err)
 		}
@@ -284,14 +338,23 @@ func (c *BoardConfig) GenerateTaskResources(task Task, jobID string) ([]TaskReso
 		}
 		//volumes = append(volumes, sshVolumes...)
 		//mounts = append(mounts, sshVolumesMount...)
+		postRun, err := c.gitCommitSteps(task, r, jobID)
+		if err != nil {
+			return nil, fmt.Errorf("Failed generate commit spec '%s-%s': %v", task.Name, r.Name, err)
+		}
+		if len(postRun) > 0 {
+			volumes = append(volumes, postRunVolumeSpec())
+			mounts = append(mounts, postRunMount())
+		}
 		g := &TaskResourceGenerator{
-			c:              c,
-			task:           task,
-			TaskResource:   r,
-			mounts:         mounts,
-			volumes:        volumes,
-			JobID:          jobID,
-			InitContainers: initContainers,
+			c:                 c,
+			task:              task,
+			TaskResource:      r,
+			mounts:            mounts,
+			volumes:           volumes,
+			JobID:             jobID,
+			InitContainers:    initContainers,
+			PostRunContainers: postRun,
 		}
 
 		if g.PrivilegedMode() {
@@ -337,6 +400,13 @@ func (c *BoardConfig) GenerateTaskResources(task Task, jobID string) ([]TaskReso
 			NodeAllocator: r.NodesLabel,
 		})
 	}
+	if len(taskSpec) > 0 {
+		manifest, err := c.RunManifestResource(c.GenerateRunManifest(task, jobID))
+		if err != nil {
+			return nil, fmt.Errorf("Failed generate run manifest for '%s': %v", task.Name, err)
+		}
+		taskSpec[0].Resource.Deps = append(taskSpec[0].Resource.Deps, manifest)
+	}
 	return taskSpec, nil
 }
 
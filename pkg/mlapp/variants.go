@@ -0,0 +1,350 @@
+package mlapp
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/kuberlab/lib/pkg/errors"
+	"github.com/kuberlab/lib/pkg/kubernetes"
+	"github.com/kuberlab/lib/pkg/types"
+	"github.com/kuberlab/lib/pkg/utils"
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Annotations of ingress-nginx. Traffic of the primary Ingress is split
+// with the canary Ingress of the same host and path, single canary is
+// allowed.
+const (
+	ingressCanaryAnnotation            = "nginx.ingress.kubernetes.io/canary"
+	ingressCanaryWeightAnnotation      = "nginx.ingress.kubernetes.io/canary-weight"
+	ingressCanaryWeightTotalAnnotation = "nginx.ingress.kubernetes.io/canary-weight-total"
+	ingressRegexAnnotation             = "nginx.ingress.kubernetes.io/use-regex"
+	ingressRewriteAnnotation           = "nginx.ingress.kubernetes.io/rewrite-target"
+)
+
+// ServingVariant is a version of the serving receiving share of its traffic
+// proportional to Weight.
+type ServingVariant struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+	// Sources replacing serving sources of the same name, e.g. model of
+	// another version
+	Sources []Volume `json:"sources,omitempty"`
+	// Images of the variant, serving images are used if empty
+	Images *Images `json:"images,omitempty"`
+}
+
+func (serv *ModelServing) variant(name string) (*ServingVariant, error) {
+	for i := range serv.Variants {
+		if serv.Variants[i].Name == name {
+			return &serv.Variants[i], nil
+		}
+	}
+	return nil, errors.NewStatusReason(
+		http.StatusBadRequest,
+		fmt.Sprintf("Variant '%v' not found in serving %v", name, serv.Name),
+		"Unknown serving variant",
+	)
+}
+
+// Promote routes all traffic of the serving to the variant. Other variants
+// are kept with zero weight, so promotion is rolled back by promoting the
+// previous variant.
+func (serv *ModelServing) Promote(name string) error {
+	if _, err := serv.variant(name); err != nil {
+		return err
+	}
+	for i := range serv.Variants {
+		if serv.Variants[i].Name == name {
+			serv.Variants[i].Weight = 100
+		} else {
+			serv.Variants[i].Weight = 0
+		}
+	}
+	return nil
+}
+
+// Rollback takes traffic off the variant, e.g. a failing canary. Its share
+// goes to other variants in proportion to their weights.
+func (serv *ModelServing) Rollback(name string) error {
+	v, err := serv.variant(name)
+	if err != nil {
+		return err
+	}
+	if variantWeights(serv.Variants)-v.Weight <= 0 {
+		return errors.NewStatusReason(
+			http.StatusBadRequest,
+			fmt.Sprintf("Can not roll back variant '%v' of serving %v: other variants have no traffic", name, serv.Name),
+			"No variant to roll back to",
+		)
+	}
+	v.Weight = 0
+	return nil
+}
+
+func variantWeights(variants []ServingVariant) int {
+	sum := 0
+	for _, v := range variants {
+		sum += v.Weight
+	}
+	return sum
+}
+
+func validateVariants(variants []ServingVariant) error {
+	names := make(map[string]bool)
+	for _, v := range variants {
+		if v.Name == "" || utils.KubeLabelEncode(v.Name) != v.Name {
+			return errors.NewStatusReason(
+				http.StatusBadRequest,
+				fmt.Sprintf("Invalid variant name '%v': must be a valid label value", v.Name),
+				"Invalid serving variant",
+			)
+		}
+		if names[v.Name] {
+			return errors.NewStatusReason(
+				http.StatusBadRequest,
+				fmt.Sprintf("Duplicate variant '%v'", v.Name),
+				"Invalid serving variant",
+			)
+		}
+		names[v.Name] = true
+		if v.Weight < 0 {
+			return errors.NewStatusReason(
+				http.StatusBadRequest,
+				fmt.Sprintf("Invalid weight %v of variant '%v'", v.Weight, v.Name),
+				"Invalid serving variant",
+			)
+		}
+	}
+	routed := 0
+	for _, v := range variants {
+		if v.Weight > 0 {
+			routed++
+		}
+	}
+	if routed > 2 {
+		return errors.NewStatusReason(
+			http.StatusBadRequest,
+			"At most two serving variants may have non-zero weight",
+			"Invalid serving variant",
+		)
+	}
+	if variantWeights(variants) == 0 {
+		return errors.NewStatusReason(
+			http.StatusBadRequest,
+			"At least one serving variant must have non-zero weight",
+			"Invalid serving variant",
+		)
+	}
+	return nil
+}
+
+// VariantReplicas distributes replicas of the serving among variants by
+// their weights, so each variant has capacity for its share of traffic.
+// Variants with non-zero weight get at least one replica, variants without
+// weight are scaled to zero.
+func VariantReplicas(variants []ServingVariant, replicas int) []int {
+	sum := variantWeights(variants)
+	res := make([]int, len(variants))
+	if sum == 0 {
+		return res
+	}
+	for i, v := range variants {
+		if v.Weight == 0 {
+			continue
+		}
+		res[i] = int(math.Round(float64(replicas*v.Weight) / float64(sum)))
+		if res[i] < 1 {
+			res[i] = 1
+		}
+	}
+	return res
+}
+
+// variantAutoscale scales replica bounds of the autoscaler by weight of
+// the variant.
+func variantAutoscale(a Autoscale, weight, sum int) *Autoscale {
+	share := func(n int32) int32 {
+		r := int32(math.Round(float64(n) * float64(weight) / float64(sum)))
+		if r < 1 {
+			return 1
+		}
+		return r
+	}
+	if a.MinReplicas > 0 {
+		a.MinReplicas = share(a.MinReplicas)
+	}
+	if a.MaxReplicas > 0 {
+		a.MaxReplicas = share(a.MaxReplicas)
+		if a.MaxReplicas < a.MinReplicas {
+			a.MaxReplicas = a.MinReplicas
+		}
+	}
+	return &a
+}
+
+// withSources returns copy of the config with volumes replaced by sources
+// of the same name.
+func (c *BoardConfig) withSources(sources []Volume) *BoardConfig {
+	vc := *c
+	vc.VolumesData = make([]Volume, len(c.VolumesData))
+	for i, v := range c.VolumesData {
+		vc.VolumesData[i] = v
+		for _, s := range sources {
+			if s.Name == v.Name {
+				vc.VolumesData[i] = s
+			}
+		}
+	}
+	return &vc
+}
+
+// generateServingVariants generates Deployment and Service per variant and
+// the route of the serving splitting traffic by weights. Workspace limits
+// are checked against replicas of all variants.
+func (c *BoardConfig) generateServingVariants(serving BoardModelServing) ([]*kubernetes.KubeResource, error) {
+	if err := validateVariants(serving.Variants); err != nil {
+		return nil, err
+	}
+	if len(serving.Ports) == 0 {
+		return nil, errors.NewStatusReason(
+			http.StatusBadRequest,
+			fmt.Sprintf("Serving %v with variants must have a port", serving.Name),
+			"Invalid serving variant",
+		)
+	}
+	replicas := VariantReplicas(serving.Variants, replicasOrOne(serving.Replicas))
+	total := serving.ModelServing
+	total.Replicas = 0
+	for _, r := range replicas {
+		total.Replicas += r
+	}
+	if err := c.checkModelServing(total); err != nil {
+		return nil, err
+	}
+	sum := variantWeights(serving.Variants)
+
+	var resources []*kubernetes.KubeResource
+	services := make([]*v1.Service, len(serving.Variants))
+	for i, variant := range serving.Variants {
+		vs := serving
+		if variant.Images != nil {
+			vs.Images = *variant.Images
+		}
+		res, deploy, err := c.withSources(variant.Sources).modelServingDeployment(vs, variant.Name)
+		if err != nil {
+			return nil, err
+		}
+		r := int32(replicas[i])
+		deploy.Spec.Replicas = &r
+		if variant.Weight > 0 {
+			setScaleToZero(deploy, serving.Autoscale)
+		}
+		svc := generateServingServiceFromDeployment(deploy)
+		services[i] = svc.Object.(*v1.Service)
+		res.Deps = []*kubernetes.KubeResource{svc}
+		resources = append(resources, res)
+
+		// Autoscaler doesn't scale deployments with zero replicas,
+		// so variants without weight don't need it.
+		if serving.Autoscale != nil && serving.Autoscale.Enabled && variant.Weight > 0 {
+			if autoscaler := c.generateHPA(deploy, variantAutoscale(*serving.Autoscale, variant.Weight, sum)); autoscaler != nil {
+				resources = append(resources, autoscaler)
+			}
+		}
+	}
+
+	name := ServingModelResourceGenerator{UIXResourceGenerator: UIXResourceGenerator{Uix: serving.Uix}}.ComponentName()
+	resources[0].Deps = append(resources[0].Deps, c.servingRoutes(name, services, serving.Variants)...)
+	for _, s := range c.Secrets {
+		resources[0].Deps = append(resources[0].Deps, c.secret2kubeResource(s))
+	}
+	return resources, nil
+}
+
+// ServingRoutePath returns path of the serving with variants on
+// ServingIngressHost of the platform.
+func ServingRoutePath(namespace, name string) string {
+	return fmt.Sprintf("/%v/%v", namespace, name)
+}
+
+// servingRoutes returns Ingress of the serving sending traffic to the
+// variant with the greatest weight and canary Ingress sending share of the
+// other variant with traffic. The canary is kept with zero weight after
+// promotion, so the share isn't routed to a variant scaled to zero.
+func (c *BoardConfig) servingRoutes(name string, services []*v1.Service, variants []ServingVariant) []*kubernetes.KubeResource {
+	primary, canary := 0, -1
+	for i, v := range variants {
+		if v.Weight > variants[primary].Weight {
+			primary = i
+		}
+	}
+	for i, v := range variants {
+		if i != primary && (canary < 0 || v.Weight > variants[canary].Weight) {
+			canary = i
+		}
+	}
+	// Canary must have the same path as the primary Ingress.
+	path := ServingRoutePath(services[primary].Namespace, name)
+	routes := []*kubernetes.KubeResource{c.servingRoute(name, path, services[primary], nil)}
+	if canary >= 0 {
+		routes = append(routes, c.servingRoute(name+"-canary", path, services[canary], map[string]string{
+			ingressCanaryAnnotation:            "true",
+			ingressCanaryWeightAnnotation:      strconv.Itoa(variants[canary].Weight),
+			ingressCanaryWeightTotalAnnotation: strconv.Itoa(variantWeights(variants)),
+		}))
+	}
+	return routes
+}
+
+func (c *BoardConfig) servingRoute(name, path string, svc *v1.Service, annotations map[string]string) *kubernetes.KubeResource {
+	p := c.Platform()
+	labels := make(map[string]string)
+	for k, v := range svc.Labels {
+		if k != types.ServingVariantLabel {
+			labels[k] = v
+		}
+	}
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	// Path of the serving is stripped.
+	annotations[ingressRegexAnnotation] = "true"
+	annotations[ingressRewriteAnnotation] = "/$2"
+	pathType := networkingv1.PathTypeImplementationSpecific
+	ingress := &networkingv1.Ingress{
+		TypeMeta: meta_v1.TypeMeta{Kind: "Ingress", APIVersion: "networking.k8s.io/v1"},
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:        name,
+			Namespace:   svc.Namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: &p.ServingIngressClass,
+			Rules: []networkingv1.IngressRule{{
+				Host: p.ServingIngressHost,
+				IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{{
+						Path:     path + "(/|$)(.*)",
+						PathType: &pathType,
+						Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+							Name: svc.Name,
+							Port: networkingv1.ServiceBackendPort{Number: svc.Spec.Ports[0].Port},
+						}},
+					}},
+				}},
+			}},
+		},
+	}
+	gvk := ingress.GroupVersionKind()
+	return &kubernetes.KubeResource{
+		Name:   name + ":ingress",
+		Kind:   &gvk,
+		Object: ingress,
+	}
+}
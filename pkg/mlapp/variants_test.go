@@ -0,0 +1,173 @@
+package mlapp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kuberlab/lib/pkg/dealerclient"
+	"github.com/kuberlab/lib/pkg/types"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+func modelVolume(version string) Volume {
+	return Volume{
+		Name: "model",
+		VolumeSource: VolumeSource{Model: &ModelSource{
+			Workspace:   "ws",
+			Model:       "resnet",
+			Version:     version,
+			DownloadURL: "https://dev.kibernetika.io/api/v0.2/workspace/ws/mlmodel/resnet/versions/" + version + "/download",
+		}},
+		MountPath: "/model",
+	}
+}
+
+func TestServingVariants(t *testing.T) {
+	serving := BoardModelServing{
+		ModelServing: ModelServing{
+			Uix: Uix{
+				Meta:  Meta{Name: "resnet"},
+				Ports: []Port{{Name: "http", Port: 9000, TargetPort: 9000, Protocol: "TCP"}},
+				Resource: Resource{
+					Replicas: 4,
+					Images:   Images{CPU: "kuberlab/serving:latest"},
+				},
+			},
+			Sources: []Volume{modelVolume("3.0.0")},
+			Variants: []ServingVariant{
+				{Name: "v3", Weight: 75},
+				{Name: "v4", Weight: 25, Sources: []Volume{modelVolume("4.0.0")}},
+			},
+		},
+		VolumesData: []Volume{modelVolume("3.0.0")},
+	}
+	resources, err := GenerateModelServing(serving, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert(2, len(resources), t)
+
+	modelURL := func(d *appsv1.Deployment) string {
+		for _, e := range d.Spec.Template.Spec.InitContainers[0].Env {
+			if e.Name == "MODEL_URL" {
+				return e.Value
+			}
+		}
+		return ""
+	}
+	v3 := resources[0].Object.(*appsv1.Deployment)
+	v4 := resources[1].Object.(*appsv1.Deployment)
+	Assert("resnet-v3", v3.Name, t)
+	Assert(int32(3), *v3.Spec.Replicas, t)
+	Assert("v3", v3.Spec.Template.Labels[types.ServingVariantLabel], t)
+	Assert("resnet-v4", v4.Name, t)
+	Assert(int32(1), *v4.Spec.Replicas, t)
+	Assert(serving.Sources[0].Model.DownloadURL, modelURL(v3), t)
+	Assert(modelVolume("4.0.0").Model.DownloadURL, modelURL(v4), t)
+
+	Assert("v4", resources[1].Deps[0].Object.(*v1.Service).Spec.Selector[types.ServingVariantLabel], t)
+
+	// Ingress of the serving routes to the heaviest variant, canary takes
+	// share of the other one.
+	route := resources[0].Deps[1].Object.(*networkingv1.Ingress)
+	canary := resources[0].Deps[2].Object.(*networkingv1.Ingress)
+	path := route.Spec.Rules[0].HTTP.Paths[0]
+	Assert("resnet", route.Name, t)
+	Assert("resnet-v3", path.Backend.Service.Name, t)
+	Assert(int32(9000), path.Backend.Service.Port.Number, t)
+	Assert("/"+v3.Namespace+"/resnet(/|$)(.*)", path.Path, t)
+	Assert("", route.Annotations[ingressCanaryAnnotation], t)
+	Assert(path.Path, canary.Spec.Rules[0].HTTP.Paths[0].Path, t)
+	Assert("resnet-v4", canary.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Name, t)
+	Assert("true", canary.Annotations[ingressCanaryAnnotation], t)
+	Assert("25", canary.Annotations[ingressCanaryWeightAnnotation], t)
+	Assert("100", canary.Annotations[ingressCanaryWeightTotalAnnotation], t)
+
+	// Variant with small weight gets a replica above the requested ones,
+	// limits are checked against all of them.
+	limited := serving
+	limited.Variants = []ServingVariant{{Name: "v3", Weight: 90}, {Name: "v4", Weight: 10}}
+	Assert([]int{4, 1}, VariantReplicas(limited.Variants, 4), t)
+	c := &BoardConfig{Config: Config{Kind: KindServing, Meta: Meta{Name: "resnet"}}, VolumesData: serving.VolumesData}
+	c.BoardMetadata.Limits = &dealerclient.ResourceLimit{Replicas: 4}
+	if _, err := c.GenerateModelServing(limited, false); err == nil {
+		t.Fatal("Expected error for replicas of variants exceeding the limit")
+	}
+	limited.Replicas = 3
+	if _, err := c.GenerateModelServing(limited, false); err != nil {
+		t.Fatal(err)
+	}
+
+	limited.Variants = append(limited.Variants, ServingVariant{Name: "v5", Weight: 10})
+	if _, err := GenerateModelServing(limited, false, nil); err == nil {
+		t.Fatal("Expected error for three variants with traffic")
+	}
+
+	if err := serving.Rollback("v4"); err != nil {
+		t.Fatal(err)
+	}
+	Assert([]int{4, 0}, VariantReplicas(serving.Variants, 4), t)
+	if err := serving.Rollback("v3"); err == nil {
+		t.Fatal("Expected error rolling back the last variant with traffic")
+	}
+
+	if err := serving.Promote("v4"); err != nil {
+		t.Fatal(err)
+	}
+	Assert([]int{0, 2}, VariantReplicas(serving.Variants, 2), t)
+	if err := serving.Promote("v5"); err == nil {
+		t.Fatal("Expected error promoting unknown variant")
+	}
+
+	serving.Variants = append(serving.Variants, ServingVariant{Name: "v4", Weight: 1})
+	if _, err := GenerateModelServing(serving, false, nil); err == nil {
+		t.Fatal("Expected error for duplicate variant")
+	}
+}
+
+func TestModelServingSourceResolved(t *testing.T) {
+	model := Volume{
+		Name:         "model",
+		VolumeSource: VolumeSource{Model: &ModelSource{Workspace: "ws", Model: "m", Version: "0.1.0"}},
+		MountPath:    "/model",
+	}
+	serving := BoardModelServing{
+		ModelServing: ModelServing{
+			Uix: Uix{
+				Meta:     Meta{Name: "m"},
+				Resource: Resource{Images: Images{CPU: "kuberlab/serving:latest"}},
+			},
+			Sources: []Volume{model},
+		},
+		VolumesData: []Volume{model},
+	}
+	c := &BoardConfig{VolumesData: serving.VolumesData, VersionLister: &fakeVersionLister{}}
+	c.Name = "m"
+	resources, err := c.GenerateModelServing(serving, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	init := resources[0].Object.(*appsv1.Deployment).Spec.Template.Spec.InitContainers[0]
+	env := map[string]string{}
+	for _, e := range init.Env {
+		env[e.Name] = e.Value
+	}
+	Assert("https://dealer.example.com/api/v0.2/workspace/ws/mlmodel/m/versions/0.1.0/download", env["MODEL_URL"], t)
+	Assert("", serving.VolumesData[0].Model.DownloadURL, t)
+
+	// Dealer of the serving is not kept on the config.
+	dealer := httptest.NewServer(http.NotFoundHandler())
+	defer dealer.Close()
+	c.VersionLister = nil
+	serving.DealerAPI = dealer.URL
+	serving.WorkspaceSecret = "secret"
+	serving.Workspace = "ws"
+	if _, err = c.GenerateModelServing(serving, false); err == nil {
+		t.Fatal("Expected error for unknown model")
+	}
+	Assert("", c.DealerAPI, t)
+	Assert(nil, c.VersionLister, t)
+}
@@ -0,0 +1,236 @@
+package mlapp
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/kuberlab/lib/pkg/dealerclient"
+	"github.com/kuberlab/lib/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const LatestVersion = "latest"
+
+// Space separated constraints, ">=2.0 <3", are joined by comma which is
+// the only AND separator supported by semver.
+var constraintSeparator = regexp.MustCompile(`([^\s,|])\s+([<>=!~^])`)
+
+// semver treats partial upper bound "<3" as "<3.x", it is padded to "<3.0.0".
+var partialUpperBound = regexp.MustCompile(`<\s*(\d+(?:\.\d+)?)([^.\d]|$)`)
+
+func normalizeConstraint(c string) string {
+	c = constraintSeparator.ReplaceAllString(c, "$1,$2")
+	return partialUpperBound.ReplaceAllStringFunc(c, func(m string) string {
+		parts := partialUpperBound.FindStringSubmatch(m)
+		version := parts[1]
+		for strings.Count(version, ".") < 2 {
+			version += ".0"
+		}
+		return "<" + version + parts[2]
+	})
+}
+
+// VersionLister lists versions of datasets and models and gets metadata of
+// model versions, implemented by dealerclient.Client.
+type VersionLister interface {
+	ModelVersionGetter
+	ListDatasetVersions(workspace, name string) ([]dealerclient.DatasetVersion, error)
+	ListModelVersions(workspace, name string) ([]dealerclient.ModelVersion, error)
+}
+
+type versionInfo struct {
+	Version string
+	Tags    []string
+}
+
+// IsVersionConstraint returns true if the revision must be resolved to
+// concrete version: latest, tag or constraint expression like "^1.2".
+func IsVersionConstraint(revision string) bool {
+	if revision == "" {
+		return false
+	}
+	if explicitConstraint(revision) {
+		return true
+	}
+	_, err := semver.NewVersion(revision)
+	return err != nil
+}
+
+// explicitConstraint returns false for values which may be also literal
+// non-semver versions, such values are kept as is if they can't be resolved.
+func explicitConstraint(revision string) bool {
+	return revision == LatestVersion || strings.ContainsAny(revision[:1], "<>=!~^")
+}
+
+// resolveVersion finds concrete version matching the revision. Exact
+// versions and tags are checked first, then latest means the highest
+// version and any other value is parsed as semver constraint.
+func resolveVersion(revision string, versions []versionInfo) (string, error) {
+	for _, v := range versions {
+		if v.Version == revision {
+			return v.Version, nil
+		}
+	}
+	for _, v := range versions {
+		for _, tag := range v.Tags {
+			if tag == revision {
+				return v.Version, nil
+			}
+		}
+	}
+	var constraint *semver.Constraints
+	if revision != LatestVersion {
+		var err error
+		constraint, err = semver.NewConstraint(normalizeConstraint(revision))
+		if err != nil {
+			return "", fmt.Errorf("invalid version constraint '%v': %v", revision, err)
+		}
+	}
+	var best *semver.Version
+	found := ""
+	for _, v := range versions {
+		sv, err := semver.NewVersion(v.Version)
+		if err != nil {
+			continue
+		}
+		if constraint == nil && sv.Prerelease() != "" {
+			// Latest is the highest release.
+			continue
+		}
+		if constraint != nil && !constraint.Check(sv) {
+			continue
+		}
+		if best == nil || sv.GreaterThan(best) {
+			best = sv
+			found = v.Version
+		}
+	}
+	if found == "" {
+		return "", fmt.Errorf("no version matches '%v'", revision)
+	}
+	return found, nil
+}
+
+// versionedSource returns workspace and name of dataset or model volume.
+func (c *BoardConfig) versionedSource(volume string) (string, string, bool) {
+	for _, v := range c.Spec.Volumes {
+		if v.Name != volume {
+			continue
+		}
+		switch {
+		case v.Dataset != nil:
+			return v.Dataset.Workspace, v.Dataset.Dataset, true
+		case v.DatasetFS != nil:
+			return v.DatasetFS.Workspace, v.DatasetFS.Dataset, true
+		case v.Model != nil:
+			return v.Model.Workspace, v.Model.Model, true
+		}
+	}
+	v := c.volumeByName(volume)
+	if v != nil && v.Model != nil {
+		return v.Model.Workspace, v.Model.Model, true
+	}
+	if v != nil && v.FlexVolume != nil {
+		opts := v.FlexVolume.Options
+		name := opts[opts["type"]]
+		return opts["workspace"], name, opts["workspace"] != "" && name != ""
+	}
+	return "", "", false
+}
+
+func (c *BoardConfig) versionLister() (VersionLister, error) {
+	if c.VersionLister != nil {
+		return c.VersionLister, nil
+	}
+	if c.DealerAPI == "" || c.GetWorkspaceSecret() == "" {
+		return nil, fmt.Errorf("dealer API is not configured")
+	}
+	return dealerclient.NewClient(
+		c.DealerAPI,
+		&dealerclient.AuthOpts{
+			WorkspaceSecret: c.GetWorkspaceSecret(),
+			Workspace:       c.Workspace,
+			Insecure:        true,
+		},
+	)
+}
+
+// ResolveRevisionConstraints resolves dataset and model revisions of the
+// task given as constraints to concrete versions. Resolved versions are
+// recorded back on the task.
+func (c *BoardConfig) ResolveRevisionConstraints(task *Task) error {
+	var lister VersionLister
+	resolve := func(revs []TaskRevision, model bool) error {
+		for i, rev := range revs {
+			if !IsVersionConstraint(rev.Revision) {
+				continue
+			}
+			workspace, name, ok := c.versionedSource(rev.VolumeName)
+			if !ok && !explicitConstraint(rev.Revision) {
+				continue
+			}
+			if !ok {
+				return errors.NewStatusReason(
+					http.StatusBadRequest,
+					fmt.Sprintf("Can't resolve version '%v' of '%v'", rev.Revision, rev.VolumeName),
+					"Version constraints are supported only for dataset and model volumes",
+				)
+			}
+			if lister == nil {
+				var err error
+				if lister, err = c.versionLister(); err != nil && !explicitConstraint(rev.Revision) {
+					logrus.Warnf("Version '%v' of '%v' is used as is: %v", rev.Revision, rev.VolumeName, err)
+					lister = nil
+					continue
+				}
+				if err != nil {
+					return fmt.Errorf("Can't resolve version '%v' of '%v': %v", rev.Revision, rev.VolumeName, err)
+				}
+			}
+			versions, err := listVersions(lister, workspace, name, model)
+			if err != nil {
+				return fmt.Errorf("Failed list versions of %v/%v: %v", workspace, name, err)
+			}
+			version, err := resolveVersion(rev.Revision, versions)
+			if err != nil {
+				return errors.NewStatusReason(
+					http.StatusBadRequest,
+					fmt.Sprintf("Can't resolve version of '%v' (%v/%v): %v", rev.VolumeName, workspace, name, err),
+					"Use exact version, tag, 'latest' or semver constraint like '^1.2' or '>=2.0 <3'",
+				)
+			}
+			logrus.Infof("Resolved version [%v=%v] to %v", rev.VolumeName, rev.Revision, version)
+			revs[i].Revision = version
+		}
+		return nil
+	}
+	if err := resolve(task.DatasetRevisions, false); err != nil {
+		return err
+	}
+	return resolve(task.ModelRevisions, true)
+}
+
+func listVersions(lister VersionLister, workspace, name string, model bool) ([]versionInfo, error) {
+	var versions []versionInfo
+	if model {
+		list, err := lister.ListModelVersions(workspace, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range list {
+			versions = append(versions, versionInfo{Version: v.Version, Tags: v.Tags})
+		}
+		return versions, nil
+	}
+	list, err := lister.ListDatasetVersions(workspace, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range list {
+		versions = append(versions, versionInfo{Version: v.Version, Tags: v.Tags})
+	}
+	return versions, nil
+}
@@ -0,0 +1,246 @@
+package mlapp
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/kuberlab/lib/pkg/dealerclient"
+	kuberlab "github.com/kuberlab/lib/pkg/kubernetes"
+	"k8s.io/api/core/v1"
+)
+
+type fakeVersionLister struct {
+	calls int
+}
+
+func (f *fakeVersionLister) ListDatasetVersions(workspace, name string) ([]dealerclient.DatasetVersion, error) {
+	f.calls++
+	return []dealerclient.DatasetVersion{
+		{Version: "1.2.0"},
+		{Version: "1.10.3", Tags: []string{"production"}},
+		{Version: "2.1.0"},
+		{Version: "3.0.0-rc1"},
+		{Version: "nightly"},
+	}, nil
+}
+
+func (f *fakeVersionLister) ListModelVersions(workspace, name string) ([]dealerclient.ModelVersion, error) {
+	f.calls++
+	return []dealerclient.ModelVersion{{Version: "0.1.0"}, {Version: "0.2.0"}}, nil
+}
+
+func (f *fakeVersionLister) GetModelVersion(workspace, name, version string) (*dealerclient.ModelVersion, error) {
+	f.calls++
+	if version != "0.1.0" && version != "0.2.0" {
+		return nil, fmt.Errorf("version %v not found", version)
+	}
+	return &dealerclient.ModelVersion{
+		Version:     version,
+		DownloadURL: "https://dealer.example.com/api/v0.2/workspace/" + workspace + "/mlmodel/" + name + "/versions/" + version + "/download",
+		Checksum:    "sha256:" + strings.Repeat("0", 64),
+	}, nil
+}
+
+func TestResolveVersion(t *testing.T) {
+	versions, _ := listVersions(&fakeVersionLister{}, "ws", "ds", false)
+	cases := map[string]string{
+		"latest":     "2.1.0",
+		"^1.2":       "1.10.3",
+		"~1.2":       "1.2.0",
+		">=2.0 <3":   "2.1.0",
+		">=1, <2":    "1.10.3",
+		"production": "1.10.3",
+		"nightly":    "nightly",
+		"1.2.0":      "1.2.0",
+		"<2":         "1.10.3",
+		"<1.10":      "1.2.0",
+	}
+	for in, expected := range cases {
+		got, err := resolveVersion(in, versions)
+		if err != nil {
+			t.Errorf("Resolve %q: %v", in, err)
+			continue
+		}
+		if got != expected {
+			t.Errorf("Resolve %q: expected %v, got %v", in, expected, got)
+		}
+	}
+	for _, in := range []string{"^4", "staging", ">=?"} {
+		if _, err := resolveVersion(in, versions); err == nil {
+			t.Errorf("Expected error for %q", in)
+		}
+	}
+	Assert(false, IsVersionConstraint("1.2.0"), t)
+	Assert(true, IsVersionConstraint("^1.2"), t)
+	Assert(true, IsVersionConstraint("production"), t)
+}
+
+func TestResolveRevisionConstraints(t *testing.T) {
+	lister := &fakeVersionLister{}
+	c := &BoardConfig{
+		Config: Config{
+			Meta:      Meta{Name: "project"},
+			Workspace: "ws",
+			Spec: Spec{
+				Volumes: []Volume{
+					{Name: "data", VolumeSource: VolumeSource{Dataset: &DatasetSource{Workspace: "ws", Dataset: "ds", Version: "^1.2"}}},
+					{Name: "model", VolumeSource: VolumeSource{Model: &ModelSource{Workspace: "ws", Model: "m"}}},
+				},
+			},
+		},
+		VolumesData: []Volume{
+			{Name: "data", VolumeSource: VolumeSource{FlexVolume: &v1.FlexVolumeSource{Options: map[string]string{"type": "dataset", "version": "^1.2"}}}},
+			{Name: "model", VolumeSource: VolumeSource{FlexVolume: &v1.FlexVolumeSource{Options: map[string]string{"type": "model"}}}},
+		},
+		VersionLister: lister,
+	}
+	task := &Task{ModelRevisions: []TaskRevision{{VolumeName: "model", Revision: "latest"}}}
+	c.InjectDatasetRevisions(task)
+	c.InjectModelRevisions(task)
+	Assert(nil, c.ResolveRevisionConstraints(task), t)
+	Assert([]TaskRevision{{VolumeName: "data", Revision: "1.10.3"}}, task.DatasetRevisions, t)
+	Assert([]TaskRevision{{VolumeName: "model", Revision: "0.2.0"}}, task.ModelRevisions, t)
+	Assert(2, lister.calls, t)
+
+	// Concrete versions are not resolved.
+	Assert(nil, c.ResolveRevisionConstraints(task), t)
+	Assert(2, lister.calls, t)
+
+	task.DatasetRevisions[0].Revision = "^9"
+	if err := c.ResolveRevisionConstraints(task); err == nil {
+		t.Fatal("Expected error for unsatisfiable constraint")
+	}
+
+	// Literal versions are kept if dealer is not configured.
+	c.VersionLister = nil
+	task.DatasetRevisions[0].Revision = "nightly"
+	Assert(nil, c.ResolveRevisionConstraints(task), t)
+	Assert("nightly", task.DatasetRevisions[0].Revision, t)
+	task.DatasetRevisions[0].Revision = "latest"
+	if err := c.ResolveRevisionConstraints(task); err == nil {
+		t.Fatal("Expected error without dealer")
+	}
+}
+
+func modelTaskConfig(lister VersionLister) *BoardConfig {
+	c := gitTaskConfig("https://github.com/kuberlab/lib")
+	c.DealerAPI = "https://dealer.example.com/api/v0.2"
+	c.VersionLister = lister
+	c.VolumesData = append(c.VolumesData, Volume{
+		Name:         "model",
+		VolumeSource: VolumeSource{Model: &ModelSource{Workspace: "ws", Model: "m", Version: "0.2.0"}},
+	})
+	return c
+}
+
+func TestResolveModelSources(t *testing.T) {
+	lister := &fakeVersionLister{}
+	c := modelTaskConfig(lister)
+	given := c.VolumesData
+	Assert(nil, c.ResolveModelSources(lister, nil), t)
+	m := c.VolumesData[1].Model
+	Assert("https://dealer.example.com/api/v0.2/workspace/ws/mlmodel/m/versions/0.2.0/download", m.DownloadURL, t)
+	Assert("sha256:"+strings.Repeat("0", 64), m.Checksum, t)
+	// Volumes of the caller are kept as is.
+	Assert("", given[1].Model.DownloadURL, t)
+	Assert(1, lister.calls, t)
+
+	// Resolved sources are not requested again.
+	Assert(nil, c.ResolveModelSources(lister, nil), t)
+	Assert(1, lister.calls, t)
+
+	c = modelTaskConfig(lister)
+	c.VolumesData[1].Model.Version = "9.9.9"
+	if err := c.ResolveModelSources(lister, nil); err == nil {
+		t.Fatal("Expected error for unknown version")
+	}
+}
+
+func TestResolveModelSourcesRevision(t *testing.T) {
+	lister := &fakeVersionLister{}
+	c := modelTaskConfig(lister)
+	c.VolumesData[1].Model.Version = "latest"
+	Assert(nil, c.ResolveModelSources(lister, nil), t)
+	Assert("0.2.0", c.VolumesData[1].Model.Version, t)
+	Assert("https://dealer.example.com/api/v0.2/workspace/ws/mlmodel/m/versions/0.2.0/download", c.VolumesData[1].Model.DownloadURL, t)
+
+	// Revision of the task replaces version, URL and checksum of the source.
+	c = modelTaskConfig(lister)
+	c.VolumesData[1].Model.DownloadURL = "https://dealer.example.com/api/v0.2/workspace/ws/mlmodel/m/versions/0.2.0/download"
+	c.VolumesData[1].Model.Checksum = "sha256:" + strings.Repeat("1", 64)
+	revisions := []TaskRevision{{VolumeName: "model", Revision: "<0.2"}}
+	task := &Task{ModelRevisions: revisions}
+	Assert(nil, c.ResolveModelSources(lister, task), t)
+	m := c.VolumesData[1].Model
+	Assert("0.1.0", m.Version, t)
+	Assert("https://dealer.example.com/api/v0.2/workspace/ws/mlmodel/m/versions/0.1.0/download", m.DownloadURL, t)
+	Assert("sha256:"+strings.Repeat("0", 64), m.Checksum, t)
+	Assert([]TaskRevision{{VolumeName: "model", Revision: "0.1.0"}}, task.ModelRevisions, t)
+	// Revisions of the caller are kept as is.
+	Assert("<0.2", revisions[0].Revision, t)
+}
+
+func TestTaskModelRevision(t *testing.T) {
+	c := modelTaskConfig(&fakeVersionLister{})
+	task := gitTask("")
+	task.Resources[0].Volumes = append(task.Resources[0].Volumes, VolumeMount{Name: "model"})
+	task.ModelRevisions = []TaskRevision{{VolumeName: "model", Revision: "0.1.0"}}
+	specs, err := c.GenerateTaskResources(task, "5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	init := specs[0].Resource.Object.(*kuberlab.WorkerSet).PodTemplate.Spec.InitContainers[1]
+	env := map[string]string{}
+	for _, e := range init.Env {
+		env[e.Name] = e.Value
+	}
+	Assert("https://dealer.example.com/api/v0.2/workspace/ws/mlmodel/m/versions/0.1.0/download", env["MODEL_URL"], t)
+	// The config is not changed by the task.
+	Assert("0.2.0", c.VolumesData[1].Model.Version, t)
+	Assert("", c.VolumesData[1].Model.DownloadURL, t)
+
+	// Model revision is recorded on the task with defaults of volumes.
+	task.ModelRevisions = nil
+	c.VolumesData[1].Model.Version = "latest"
+	Assert(nil, c.InjectRevisions(nil, &task), t)
+	Assert([]TaskRevision{{VolumeName: "model", Revision: "0.2.0"}}, task.ModelRevisions, t)
+}
+
+func TestModelInitStepRevision(t *testing.T) {
+	c := modelTaskConfig(nil)
+	v := c.VolumesData[1]
+	v.Model.DownloadURL = "https://dealer.example.com/api/v0.2/workspace/ws/mlmodel/m/versions/0.2.0/download"
+	if _, err := (modelProvider{}).InitSteps(c, v, VolumeInitContext{Name: "model", Revision: "0.1.0"}); err == nil {
+		t.Fatal("Expected error for unresolved revision")
+	}
+	steps, err := (modelProvider{}).InitSteps(c, v, VolumeInitContext{Name: "model", Revision: "0.2.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert(1, len(steps), t)
+}
+
+func TestTaskModelSourceResolved(t *testing.T) {
+	c := modelTaskConfig(&fakeVersionLister{})
+	task := gitTask("")
+	task.Resources[0].Volumes = append(task.Resources[0].Volumes, VolumeMount{Name: "model"})
+	specs, err := c.GenerateTaskResources(task, "5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	init := specs[0].Resource.Object.(*kuberlab.WorkerSet).PodTemplate.Spec.InitContainers[1]
+	env := map[string]string{}
+	for _, e := range init.Env {
+		env[e.Name] = e.Value
+	}
+	Assert("https://dealer.example.com/api/v0.2/workspace/ws/mlmodel/m/versions/0.2.0/download", env["MODEL_URL"], t)
+	Assert(strings.Repeat("0", 64), env["MODEL_SHA256"], t)
+
+	// Unresolved model can't be downloaded without dealer.
+	c = modelTaskConfig(nil)
+	c.DealerAPI = ""
+	if _, err := c.GenerateTaskResources(task, "5"); err == nil {
+		t.Fatal("Expected error without dealer")
+	}
+}
@@ -1,10 +1,7 @@
 package mlapp
 
 import (
-	"crypto/sha1"
-	"fmt"
 	"github.com/json-iterator/go"
-	"github.com/kuberlab/lib/pkg/utils"
 	"k8s.io/api/core/v1"
 )
 
@@ -51,57 +48,14 @@ type VolumeSource struct {
 	Dataset               *DatasetSource                        `json:"dataset,omitempty"`
 	DatasetFS             *DatasetFSSource                      `json:"datasetFS,omitempty"`
 	Model                 *ModelSource                          `json:"model,omitempty"`
+	Extension             *ExtensionVolumeSource                `json:"extension,omitempty"`
 }
 
 func (v Volume) CommonID() string {
-	if v.PersistentStorage != nil {
-		return "kps-" + v.PersistentStorage.StorageName
-	} else if v.NFS != nil {
-		m := "rw"
-		if v.NFS.ReadOnly {
-			m = "r"
-		}
-		if v.ReadOnly {
-			m = "r"
-		}
-		hash := fmt.Sprintf("%x", sha1.Sum([]byte(v.NFS.Server + "-" + v.NFS.Path + "-" + m)))
-		return "nfs-" + hash
-	}
-	m := "org-"
-	if v.ReadOnly {
-		m = "org-r-"
-	}
-	return m + utils.KubeNamespaceEncode(v.Name)
+	return v.provider().ID(v)
 }
 func (v Volume) V1Volume() v1.Volume {
-	r := v1.Volume{
-		Name: v.CommonID(),
-		VolumeSource: v1.VolumeSource{
-			HostPath:              v.HostPath,
-			NFS:                   v.NFS,
-			EmptyDir:              v.EmptyDir,
-			PersistentVolumeClaim: v.PersistentVolumeClaim,
-			FlexVolume:            v.FlexVolume,
-		},
-	}
-	if v.PersistentStorage != nil {
-		r.PersistentVolumeClaim = &v1.PersistentVolumeClaimVolumeSource{
-			ClaimName: utils.KubeDeploymentEncode(v.PersistentStorage.StorageName),
-		}
-	}
-	if v.GitRepo != nil {
-		//if v.GitRepo.AccountId != "" {
-		r.EmptyDir = &v1.EmptyDirVolumeSource{}
-		//} else {
-			// Copy git repo to not allow change it in the future.
-			//git := v.GitRepo.GitRepoVolumeSource
-			//r.GitRepo = &git
-		//}
-	}
-	if v.Model != nil {
-		r.EmptyDir = &v1.EmptyDirVolumeSource{}
-	}
-	return r
+	return v.provider().KubeVolume(v)
 }
 
 type GitRepoVolumeSource struct {
@@ -131,6 +85,8 @@ type ModelSource struct {
 	Model       string `json:"model,omitempty" protobuf:"bytes,2,opt,name=model"`
 	Version     string `json:"version,omitempty" protobuf:"bytes,3,opt,name=version"`
 	DownloadURL string `json:"downloadURL,omitempty" protobuf:"bytes,3,opt,name=downloadURL"`
+	// Checksum of model archive, "sha256:<hex>"
+	Checksum string `json:"checksum,omitempty" protobuf:"bytes,4,opt,name=checksum"`
 }
 
 type DatasetFSSource struct {
@@ -154,19 +110,5 @@ func (v Volume) String() string {
 }
 
 func (v Volume) GetBoundID() string {
-	if v.NFS != nil {
-		p := "rw"
-		if v.NFS.ReadOnly {
-			p = "r"
-		}
-		if v.ReadOnly {
-			p = "r"
-		}
-		return v.NFS.Server + "/" + v.NFS.Path + ":" + p
-	} else if v.GitRepo != nil {
-		return v.GitRepo.Repository + "/" + v.GitRepo.Directory + ":" + v.GitRepo.Revision
-	} else if v.PersistentVolumeClaim != nil {
-		return v.PersistentVolumeClaim.ClaimName
-	}
-	return v.Name
+	return v.provider().BoundID(v)
 }
@@ -0,0 +1,310 @@
+package mlapp
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/kuberlab/lib/pkg/utils"
+	"k8s.io/api/core/v1"
+)
+
+// VolumeProvider implements a kind of volume source: how it is identified,
+// rendered to kubernetes volume, prepared by init containers, versioned and
+// cleaned up.
+//
+// In-house storage types are described with Volume.Extension and handled by
+// providers added with RegisterVolumeProvider. Embed BaseVolumeProvider to get
+// default behaviour for everything except Name and Match.
+type VolumeProvider interface {
+	// Name of the provider, e.g. "gitRepo"
+	Name() string
+	// Match reports whether the volume is handled by the provider
+	Match(v Volume) bool
+	// ID returns name of kubernetes volume. Volumes with the same ID are shared
+	ID(v Volume) string
+	// BoundID identifies data bound to the volume
+	BoundID(v Volume) string
+	// KubeVolume renders kubernetes volume named v.CommonID()
+	KubeVolume(v Volume) v1.Volume
+	// InitSteps returns steps preparing volume content before component starts
+	InitSteps(c *BoardConfig, v Volume, ctx VolumeInitContext) ([]InitStep, error)
+	// DefaultRevision returns revision configured in the volume itself
+	DefaultRevision(v Volume) string
+	// SetRevision applies revision requested by task to rendered volume
+	SetRevision(kv *v1.Volume, revision string)
+	// CleanupSubPath returns sub path to mount for project data cleanup,
+	// false if volume doesn't hold project data
+	CleanupSubPath(c *BoardConfig, v Volume) (string, bool)
+}
+
+// VolumeInitContext is passed to VolumeProvider.InitSteps.
+type VolumeInitContext struct {
+	// Index of the mount in component mounts, used to build unique paths
+	Index int
+	// Mount name
+	Name string
+	// Revision requested by the task, empty for default
+	Revision string
+	// Secret mounts, shared by all init steps
+	SecretMounts []v1.VolumeMount
+}
+
+// MountsAt returns secret mounts along with the volume mounted at path.
+func (ctx VolumeInitContext) MountsAt(id, path string) []v1.VolumeMount {
+	return append(append([]v1.VolumeMount{}, ctx.SecretMounts...), v1.VolumeMount{
+		Name:      id,
+		MountPath: path,
+		ReadOnly:  false,
+	})
+}
+
+// ExtensionVolumeSource describes volume handled by registered VolumeProvider.
+type ExtensionVolumeSource struct {
+	// Provider type, matched by VolumeProvider
+	Type    string            `json:"type"`
+	Options map[string]string `json:"options,omitempty"`
+}
+
+var (
+	providersMu     sync.RWMutex
+	customProviders []VolumeProvider
+	builtinVolumes  = []VolumeProvider{
+		persistentStorageProvider{},
+		nfsProvider{},
+		gitRepoProvider{},
+		modelProvider{},
+		pvcProvider{},
+		flexVolumeProvider{},
+	}
+	defaultProvider VolumeProvider = genericProvider{}
+)
+
+// RegisterVolumeProvider adds provider. Registered providers are matched in
+// reverse order of registration and take precedence over built-in ones.
+func RegisterVolumeProvider(p VolumeProvider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	customProviders = append([]VolumeProvider{p}, customProviders...)
+}
+
+// VolumeProviderFor returns provider handling the volume, nil if the volume
+// is an extension which no registered provider matches.
+func VolumeProviderFor(v Volume) VolumeProvider {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	for _, p := range customProviders {
+		if p.Match(v) {
+			return p
+		}
+	}
+	if v.Extension != nil {
+		return nil
+	}
+	for _, p := range builtinVolumes {
+		if p.Match(v) {
+			return p
+		}
+	}
+	return defaultProvider
+}
+
+func volumeProvider(v Volume) (VolumeProvider, error) {
+	p := VolumeProviderFor(v)
+	if p == nil {
+		return nil, fmt.Errorf("No volume provider registered for type '%s' of source '%s'", v.Extension.Type, v.Name)
+	}
+	return p, nil
+}
+
+// provider never returns nil, volumes of unknown extension type are
+// handled as generic ones; volumeProvider reports them as errors.
+func (v Volume) provider() VolumeProvider {
+	if p := VolumeProviderFor(v); p != nil {
+		return p
+	}
+	return defaultProvider
+}
+
+// BaseVolumeProvider implements defaults of VolumeProvider.
+type BaseVolumeProvider struct{}
+
+func (BaseVolumeProvider) ID(v Volume) string {
+	m := "org-"
+	if v.ReadOnly {
+		m = "org-r-"
+	}
+	return m + utils.KubeNamespaceEncode(v.Name)
+}
+
+func (BaseVolumeProvider) BoundID(v Volume) string {
+	return v.Name
+}
+
+func (BaseVolumeProvider) KubeVolume(v Volume) v1.Volume {
+	return v1.Volume{
+		Name: v.CommonID(),
+		VolumeSource: v1.VolumeSource{
+			HostPath:              v.HostPath,
+			NFS:                   v.NFS,
+			EmptyDir:              v.EmptyDir,
+			PersistentVolumeClaim: v.PersistentVolumeClaim,
+			FlexVolume:            v.FlexVolume,
+		},
+	}
+}
+
+func (BaseVolumeProvider) InitSteps(c *BoardConfig, v Volume, ctx VolumeInitContext) ([]InitStep, error) {
+	return nil, nil
+}
+
+func (BaseVolumeProvider) DefaultRevision(v Volume) string {
+	return ""
+}
+
+func (BaseVolumeProvider) SetRevision(kv *v1.Volume, revision string) {}
+
+func (BaseVolumeProvider) CleanupSubPath(c *BoardConfig, v Volume) (string, bool) {
+	if v.ClusterStorage == "" || strings.HasPrefix(v.SubPath, "/") {
+		return "", false
+	}
+	return c.Workspace + "/" + c.WorkspaceID, true
+}
+
+type genericProvider struct{ BaseVolumeProvider }
+
+func (genericProvider) Name() string        { return "generic" }
+func (genericProvider) Match(v Volume) bool { return true }
+
+type persistentStorageProvider struct{ BaseVolumeProvider }
+
+func (persistentStorageProvider) Name() string        { return "persistentStorage" }
+func (persistentStorageProvider) Match(v Volume) bool { return v.PersistentStorage != nil }
+
+func (persistentStorageProvider) ID(v Volume) string {
+	return "kps-" + v.PersistentStorage.StorageName
+}
+
+func (p persistentStorageProvider) KubeVolume(v Volume) v1.Volume {
+	r := p.BaseVolumeProvider.KubeVolume(v)
+	r.PersistentVolumeClaim = &v1.PersistentVolumeClaimVolumeSource{
+		ClaimName: utils.KubeDeploymentEncode(v.PersistentStorage.StorageName),
+	}
+	return r
+}
+
+type nfsProvider struct{ BaseVolumeProvider }
+
+func (nfsProvider) Name() string        { return "nfs" }
+func (nfsProvider) Match(v Volume) bool { return v.NFS != nil }
+
+func (nfsProvider) mode(v Volume) string {
+	if v.NFS.ReadOnly || v.ReadOnly {
+		return "r"
+	}
+	return "rw"
+}
+
+func (p nfsProvider) ID(v Volume) string {
+	hash := fmt.Sprintf("%x", sha1.Sum([]byte(v.NFS.Server+"-"+v.NFS.Path+"-"+p.mode(v))))
+	return "nfs-" + hash
+}
+
+func (p nfsProvider) BoundID(v Volume) string {
+	return v.NFS.Server + "/" + v.NFS.Path + ":" + p.mode(v)
+}
+
+type gitRepoProvider struct{ BaseVolumeProvider }
+
+func (gitRepoProvider) Name() string        { return "gitRepo" }
+func (gitRepoProvider) Match(v Volume) bool { return v.GitRepo != nil }
+
+func (gitRepoProvider) BoundID(v Volume) string {
+	return v.GitRepo.Repository + "/" + v.GitRepo.Directory + ":" + v.GitRepo.Revision
+}
+
+// KubeVolume renders empty dir, repository is cloned by init container.
+func (p gitRepoProvider) KubeVolume(v Volume) v1.Volume {
+	r := p.BaseVolumeProvider.KubeVolume(v)
+	r.EmptyDir = &v1.EmptyDirVolumeSource{}
+	return r
+}
+
+func (gitRepoProvider) InitSteps(c *BoardConfig, v Volume, ctx VolumeInitContext) ([]InitStep, error) {
+	baseDir := fmt.Sprintf("/gitdata/%d", ctx.Index)
+	revision := ctx.Revision
+	if revision == "" {
+		revision = v.GitRepo.Revision
+	}
+	step, err := gitCloneStep(ctx.Name, v.GitRepo.Repository, revision, baseDir, ctx.MountsAt(v.CommonID(), baseDir))
+	if err != nil {
+		return nil, err
+	}
+	return []InitStep{step}, nil
+}
+
+func (gitRepoProvider) DefaultRevision(v Volume) string {
+	return v.GitRepo.Revision
+}
+
+func (gitRepoProvider) SetRevision(kv *v1.Volume, revision string) {
+	if kv.GitRepo != nil {
+		kv.GitRepo.Revision = revision
+	}
+}
+
+type modelProvider struct{ BaseVolumeProvider }
+
+func (modelProvider) Name() string        { return "model" }
+func (modelProvider) Match(v Volume) bool { return v.Model != nil }
+
+// KubeVolume renders empty dir, model is downloaded by init container.
+func (p modelProvider) KubeVolume(v Volume) v1.Volume {
+	r := p.BaseVolumeProvider.KubeVolume(v)
+	r.EmptyDir = &v1.EmptyDirVolumeSource{}
+	return r
+}
+
+// InitSteps downloads the source resolved to the revision of the task, see
+// ResolveModelSources.
+func (modelProvider) InitSteps(c *BoardConfig, v Volume, ctx VolumeInitContext) ([]InitStep, error) {
+	if ctx.Revision != "" && ctx.Revision != v.Model.Version {
+		return nil, fmt.Errorf("Model source '%v' is not resolved to version '%v'", v.Name, ctx.Revision)
+	}
+	baseDir := fmt.Sprintf("/model/%d", ctx.Index)
+	step, err := c.modelDownloadStep(ctx.Name, v.Model, baseDir, ctx.MountsAt(v.CommonID(), baseDir))
+	if err != nil {
+		return nil, err
+	}
+	return []InitStep{step}, nil
+}
+
+func (modelProvider) DefaultRevision(v Volume) string {
+	return v.Model.Version
+}
+
+type pvcProvider struct{ BaseVolumeProvider }
+
+func (pvcProvider) Name() string        { return "persistentVolumeClaim" }
+func (pvcProvider) Match(v Volume) bool { return v.PersistentVolumeClaim != nil }
+
+func (pvcProvider) BoundID(v Volume) string {
+	return v.PersistentVolumeClaim.ClaimName
+}
+
+type flexVolumeProvider struct{ BaseVolumeProvider }
+
+func (flexVolumeProvider) Name() string        { return "flexVolume" }
+func (flexVolumeProvider) Match(v Volume) bool { return v.FlexVolume != nil }
+
+func (flexVolumeProvider) DefaultRevision(v Volume) string {
+	return v.FlexVolume.Options["version"]
+}
+
+// SetRevision sets version of plukefs volumes.
+func (flexVolumeProvider) SetRevision(kv *v1.Volume, revision string) {
+	if kv.FlexVolume != nil && kv.FlexVolume.Options["kuberlabFS"] == "plukefs" {
+		kv.FlexVolume.Options["version"] = revision
+	}
+}
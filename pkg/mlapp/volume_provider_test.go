@@ -0,0 +1,74 @@
+package mlapp
+
+import (
+	"testing"
+
+	kuberlab "github.com/kuberlab/lib/pkg/kubernetes"
+	"k8s.io/api/core/v1"
+)
+
+type testStoreProvider struct{ BaseVolumeProvider }
+
+func (testStoreProvider) Name() string { return "test-store" }
+
+func (testStoreProvider) Match(v Volume) bool {
+	return v.Extension != nil && v.Extension.Type == "test-store"
+}
+
+func (testStoreProvider) ID(v Volume) string {
+	return "ts-" + v.Name
+}
+
+func (p testStoreProvider) KubeVolume(v Volume) v1.Volume {
+	r := p.BaseVolumeProvider.KubeVolume(v)
+	r.EmptyDir = &v1.EmptyDirVolumeSource{}
+	return r
+}
+
+func (testStoreProvider) InitSteps(c *BoardConfig, v Volume, ctx VolumeInitContext) ([]InitStep, error) {
+	return []InitStep{{
+		Name:   ctx.Name,
+		Image:  "test-store",
+		Script: `fetch "$BUCKET" /data`,
+		Params: []InitParam{{Name: "BUCKET", Value: v.Extension.Options["bucket"]}},
+		Mounts: ctx.MountsAt(v.CommonID(), "/data"),
+	}}, nil
+}
+
+func TestVolumeProviders(t *testing.T) {
+	Assert("gitRepo", VolumeProviderFor(Volume{VolumeSource: VolumeSource{GitRepo: &GitRepoVolumeSource{}}}).Name(), t)
+	Assert("generic", VolumeProviderFor(Volume{VolumeSource: VolumeSource{HostPath: &v1.HostPathVolumeSource{}}}).Name(), t)
+
+	c := gitTaskConfig("https://github.com/kuberlab/lib")
+	c.VolumesData = append(c.VolumesData, Volume{
+		Name: "store",
+		VolumeSource: VolumeSource{
+			Extension: &ExtensionVolumeSource{Type: "test-store", Options: map[string]string{"bucket": "b1"}},
+		},
+	})
+	task := gitTask("master")
+	task.Resources[0].Volumes = []VolumeMount{{Name: "store"}}
+
+	if _, err := c.GenerateTaskResources(task, "1"); err == nil {
+		t.Fatal("Extension volume without provider must be rejected")
+	}
+
+	providersMu.RLock()
+	registered := customProviders
+	providersMu.RUnlock()
+	defer func() {
+		providersMu.Lock()
+		customProviders = registered
+		providersMu.Unlock()
+	}()
+	RegisterVolumeProvider(testStoreProvider{})
+	specs, err := c.GenerateTaskResources(task, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := specs[0].Resource.Object.(*kuberlab.WorkerSet).PodTemplate
+	Assert("ts-store", pod.Spec.Volumes[0].Name, t)
+	Assert(1, len(pod.Spec.InitContainers), t)
+	Assert("test-store", pod.Spec.InitContainers[0].Image, t)
+	Assert("b1", pod.Spec.InitContainers[0].Env[0].Value, t)
+}
@@ -0,0 +1,101 @@
+// Package registry resolves image tags to digests using Docker Registry
+// HTTP API V2.
+package registry
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	DockerHub        = "docker.io"
+	dockerHubAPIHost = "registry-1.docker.io"
+	DefaultTag       = "latest"
+)
+
+var (
+	repositoryRe = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*$`)
+	tagRe        = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+	digestRe     = regexp.MustCompile(`^[a-z0-9]+(?:[+._-][a-z0-9]+)*:[a-zA-Z0-9=_-]{32,}$`)
+)
+
+// Reference is parsed image name.
+type Reference struct {
+	// Original image name
+	Name string
+	// Registry host, docker.io for Docker Hub
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference parses image name like [registry/]repository[:tag][@digest].
+func ParseReference(image string) (*Reference, error) {
+	ref := &Reference{Name: image}
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		ref.Digest = name[i+1:]
+		name = name[:i]
+		if !digestRe.MatchString(ref.Digest) {
+			return nil, fmt.Errorf("invalid digest in image '%v'", image)
+		}
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		ref.Tag = name[i+1:]
+		name = name[:i]
+		if !tagRe.MatchString(ref.Tag) {
+			return nil, fmt.Errorf("invalid tag in image '%v'", image)
+		}
+	}
+	ref.Registry = DockerHub
+	if i := strings.Index(name, "/"); i >= 0 {
+		host := name[:i]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			ref.Registry = host
+			name = name[i+1:]
+		}
+	}
+	if ref.Registry == DockerHub && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	if !repositoryRe.MatchString(name) {
+		return nil, fmt.Errorf("invalid image name '%v'", image)
+	}
+	ref.Repository = name
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = DefaultTag
+	}
+	return ref, nil
+}
+
+// Pinned returns original image name pinned by digest.
+func (r *Reference) Pinned(digest string) string {
+	name := r.Name
+	if i := strings.Index(name, "@"); i >= 0 {
+		name = name[:i]
+	}
+	return name + "@" + digest
+}
+
+func (r *Reference) apiHost() string {
+	if r.Registry == DockerHub {
+		return dockerHubAPIHost
+	}
+	return r.Registry
+}
+
+// NormalizeRegistry converts registry address from docker config, e.g.
+// https://index.docker.io/v1/, to registry host used in Reference.
+func NormalizeRegistry(server string) string {
+	server = strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+	if i := strings.Index(server, "/"); i >= 0 {
+		server = server[:i]
+	}
+	switch server {
+	case "index.docker.io", dockerHubAPIHost:
+		return DockerHub
+	}
+	return server
+}
@@ -0,0 +1,349 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultCacheTTL = 5 * time.Minute
+	DefaultTimeout  = 30 * time.Second
+)
+
+var manifestTypes = []string{
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+}
+
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// DefaultResolver is shared by all callers so concurrent launches reuse
+// resolved digests.
+var DefaultResolver = NewResolver(DefaultCacheTTL)
+
+// Credentials of the registry, see docker config auths.
+type Credentials struct {
+	Username string
+	Password string
+	// Refresh token exchanged for access token
+	IdentityToken string
+	// Bearer token sent to the registry as is
+	RegistryToken string
+}
+
+// Keychain returns credentials of the registry host or nil.
+type Keychain func(registry string) *Credentials
+
+// ImageNotFoundError is returned if image tag doesn't exist in registry.
+type ImageNotFoundError struct {
+	Image string
+}
+
+func (e *ImageNotFoundError) Error() string {
+	return fmt.Sprintf("image '%v' not found", e.Image)
+}
+
+type cacheKey struct {
+	registry   string
+	repository string
+	tag        string
+	identity   string
+}
+
+type cacheEntry struct {
+	digest  string
+	expires time.Time
+}
+
+// Resolver resolves image tags to digests and caches successful results
+// for TTL. Errors are not cached.
+type Resolver struct {
+	TTL time.Duration
+	// Timeout of single resolve
+	Timeout time.Duration
+	// Registries accessed over plain HTTP, localhost is always accessed
+	// over plain HTTP
+	Insecure []string
+	Client   *http.Client
+
+	mu    sync.Mutex
+	cache map[cacheKey]cacheEntry
+	now   func() time.Time
+}
+
+func NewResolver(ttl time.Duration) *Resolver {
+	return &Resolver{
+		TTL:     ttl,
+		Timeout: DefaultTimeout,
+		Client:  http.DefaultClient,
+		cache:   make(map[cacheKey]cacheEntry),
+		now:     time.Now,
+	}
+}
+
+func (c *Credentials) identity() string {
+	if c == nil {
+		return ""
+	}
+	h := sha256.New()
+	for _, v := range []string{c.Username, c.Password, c.IdentityToken, c.RegistryToken} {
+		fmt.Fprintf(h, "%d:%s", len(v), v)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// Pin returns image pinned by digest. Images already pinned are returned
+// as is.
+func (r *Resolver) Pin(ctx context.Context, image string, keychain Keychain) (string, error) {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+	if ref.Digest != "" {
+		return image, nil
+	}
+	var creds *Credentials
+	if keychain != nil {
+		creds = keychain(ref.Registry)
+	}
+	digest, err := r.Digest(ctx, ref, creds)
+	if err != nil {
+		return "", err
+	}
+	return ref.Pinned(digest), nil
+}
+
+// Digest returns digest of the manifest referenced by the tag.
+func (r *Resolver) Digest(ctx context.Context, ref *Reference, creds *Credentials) (string, error) {
+	if ref.Digest != "" {
+		return ref.Digest, nil
+	}
+	key := cacheKey{registry: ref.Registry, repository: ref.Repository, tag: ref.Tag, identity: creds.identity()}
+	r.mu.Lock()
+	e, ok := r.cache[key]
+	r.mu.Unlock()
+	if ok && r.now().Before(e.expires) {
+		return e.digest, nil
+	}
+
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+	digest, err := r.fetchDigest(ctx, ref, creds)
+	if err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	r.purgeLocked()
+	r.cache[key] = cacheEntry{digest: digest, expires: r.now().Add(r.TTL)}
+	r.mu.Unlock()
+	return digest, nil
+}
+
+// Purge removes expired cache entries.
+func (r *Resolver) Purge() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.purgeLocked()
+}
+
+func (r *Resolver) purgeLocked() {
+	now := r.now()
+	for k, e := range r.cache {
+		if !now.Before(e.expires) {
+			delete(r.cache, k)
+		}
+	}
+}
+
+func (r *Resolver) scheme(host string) string {
+	hostname := host
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		hostname = host[:i]
+	}
+	if hostname == "localhost" || hostname == "127.0.0.1" {
+		return "http"
+	}
+	for _, h := range r.Insecure {
+		if h == host {
+			return "http"
+		}
+	}
+	return "https"
+}
+
+func (r *Resolver) fetchDigest(ctx context.Context, ref *Reference, creds *Credentials) (string, error) {
+	host := ref.apiHost()
+	u := fmt.Sprintf("%s://%s/v2/%s/manifests/%s", r.scheme(host), host, ref.Repository, ref.Tag)
+
+	authorization := ""
+	if creds != nil && creds.RegistryToken != "" {
+		authorization = "Bearer " + creds.RegistryToken
+	}
+	resp, err := r.manifest(ctx, http.MethodHead, u, authorization)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && authorization == "" {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if authorization, err = r.authorize(ctx, challenge, ref, creds); err != nil {
+			return "", err
+		}
+		if resp, err = r.manifest(ctx, http.MethodHead, u, authorization); err != nil {
+			return "", err
+		}
+	}
+	resp.Body.Close()
+	if err = checkResponse(resp, ref); err != nil {
+		return "", err
+	}
+	if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+
+	// Digest header is optional, calculate it from the manifest.
+	if resp, err = r.manifest(ctx, http.MethodGet, u, authorization); err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if err = checkResponse(resp, ref); err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if _, err = io.Copy(h, resp.Body); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("sha256:%x", h.Sum(nil)), nil
+}
+
+func (r *Resolver) manifest(ctx context.Context, method, u, authorization string) (*http.Response, error) {
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestTypes, ", "))
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	return r.Client.Do(req.WithContext(ctx))
+}
+
+func checkResponse(resp *http.Response, ref *Reference) error {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return &ImageNotFoundError{Image: ref.Name}
+	}
+	return fmt.Errorf("failed get manifest of '%v': %v", ref.Name, resp.Status)
+}
+
+// authorize returns Authorization header value for the challenge.
+func (r *Resolver) authorize(ctx context.Context, challenge string, ref *Reference, creds *Credentials) (string, error) {
+	scheme := strings.ToLower(strings.SplitN(challenge, " ", 2)[0])
+	params := map[string]string{}
+	for _, m := range challengeParam.FindAllStringSubmatch(challenge, -1) {
+		params[strings.ToLower(m[1])] = m[2]
+	}
+	switch scheme {
+	case "basic":
+		if creds == nil || creds.Username == "" {
+			return "", fmt.Errorf("registry %v requires credentials", ref.Registry)
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(creds.Username+":"+creds.Password)), nil
+	case "bearer":
+		token, err := r.token(ctx, params, ref, creds)
+		if err != nil {
+			return "", err
+		}
+		return "Bearer " + token, nil
+	}
+	return "", fmt.Errorf("unsupported authentication challenge from %v: %q", ref.Registry, challenge)
+}
+
+func (r *Resolver) token(ctx context.Context, params map[string]string, ref *Reference, creds *Credentials) (string, error) {
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("registry %v sent bearer challenge without realm", ref.Registry)
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = fmt.Sprintf("repository:%s:pull", ref.Repository)
+	}
+
+	var req *http.Request
+	var err error
+	if creds != nil && creds.IdentityToken != "" {
+		form := url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {creds.IdentityToken},
+			"service":       {params["service"]},
+			"scope":         {scope},
+			"client_id":     {"kuberlab"},
+		}
+		req, err = http.NewRequest(http.MethodPost, realm, strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		q := url.Values{"scope": {scope}}
+		if params["service"] != "" {
+			q.Set("service", params["service"])
+		}
+		sep := "?"
+		if strings.Contains(realm, "?") {
+			sep = "&"
+		}
+		req, err = http.NewRequest(http.MethodGet, realm+sep+q.Encode(), nil)
+		if err != nil {
+			return "", err
+		}
+		if creds != nil && creds.Username != "" {
+			req.SetBasicAuth(creds.Username, creds.Password)
+		}
+	}
+	resp, err := r.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed get token for %v: %v", ref.Name, resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	var t struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.Unmarshal(data, &t); err != nil {
+		return "", fmt.Errorf("invalid token response from %v: %v", realm, err)
+	}
+	if t.Token == "" {
+		t.Token = t.AccessToken
+	}
+	if t.Token == "" {
+		return "", fmt.Errorf("empty token received from %v", realm)
+	}
+	return t.Token, nil
+}
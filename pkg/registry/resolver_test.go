@@ -0,0 +1,170 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testManifest = `{"schemaVersion":2}`
+
+var testDigest = fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(testManifest)))
+
+// testRegistry serves repository "team/app" with tag "v1" behind bearer
+// token auth, like Docker Hub does.
+type testRegistry struct {
+	*httptest.Server
+	manifests  int32
+	noDigest   bool
+	basicAuth  bool
+	tokenCalls int32
+}
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	reg := &testRegistry{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&reg.tokenCalls, 1)
+		if user, pass, _ := r.BasicAuth(); user != "user" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Query().Get("scope"), "repository:team/") || r.URL.Query().Get("service") != "test" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"token":"tkn"}`)
+	})
+	mux.HandleFunc("/v2/", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&reg.manifests, 1)
+		authorized := r.Header.Get("Authorization") == "Bearer tkn"
+		if reg.basicAuth {
+			user, pass, _ := r.BasicAuth()
+			authorized = user == "user" && pass == "secret"
+		}
+		if !authorized {
+			if reg.basicAuth {
+				w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			} else {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, reg.URL))
+			}
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if !strings.Contains(r.Header.Get("Accept"), "manifest.list.v2+json") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.URL.Path != "/v2/team/app/manifests/v1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if !reg.noDigest {
+			w.Header().Set("Docker-Content-Digest", testDigest)
+		}
+		if r.Method == http.MethodGet {
+			fmt.Fprint(w, testManifest)
+		}
+	})
+	reg.Server = httptest.NewServer(mux)
+	t.Cleanup(reg.Close)
+	return reg
+}
+
+func (reg *testRegistry) host() string {
+	return strings.TrimPrefix(reg.URL, "http://")
+}
+
+func testKeychain(host string) Keychain {
+	return func(registry string) *Credentials {
+		if registry == host {
+			return &Credentials{Username: "user", Password: "secret"}
+		}
+		return nil
+	}
+}
+
+func TestResolverBearer(t *testing.T) {
+	reg := newTestRegistry(t)
+	r := NewResolver(time.Minute)
+	image := reg.host() + "/team/app:v1"
+
+	pinned, err := r.Pin(context.Background(), image, testKeychain(reg.host()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert(image+"@"+testDigest, pinned, t)
+
+	// Cached.
+	pinned, err = r.Pin(context.Background(), image, testKeychain(reg.host()))
+	Assert(nil, err, t)
+	Assert(int32(2), atomic.LoadInt32(&reg.manifests), t)
+
+	// Pinned images are not resolved.
+	again, err := r.Pin(context.Background(), pinned, nil)
+	Assert(pinned, again, t)
+
+	if _, err = r.Pin(context.Background(), image, nil); err == nil {
+		t.Fatal("Expected error without credentials")
+	}
+	_, err = r.Pin(context.Background(), reg.host()+"/team/other:v1", testKeychain(reg.host()))
+	if _, ok := err.(*ImageNotFoundError); !ok {
+		t.Fatalf("Expected ImageNotFoundError, got %v", err)
+	}
+}
+
+func TestResolverBasicWithoutDigestHeader(t *testing.T) {
+	reg := newTestRegistry(t)
+	reg.basicAuth = true
+	reg.noDigest = true
+	r := NewResolver(time.Minute)
+
+	pinned, err := r.Pin(context.Background(), reg.host()+"/team/app:v1", testKeychain(reg.host()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	Assert(reg.host()+"/team/app:v1@"+testDigest, pinned, t)
+	Assert(int32(0), atomic.LoadInt32(&reg.tokenCalls), t)
+}
+
+func TestParseReference(t *testing.T) {
+	cases := map[string]Reference{
+		"ubuntu":                        {Registry: DockerHub, Repository: "library/ubuntu", Tag: "latest"},
+		"kuberlab/serving:1.0-gpu":      {Registry: DockerHub, Repository: "kuberlab/serving", Tag: "1.0-gpu"},
+		"localhost:5000/app":            {Registry: "localhost:5000", Repository: "app", Tag: "latest"},
+		"gcr.io/p/app:v1@" + testDigest: {Registry: "gcr.io", Repository: "p/app", Tag: "v1", Digest: testDigest},
+	}
+	for in, expected := range cases {
+		ref, err := ParseReference(in)
+		if err != nil {
+			t.Errorf("Parse %v: %v", in, err)
+			continue
+		}
+		expected.Name = in
+		Assert(expected, *ref, t)
+	}
+	for _, in := range []string{"", "Upper/case", "app:bad tag", "app@sha256:short", "app/../x"} {
+		if _, err := ParseReference(in); err == nil {
+			t.Errorf("Expected error for %q", in)
+		}
+	}
+	Assert(DockerHub, NormalizeRegistry("https://index.docker.io/v1/"), t)
+	Assert("registry.example.com:5000", NormalizeRegistry("registry.example.com:5000"), t)
+}
+
+func Assert(want, got interface{}, t *testing.T) {
+	if !reflect.DeepEqual(want, got) {
+		_, file, line, _ := runtime.Caller(1)
+		splitted := strings.Split(file, string(os.PathSeparator))
+		t.Fatalf("%v:%v: Failed: got %v, want %v", splitted[len(splitted)-1], line, got, want)
+	}
+}
@@ -6,6 +6,7 @@ const (
 	ComponentTypeLabel       = "kuberlab.io/component-type"
 	ComponentLabel           = "kuberlab.io/component"
 	ServingIDLabel           = "kuberlab.io/serving-id"
+	ServingVariantLabel      = "kuberlab.io/serving-variant"
 	KuberlabMLNodeLabel      = "kuberlab.io/ml-node"
 	KuberlabPrivateNodeLabel = "kuberlab.io/private-resource"
 	ComputeTypeLabel         = "kuberlab.io/compute-type"